@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Backend stores opaque values with a TTL, plus a generation counter per tag.
+// Entries are written under the generations current at the time, so bumping a
+// tag orphans every entry that depended on it; orphans age out on their own.
+type Backend interface {
+	Name() string
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Generations returns the current generation of each tag, 0 if never bumped.
+	Generations(ctx context.Context, tags []string) ([]int64, error)
+	Bump(ctx context.Context, tags []string) error
+}
+
+// Cache is a read-through cache of JSON-encoded values with tag-based
+// invalidation. Concurrent misses on the same key share a single load.
+type Cache struct {
+	backend Backend
+	ttl     time.Duration
+	group   singleflight.Group
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	coalesced atomic.Int64
+	errors    atomic.Int64
+}
+
+// Stats is a snapshot of cache counters since start-up.
+type Stats struct {
+	Backend   string  `json:"backend"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Coalesced int64   `json:"coalesced"` // Misses served by another caller's load
+	Errors    int64   `json:"errors"`
+	HitRate   float64 `json:"hit_rate"`
+	Entries   *int    `json:"entries,omitempty"`
+	Evictions *int64  `json:"evictions,omitempty"`
+}
+
+// Default is the cache configured from the environment by Init. It starts as a
+// small in-memory cache so it is always usable.
+var Default = New(NewMemory(1000), 10*time.Second)
+
+// Init configures Default from CACHE_BACKEND ("memory" or "redis").
+func Init() error {
+	ttl := 10 * time.Second
+	if v := os.Getenv("CACHE_TTL"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid CACHE_TTL %q", v)
+		}
+		ttl = parsed
+	}
+
+	backend := getEnv("CACHE_BACKEND", "memory")
+	switch backend {
+	case "memory":
+		maxEntries, err := strconv.Atoi(getEnv("CACHE_MAX_ENTRIES", "1000"))
+		if err != nil || maxEntries <= 0 {
+			return fmt.Errorf("invalid CACHE_MAX_ENTRIES %q", os.Getenv("CACHE_MAX_ENTRIES"))
+		}
+		Default = New(NewMemory(maxEntries), ttl)
+	case "redis":
+		redis, err := NewRedis(os.Getenv("REDIS_URL"), getEnv("CACHE_KEY_PREFIX", "invesa:"))
+		if err != nil {
+			return err
+		}
+		Default = New(redis, ttl)
+	default:
+		return fmt.Errorf("unknown CACHE_BACKEND %q", backend)
+	}
+	return nil
+}
+
+func New(backend Backend, ttl time.Duration) *Cache {
+	return &Cache{backend: backend, ttl: ttl}
+}
+
+// Fetch decodes the value cached under key into dst, calling load on a miss.
+// The entry is dropped as soon as any of tags is invalidated. If the backend
+// fails, Fetch falls back to load so the cache never takes a request down.
+func (c *Cache) Fetch(ctx context.Context, key string, tags []string, dst any, load func(context.Context) (any, error)) error {
+	gens, err := c.backend.Generations(ctx, tags)
+	if err != nil {
+		c.errors.Add(1)
+		return loadInto(ctx, dst, load)
+	}
+	fullKey := versionedKey(key, tags, gens)
+
+	data, ok, err := c.backend.Get(ctx, fullKey)
+	if err != nil {
+		c.errors.Add(1)
+	} else if ok && json.Unmarshal(data, dst) == nil {
+		c.hits.Add(1)
+		return nil
+	}
+	c.misses.Add(1)
+
+	// The load outlives the request that started it when others are waiting on it.
+	loadCtx := context.WithoutCancel(ctx)
+	loaded := false
+	result, err, shared := c.group.Do(fullKey, func() (any, error) {
+		loaded = true
+		value, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.backend.Set(loadCtx, fullKey, data, c.ttl); err != nil {
+			c.errors.Add(1)
+		}
+		return data, nil
+	})
+	// shared is also true for the caller that ran the load.
+	if shared && !loaded {
+		c.coalesced.Add(1)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(result.([]byte), dst)
+}
+
+// Invalidate makes every entry depending on any of tags unreachable.
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) {
+	if len(tags) == 0 {
+		return
+	}
+	if err := c.backend.Bump(ctx, tags); err != nil {
+		c.errors.Add(1)
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	s := Stats{
+		Backend:   c.backend.Name(),
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Coalesced: c.coalesced.Load(),
+		Errors:    c.errors.Load(),
+	}
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRate = float64(s.Hits) / float64(total)
+	}
+	if m, ok := c.backend.(*Memory); ok {
+		entries, evictions := m.Len(), m.Evictions()
+		s.Entries, s.Evictions = &entries, &evictions
+	}
+	return s
+}
+
+func loadInto(ctx context.Context, dst any, load func(context.Context) (any, error)) error {
+	value, err := load(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func versionedKey(key string, tags []string, gens []int64) string {
+	var b strings.Builder
+	b.WriteString(key)
+	for i, tag := range tags {
+		fmt.Fprintf(&b, "|%s@%d", tag, gens[i])
+	}
+	return b.String()
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
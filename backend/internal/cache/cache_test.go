@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchInvalidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		tags      []string
+		bump      []string
+		wantStale bool
+	}{
+		{"no bump", []string{"ideas"}, nil, true},
+		{"bumped tag", []string{"ideas"}, []string{"ideas"}, false},
+		{"unrelated tag", []string{"ideas"}, []string{"users"}, true},
+		{"one of several tags", []string{"ideas", "users"}, []string{"users"}, false},
+		{"untagged entry", nil, []string{"ideas"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := New(NewMemory(10), time.Minute)
+			version := 1
+			load := func(context.Context) (any, error) { return version, nil }
+
+			var got int
+			if err := c.Fetch(ctx, "k", tt.tags, &got, load); err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			version = 2
+			c.Invalidate(ctx, tt.bump...)
+			if err := c.Fetch(ctx, "k", tt.tags, &got, load); err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			if stale := got == 1; stale != tt.wantStale {
+				t.Errorf("after bumping %v, Fetch = %d; stale = %v, want %v", tt.bump, got, stale, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestFetchCoalescesConcurrentMisses(t *testing.T) {
+	const callers = 20
+	ctx := context.Background()
+	c := New(NewMemory(10), time.Minute)
+
+	var loads atomic.Int64
+	load := func(context.Context) (any, error) {
+		loads.Add(1)
+		// Hold the load until every caller has missed, and a little longer
+		// so they are all waiting on it rather than about to start another.
+		for c.misses.Load() < callers {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, callers)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Fetch(ctx, "k", []string{"ideas"}, &results[i], load); err != nil {
+				t.Errorf("Fetch: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("load ran %d times, want 1", got)
+	}
+	for i, r := range results {
+		if r != "value" {
+			t.Errorf("caller %d got %q, want %q", i, r, "value")
+		}
+	}
+	if s := c.Stats(); s.Coalesced != callers-1 {
+		t.Errorf("Coalesced = %d, want %d", s.Coalesced, callers-1)
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is a per-process LRU bounded by entry count and TTL.
+type Memory struct {
+	maxEntries int
+
+	mu          sync.Mutex
+	order       *list.List // Front is most recently used
+	entries     map[string]*list.Element
+	generations map[string]int64
+	evictions   int64
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{
+		maxEntries:  maxEntries,
+		order:       list.New(),
+		entries:     map[string]*list.Element{},
+		generations: map[string]int64{},
+	}
+}
+
+func (m *Memory) Name() string { return "memory" }
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if time.Now().After(entry.expiresAt) {
+		m.remove(el)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if el, ok := m.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	for m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+		m.evictions++
+	}
+	return nil
+}
+
+func (m *Memory) Generations(_ context.Context, tags []string) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	gens := make([]int64, len(tags))
+	for i, tag := range tags {
+		gens[i] = m.generations[tag]
+	}
+	return gens, nil
+}
+
+func (m *Memory) Bump(_ context.Context, tags []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tag := range tags {
+		m.generations[tag]++
+	}
+	return nil
+}
+
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *Memory) Evictions() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.evictions
+}
+
+func (m *Memory) remove(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.entries, el.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryEviction(t *testing.T) {
+	tests := []struct {
+		name string
+		// ops are "set k" or "get k", run in order on a cache of 3 entries.
+		ops           []string
+		wantKeys      []string // Most recently used first
+		wantEvictions int64
+	}{
+		{"under capacity", []string{"set a", "set b"}, []string{"b", "a"}, 0},
+		{"evicts oldest", []string{"set a", "set b", "set c", "set d"}, []string{"d", "c", "b"}, 1},
+		{"get refreshes", []string{"set a", "set b", "set c", "get a", "set d"}, []string{"d", "a", "c"}, 1},
+		{"set refreshes", []string{"set a", "set b", "set c", "set a", "set d"}, []string{"d", "a", "c"}, 1},
+		{"evicts in order", []string{"set a", "set b", "set c", "set d", "set e", "set f"}, []string{"f", "e", "d"}, 3},
+		{"get of evicted key misses", []string{"set a", "set b", "set c", "set d", "get a", "set e"}, []string{"e", "d", "c"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := NewMemory(3)
+			for _, op := range tt.ops {
+				key := op[4:]
+				if op[:3] == "set" {
+					m.Set(ctx, key, []byte(key), time.Minute)
+				} else {
+					m.Get(ctx, key)
+				}
+			}
+			var keys []string
+			for el := m.order.Front(); el != nil; el = el.Next() {
+				keys = append(keys, el.Value.(*memoryEntry).key)
+			}
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if got := m.Evictions(); got != tt.wantEvictions {
+				t.Errorf("Evictions() = %d, want %d", got, tt.wantEvictions)
+			}
+			for _, key := range tt.wantKeys {
+				if value, ok, _ := m.Get(ctx, key); !ok || string(value) != key {
+					t.Errorf("Get(%q) = %q, %v; want %q, true", key, value, ok, key)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(3)
+	m.Set(ctx, "a", []byte("a"), -time.Second)
+	if _, ok, _ := m.Get(ctx, "a"); ok {
+		t.Error("Get returned an expired entry")
+	}
+	if got := m.Len(); got != 0 {
+		t.Errorf("Len() = %d after reading an expired entry, want 0", got)
+	}
+}
@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	redisPoolSize = 16
+	redisTimeout  = 2 * time.Second
+)
+
+// Redis is a cache backend speaking the Redis protocol (RESP2), so it also
+// works with compatible servers such as Valkey, KeyDB or Dragonfly.
+type Redis struct {
+	addr     string
+	username string
+	password string
+	db       int
+	useTLS   bool
+	prefix   string
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// redisError is an error reply from the server. The connection stays usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// NewRedis parses a redis:// or rediss:// URL of the form
+// redis://[user:password@]host[:port][/db].
+func NewRedis(rawURL, prefix string) (*Redis, error) {
+	if rawURL == "" {
+		return nil, errors.New("REDIS_URL is required for the redis cache backend")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REDIS_URL: %v", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid REDIS_URL scheme %q", u.Scheme)
+	}
+
+	r := &Redis{
+		addr:   u.Host,
+		useTLS: u.Scheme == "rediss",
+		prefix: prefix,
+		pool:   make(chan *redisConn, redisPoolSize),
+	}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+		// redis://:password@host has only a password.
+		if _, hasPassword := u.User.Password(); !hasPassword {
+			r.password, r.username = r.username, ""
+		}
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid REDIS_URL database %q", db)
+		}
+	}
+	return r, nil
+}
+
+func (r *Redis) Name() string { return "redis" }
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	replies, err := r.do(ctx, []string{"GET", r.prefix + key})
+	if err != nil {
+		return nil, false, err
+	}
+	value, _ := replies[0].([]byte)
+	return value, value != nil, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.do(ctx, []string{"SET", r.prefix + key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10)})
+	return err
+}
+
+func (r *Redis) Generations(ctx context.Context, tags []string) ([]int64, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	cmd := []string{"MGET"}
+	for _, tag := range tags {
+		cmd = append(cmd, r.prefix+"gen:"+tag)
+	}
+	replies, err := r.do(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	values, _ := replies[0].([]interface{})
+	gens := make([]int64, len(tags))
+	for i := range gens {
+		if i < len(values) {
+			if raw, ok := values[i].([]byte); ok {
+				gens[i], _ = strconv.ParseInt(string(raw), 10, 64)
+			}
+		}
+	}
+	return gens, nil
+}
+
+func (r *Redis) Bump(ctx context.Context, tags []string) error {
+	cmds := make([][]string, len(tags))
+	for i, tag := range tags {
+		cmds[i] = []string{"INCR", r.prefix + "gen:" + tag}
+	}
+	_, err := r.do(ctx, cmds...)
+	return err
+}
+
+// do pipelines cmds on one pooled connection and returns one reply per command.
+func (r *Redis) do(ctx context.Context, cmds ...[]string) ([]interface{}, error) {
+	conn, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(redisTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.conn.SetDeadline(deadline)
+
+	replies, err := conn.roundTrip(cmds)
+	if err != nil {
+		conn.conn.Close()
+		return nil, err
+	}
+	r.put(conn)
+
+	for _, reply := range replies {
+		if e, ok := reply.(redisError); ok {
+			return nil, e
+		}
+	}
+	return replies, nil
+}
+
+func (r *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-r.pool:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if r.useTLS {
+		host, _, _ := net.SplitHostPort(r.addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &redisConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	var setup [][]string
+	if r.password != "" {
+		if r.username != "" {
+			setup = append(setup, []string{"AUTH", r.username, r.password})
+		} else {
+			setup = append(setup, []string{"AUTH", r.password})
+		}
+	}
+	if r.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.db)})
+	}
+	if len(setup) > 0 {
+		conn.SetDeadline(time.Now().Add(redisTimeout))
+		replies, err := rc.roundTrip(setup)
+		if err == nil {
+			for _, reply := range replies {
+				if e, ok := reply.(redisError); ok {
+					err = e
+				}
+			}
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+func (r *Redis) put(conn *redisConn) {
+	select {
+	case r.pool <- conn:
+	default:
+		conn.conn.Close()
+	}
+}
+
+func (c *redisConn) roundTrip(cmds [][]string) ([]interface{}, error) {
+	for _, cmd := range cmds {
+		fmt.Fprintf(c.w, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(cmds))
+	for i := range cmds {
+		reply, err := c.readReply()
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// readReply parses one RESP2 reply. Bulk strings are returned as []byte (nil
+// for a null bulk), integers as int64 and arrays as []interface{}.
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return redisError(body), nil
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return []byte(nil), nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return []interface{}(nil), nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -84,12 +84,21 @@ func CreateTables() error {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
 
+		`CREATE TABLE IF NOT EXISTS categories (
+			id SERIAL PRIMARY KEY,
+			slug VARCHAR(50) UNIQUE NOT NULL,
+			name VARCHAR(100) NOT NULL,
+			sort_order INTEGER NOT NULL DEFAULT 0,
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
 		`CREATE TABLE IF NOT EXISTS ideas (
 			id SERIAL PRIMARY KEY,
 			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
 			title VARCHAR(255) NOT NULL,
 			description TEXT NOT NULL,
-			category VARCHAR(50) NOT NULL DEFAULT 'Other',
+			category VARCHAR(50) NOT NULL DEFAULT 'other',
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
 
@@ -101,13 +110,339 @@ func CreateTables() error {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
 
-		`CREATE TABLE IF NOT EXISTS idea_likes (
+		// A user can leave each reaction kind at most once per idea.
+		`CREATE TABLE IF NOT EXISTS idea_reactions (
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			kind VARCHAR(20) NOT NULL CHECK (kind IN ('like', 'insightful', 'would_invest')),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, idea_id, kind)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS comments (
+			id SERIAL PRIMARY KEY,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			content TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Denormalized per-idea counters, kept in step by the writers and
+		// repaired by the periodic reconciliation job.
+		`CREATE TABLE IF NOT EXISTS idea_stats (
+			idea_id INTEGER PRIMARY KEY REFERENCES ideas(id) ON DELETE CASCADE,
+			likes_count INTEGER NOT NULL DEFAULT 0,
+			insightful_count INTEGER NOT NULL DEFAULT 0,
+			would_invest_count INTEGER NOT NULL DEFAULT 0,
+			comments_count INTEGER NOT NULL DEFAULT 0,
+			views_count INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Precomputed ranking inputs for the ideas feed, refreshed by handlers.RefreshIdeaRankings.
+		`CREATE TABLE IF NOT EXISTS idea_rankings (
+			idea_id INTEGER PRIMARY KEY REFERENCES ideas(id) ON DELETE CASCADE,
+			likes_count INTEGER NOT NULL DEFAULT 0,
+			comments_count INTEGER NOT NULL DEFAULT 0,
+			views_count INTEGER NOT NULL DEFAULT 0,
+			trending_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+			computed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS user_follows (
+			follower_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			followed_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (follower_id, followed_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS category_follows (
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			category_slug VARCHAR(50) REFERENCES categories(slug) ON UPDATE CASCADE ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, category_slug)
+		)`,
+
+		// In-app notifications. actor_id and idea_id are optional context.
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id BIGSERIAL PRIMARY KEY,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			type VARCHAR(50) NOT NULL,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			message TEXT NOT NULL,
+			read_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Feed signals: ideas shown to a user (seen_count counts feed sessions) and
+		// ideas they marked "not interested".
+		`CREATE TABLE IF NOT EXISTS idea_impressions (
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			seen_count INTEGER NOT NULL DEFAULT 1,
+			first_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, idea_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS idea_dismissals (
 			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
 			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			reason VARCHAR(50) NOT NULL DEFAULT '',
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			PRIMARY KEY (user_id, idea_id)
 		)`,
 
+		`CREATE TABLE IF NOT EXISTS idea_attachments (
+			id SERIAL PRIMARY KEY,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			uploader_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			storage_key VARCHAR(255) UNIQUE NOT NULL,
+			file_name VARCHAR(255) NOT NULL,
+			content_type VARCHAR(255) NOT NULL,
+			size_bytes BIGINT NOT NULL,
+			sha256 CHAR(64) NOT NULL,
+			kind VARCHAR(30) NOT NULL DEFAULT 'other',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Versioned NDA documents gating an idea's confidential details.
+		`CREATE TABLE IF NOT EXISTS idea_ndas (
+			id SERIAL PRIMARY KEY,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			version INTEGER NOT NULL,
+			body TEXT NOT NULL,
+			document_hash CHAR(64) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (idea_id, version)
+		)`,
+
+		// Click-through signatures. Rows are never deleted, only revoked, to keep the audit trail.
+		`CREATE TABLE IF NOT EXISTS nda_acceptances (
+			id SERIAL PRIMARY KEY,
+			nda_id INTEGER REFERENCES idea_ndas(id) ON DELETE CASCADE,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			signer_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			signer_name VARCHAR(255) NOT NULL,
+			nda_version INTEGER NOT NULL,
+			document_hash CHAR(64) NOT NULL,
+			ip_address VARCHAR(50),
+			user_agent TEXT,
+			accepted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			revoked_at TIMESTAMP,
+			revoked_by UUID REFERENCES users(id) ON DELETE SET NULL
+		)`,
+
+		// Append-only idea view log. viewer_key is the user id, or a hash of IP and
+		// user agent for anonymous viewers.
+		`CREATE TABLE IF NOT EXISTS idea_view_events (
+			id BIGSERIAL PRIMARY KEY,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			viewer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			viewer_key VARCHAR(64) NOT NULL,
+			viewer_role VARCHAR(50) NOT NULL DEFAULT 'anonymous',
+			referrer TEXT NOT NULL DEFAULT '',
+			referrer_host VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS idea_viewers (
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			viewer_key VARCHAR(64) NOT NULL,
+			viewer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			viewer_role VARCHAR(50) NOT NULL DEFAULT 'anonymous',
+			first_viewed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (idea_id, viewer_key)
+		)`,
+
+		// Daily rollups of idea_view_events, maintained by handlers.RollupIdeaViews.
+		`CREATE TABLE IF NOT EXISTS idea_view_daily (
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			views INTEGER NOT NULL DEFAULT 0,
+			unique_viewers INTEGER NOT NULL DEFAULT 0,
+			investor_views INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (idea_id, day)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS idea_referrer_daily (
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			referrer_host VARCHAR(255) NOT NULL,
+			views INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (idea_id, day, referrer_host)
+		)`,
+
+		// Funding rounds. Amounts are whole units of the round's currency.
+		`CREATE TABLE IF NOT EXISTS funding_rounds (
+			id SERIAL PRIMARY KEY,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			instrument VARCHAR(20) NOT NULL CHECK (instrument IN ('equity', 'safe', 'convertible')),
+			target_amount BIGINT NOT NULL CHECK (target_amount > 0),
+			currency CHAR(3) NOT NULL,
+			valuation BIGINT,
+			minimum_ticket BIGINT,
+			opens_at TIMESTAMP,
+			closes_at TIMESTAMP,
+			status VARCHAR(20) NOT NULL DEFAULT 'draft',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// One row per investor per round; status moves through the interest pipeline.
+		`CREATE TABLE IF NOT EXISTS round_interests (
+			id SERIAL PRIMARY KEY,
+			round_id INTEGER REFERENCES funding_rounds(id) ON DELETE CASCADE,
+			investor_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			status VARCHAR(20) NOT NULL,
+			amount BIGINT,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (round_id, investor_id)
+		)`,
+
+		// User reports against ideas, comments, messages and users. target_id is
+		// the target's primary key as text; target_user_id is its author.
+		`CREATE TABLE IF NOT EXISTS reports (
+			id SERIAL PRIMARY KEY,
+			reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			target_type VARCHAR(20) NOT NULL CHECK (target_type IN ('idea', 'comment', 'message', 'user')),
+			target_id VARCHAR(64) NOT NULL,
+			target_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			reason VARCHAR(30) NOT NULL,
+			details TEXT NOT NULL DEFAULT '',
+			status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'claimed', 'resolved', 'dismissed')),
+			claimed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			claimed_at TIMESTAMP,
+			resolution TEXT NOT NULL DEFAULT '',
+			resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			resolved_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Audit trail of moderator decisions. moderator_id is NULL for the admin key.
+		`CREATE TABLE IF NOT EXISTS moderation_actions (
+			id SERIAL PRIMARY KEY,
+			report_id INTEGER REFERENCES reports(id) ON DELETE SET NULL,
+			moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			action VARCHAR(20) NOT NULL,
+			target_type VARCHAR(20) NOT NULL,
+			target_id VARCHAR(64) NOT NULL,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Verdicts of the content screening pipeline, one per screened create or
+		// edit. content_id is NULL for rejected content, which is never saved;
+		// review_status is only set for held content.
+		`CREATE TABLE IF NOT EXISTS screening_results (
+			id SERIAL PRIMARY KEY,
+			content_type VARCHAR(20) NOT NULL CHECK (content_type IN ('idea', 'message', 'feedback', 'update')),
+			content_id INTEGER,
+			author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			author_key VARCHAR(64) NOT NULL,
+			content_hash VARCHAR(64) NOT NULL,
+			excerpt TEXT NOT NULL DEFAULT '',
+			verdict VARCHAR(10) NOT NULL CHECK (verdict IN ('allow', 'hold', 'reject')),
+			reasons JSONB NOT NULL DEFAULT '[]',
+			review_status VARCHAR(10) CHECK (review_status IN ('pending', 'approved', 'rejected')),
+			reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			reviewed_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Idea teams: the owner stays ideas.user_id; collaborators are members
+		// with a role. Invitations target a user, or an email with no account yet.
+		`CREATE TABLE IF NOT EXISTS idea_members (
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			role VARCHAR(10) NOT NULL CHECK (role IN ('editor', 'viewer')),
+			added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (idea_id, user_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS idea_invitations (
+			id SERIAL PRIMARY KEY,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			inviter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			invitee_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			email VARCHAR(255) NOT NULL DEFAULT '',
+			role VARCHAR(10) NOT NULL CHECK (role IN ('editor', 'viewer')),
+			status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'revoked')),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			responded_at TIMESTAMP
+		)`,
+
+		// Founder progress updates on an idea. Scheduled updates have publish_at
+		// until the scheduler publishes them; delivered_at is set once followers
+		// and supporters have been sent the batch containing the update.
+		`CREATE TABLE IF NOT EXISTS idea_updates (
+			id SERIAL PRIMARY KEY,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			title VARCHAR(255) NOT NULL,
+			body TEXT NOT NULL,
+			visibility VARCHAR(20) NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'supporters')),
+			publish_at TIMESTAMP,
+			published_at TIMESTAMP,
+			delivered_at TIMESTAMP,
+			hidden_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		// Direct-message threads, one per pair of users (user_one_id sorts
+		// first). Each participant keeps their own unread count and recency so
+		// the conversation list never scans messages.
+		`CREATE TABLE IF NOT EXISTS conversations (
+			id SERIAL PRIMARY KEY,
+			user_one_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			user_two_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (user_one_id, user_two_id),
+			CHECK (user_one_id < user_two_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS conversation_participants (
+			conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			last_read_message_id INTEGER NOT NULL DEFAULT 0,
+			unread_count INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (conversation_id, user_id)
+		)`,
+
+		// Private bookmarks: each user's saved ideas live in named watchlists.
+		`CREATE TABLE IF NOT EXISTS watchlists (
+			id SERIAL PRIMARY KEY,
+			user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (user_id, name)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS watchlist_items (
+			watchlist_id INTEGER REFERENCES watchlists(id) ON DELETE CASCADE,
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			note TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (watchlist_id, idea_id)
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS tags (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(50) UNIQUE NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+
+		`CREATE TABLE IF NOT EXISTS idea_tags (
+			idea_id INTEGER REFERENCES ideas(id) ON DELETE CASCADE,
+			tag_id INTEGER REFERENCES tags(id) ON DELETE CASCADE,
+			PRIMARY KEY (idea_id, tag_id)
+		)`,
+
 		`CREATE TABLE IF NOT EXISTS activity_logs (
 			id SERIAL PRIMARY KEY,
 			user_id UUID REFERENCES users(id) ON DELETE SET NULL,
@@ -127,12 +462,44 @@ func CreateTables() error {
 		`CREATE INDEX IF NOT EXISTS idx_ideas_category ON ideas(category)`,
 		`CREATE INDEX IF NOT EXISTS idx_ideas_userid ON ideas(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_ideas_created_at ON ideas(created_at)`,
-		`CREATE INDEX IF NOT EXISTS idx_likes_ideaid ON idea_likes(idea_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_reactions_idea_kind ON idea_reactions(idea_id, kind)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_stats_likes ON idea_stats(likes_count DESC NULLS LAST, idea_id DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_stats_comments ON idea_stats(comments_count DESC NULLS LAST, idea_id DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_pair ON messages(sender_id, receiver_id, created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_feedback_created_at ON feedback(created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_activity_logs_userid ON activity_logs(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_activity_logs_created_at ON activity_logs(created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_activity_logs_created_at ON activity_logs(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_ideaid ON comments(idea_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_rankings_trending ON idea_rankings(trending_score DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_rankings_likes ON idea_rankings(likes_count DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_rankings_comments ON idea_rankings(comments_count DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_attachments_ideaid ON idea_attachments(idea_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_nda_acceptances_idea_signer ON nda_acceptances(idea_id, signer_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_view_events_created_at ON idea_view_events(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_funding_rounds_ideaid ON funding_rounds(idea_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_funding_rounds_one_open ON funding_rounds(idea_id) WHERE status = 'open'`,
+		`CREATE INDEX IF NOT EXISTS idx_round_interests_investor ON round_interests(investor_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_watchlist_items_ideaid ON watchlist_items(idea_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_follows_followed ON user_follows(followed_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_category_follows_slug ON category_follows(category_slug)`,
+		`CREATE INDEX IF NOT EXISTS idx_reports_queue ON reports(status, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_reports_target ON reports(target_type, target_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_one_open ON reports(reporter_id, target_type, target_id) WHERE status IN ('open', 'claimed')`,
+		`CREATE INDEX IF NOT EXISTS idx_moderation_actions_target ON moderation_actions(target_type, target_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_members_user ON idea_members(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_conversation_participants_user ON conversation_participants(user_id, updated_at DESC)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_idea_invitations_one_pending ON idea_invitations(idea_id, COALESCE(invitee_id::text, lower(email))) WHERE status = 'pending'`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_invitations_invitee ON idea_invitations(invitee_id) WHERE status = 'pending'`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_updates_timeline ON idea_updates(idea_id, published_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_updates_undelivered ON idea_updates(idea_id, published_at) WHERE delivered_at IS NULL AND published_at IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_updates_publish_at ON idea_updates(publish_at) WHERE publish_at IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_screening_results_author ON screening_results(content_type, author_key, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_screening_results_review ON screening_results(review_status, created_at) WHERE review_status IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications(user_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_categories_sort ON categories(sort_order, name)`,
+		`CREATE INDEX IF NOT EXISTS idx_tags_name_prefix ON tags(name text_pattern_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_idea_tags_tagid ON idea_tags(tag_id)`,
 
 		// Migrations: Ensure columns exist if table was created before auth features
 		`ALTER TABLE users ADD COLUMN IF NOT EXISTS reset_token VARCHAR(255)`,
@@ -140,6 +507,160 @@ func CreateTables() error {
 		`ALTER TABLE users ADD COLUMN IF NOT EXISTS is_verified BOOLEAN DEFAULT FALSE`,
 		`ALTER TABLE users ADD COLUMN IF NOT EXISTS verification_token VARCHAR(255)`,
 		`ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS stage VARCHAR(30) NOT NULL DEFAULT ''`,
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS visibility VARCHAR(20) NOT NULL DEFAULT 'public'`,
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS published_at TIMESTAMP`,
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS share_token VARCHAR(64) UNIQUE`,
+		`UPDATE ideas SET published_at = created_at WHERE published_at IS NULL AND visibility <> 'draft'`,
+		`CREATE INDEX IF NOT EXISTS idx_ideas_visibility ON ideas(visibility, created_at)`,
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS confidential_details TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS external_id VARCHAR(255)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_ideas_owner_external_id ON ideas(user_id, external_id) WHERE external_id IS NOT NULL`,
+		// Trigram indexes back duplicate detection and related ideas.
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_ideas_title_trgm ON ideas USING gin (title gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_ideas_description_trgm ON ideas USING gin (description gin_trgm_ops)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS interest_sectors TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS interest_stages TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS feed_token VARCHAR(64) UNIQUE`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS is_moderator BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP`,
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP`,
+		`ALTER TABLE comments ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP`,
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP`,
+		// Screening holds are kept apart from moderator hides, so approving a
+		// held edit cannot bring back content a moderator took down.
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS held_at TIMESTAMP`,
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS held_at TIMESTAMP`,
+		`ALTER TABLE idea_updates ADD COLUMN IF NOT EXISTS held_at TIMESTAMP`,
+		// Email invitations are accepted with the single-use token from the
+		// emailed link, never by matching an (unverified) account address.
+		`ALTER TABLE idea_invitations ADD COLUMN IF NOT EXISTS token_hash VARCHAR(64)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_idea_invitations_token ON idea_invitations(token_hash) WHERE token_hash IS NOT NULL`,
+		`DROP INDEX IF EXISTS idx_idea_invitations_email`,
+		// When the impression's current feed session began. It only moves when
+		// the session expires, so steady scrolling cannot keep one open forever.
+		`ALTER TABLE idea_impressions ADD COLUMN IF NOT EXISTS session_started_at TIMESTAMP`,
+		`ALTER TABLE idea_impressions ALTER COLUMN session_started_at SET DEFAULT CURRENT_TIMESTAMP`,
+		// Views are deduplicated per viewer within fixed time buckets; older
+		// events have no bucket and never conflict.
+		`ALTER TABLE idea_view_events ADD COLUMN IF NOT EXISTS dedup_bucket BIGINT`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_idea_view_events_bucket ON idea_view_events(idea_id, viewer_key, dedup_bucket)`,
+		`DROP INDEX IF EXISTS idx_idea_view_events_dedup`,
+		// Set when the receiver's client acknowledges a message pushed over the chat socket.
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_receiver_undelivered ON messages(receiver_id, id) WHERE delivered_at IS NULL`,
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS conversation_id INTEGER REFERENCES conversations(id) ON DELETE CASCADE`,
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS read_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id, id)`,
+		// Bumped by every change a listing or feed reader can see: edits, visibility
+		// changes, publishing, hides, screening releases, trashing and restores.
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP`,
+		`UPDATE ideas SET updated_at = COALESCE(published_at, created_at) WHERE updated_at IS NULL`,
+		`ALTER TABLE ideas ALTER COLUMN updated_at SET DEFAULT CURRENT_TIMESTAMP`,
+		// Soft delete: trashed ideas are restorable until the purge job removes them.
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS idx_ideas_deleted_at ON ideas(deleted_at) WHERE deleted_at IS NOT NULL`,
+		// Scheduled publishing: drafts with publish_at go live with scheduled_visibility.
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS publish_at TIMESTAMP`,
+		`ALTER TABLE ideas ADD COLUMN IF NOT EXISTS scheduled_visibility VARCHAR(20)`,
+		`CREATE INDEX IF NOT EXISTS idx_ideas_publish_at ON ideas(publish_at) WHERE publish_at IS NOT NULL`,
+		// Founder updates are screened like ideas. The check is only replaced
+		// while it still lacks 'update', so restarts do not rescan the table.
+		`DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'screening_results_content_type_check'
+					AND pg_get_constraintdef(oid) LIKE '%''update''%') THEN
+				ALTER TABLE screening_results DROP CONSTRAINT IF EXISTS screening_results_content_type_check;
+				ALTER TABLE screening_results ADD CONSTRAINT screening_results_content_type_check CHECK (content_type IN ('idea', 'message', 'feedback', 'update'));
+			END IF;
+		END $$`,
+
+		// Migrations: Move free-form idea categories onto the categories table.
+		// The slug expression must stay in sync with handlers.slugify.
+		`INSERT INTO categories (slug, name, sort_order) VALUES
+			('technology', 'Technology', 10),
+			('health-wellness', 'Health & Wellness', 20),
+			('finance', 'Finance', 30),
+			('education', 'Education', 40),
+			('lifestyle', 'Lifestyle', 50),
+			('food-beverage', 'Food & Beverage', 60),
+			('art-design', 'Art & Design', 70),
+			('retail', 'Retail', 80),
+			('other', 'Other', 1000)
+		ON CONFLICT (slug) DO NOTHING`,
+		`INSERT INTO categories (slug, name, sort_order)
+		SELECT DISTINCT ON (slug) slug, name, 500 FROM (
+			SELECT COALESCE(NULLIF(TRIM(BOTH '-' FROM LOWER(REGEXP_REPLACE(category, '[^a-zA-Z0-9]+', '-', 'g'))), ''), 'other') AS slug,
+				TRIM(category) AS name
+			FROM ideas
+		) legacy
+		ORDER BY slug, name
+		ON CONFLICT (slug) DO NOTHING`,
+		`UPDATE ideas SET category = COALESCE(NULLIF(TRIM(BOTH '-' FROM LOWER(REGEXP_REPLACE(category, '[^a-zA-Z0-9]+', '-', 'g'))), ''), 'other')
+		WHERE category <> COALESCE(NULLIF(TRIM(BOTH '-' FROM LOWER(REGEXP_REPLACE(category, '[^a-zA-Z0-9]+', '-', 'g'))), ''), 'other')`,
+		`ALTER TABLE ideas ALTER COLUMN category SET DEFAULT 'other'`,
+		`DO $$ BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'fk_ideas_category') THEN
+				ALTER TABLE ideas ADD CONSTRAINT fk_ideas_category FOREIGN KEY (category) REFERENCES categories(slug) ON UPDATE CASCADE;
+			END IF;
+		END $$`,
+		// Likes became "like" reactions. The old table is dropped once copied so
+		// later unlikes are not resurrected on the next start.
+		`DO $$ BEGIN
+			IF to_regclass('idea_likes') IS NOT NULL THEN
+				INSERT INTO idea_reactions (user_id, idea_id, kind, created_at)
+				SELECT user_id, idea_id, 'like', created_at FROM idea_likes
+				ON CONFLICT DO NOTHING;
+				DROP TABLE idea_likes;
+			END IF;
+		END $$`,
+		// View counts moved from ideas to idea_stats.
+		`DO $$ BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'ideas' AND column_name = 'views_count') THEN
+				INSERT INTO idea_stats (idea_id, views_count)
+				SELECT id, views_count FROM ideas
+				ON CONFLICT (idea_id) DO UPDATE SET views_count = GREATEST(idea_stats.views_count, EXCLUDED.views_count);
+				ALTER TABLE ideas DROP COLUMN views_count;
+			END IF;
+		END $$`,
+		`INSERT INTO idea_stats (idea_id) SELECT id FROM ideas ON CONFLICT DO NOTHING`,
+		// Messages sent before conversations existed are grouped into them.
+		// Their history counts as read, so old threads do not show as unread.
+		`DO $$ BEGIN
+			IF EXISTS (SELECT 1 FROM messages WHERE conversation_id IS NULL AND sender_id <> receiver_id) THEN
+				INSERT INTO conversations (user_one_id, user_two_id, created_at)
+				SELECT LEAST(sender_id, receiver_id), GREATEST(sender_id, receiver_id), MIN(created_at)
+				FROM messages
+				WHERE conversation_id IS NULL AND sender_id <> receiver_id
+				GROUP BY 1, 2
+				ON CONFLICT DO NOTHING;
+
+				UPDATE messages m SET conversation_id = c.id
+				FROM conversations c
+				WHERE m.conversation_id IS NULL
+					AND c.user_one_id = LEAST(m.sender_id, m.receiver_id)
+					AND c.user_two_id = GREATEST(m.sender_id, m.receiver_id);
+
+				INSERT INTO conversation_participants (conversation_id, user_id, last_read_message_id, updated_at)
+				SELECT m.conversation_id, p.user_id, MAX(m.id), COALESCE(MAX(m.created_at), CURRENT_TIMESTAMP)
+				FROM messages m CROSS JOIN LATERAL (VALUES (m.sender_id), (m.receiver_id)) p(user_id)
+				WHERE m.conversation_id IS NOT NULL
+				GROUP BY 1, 2
+				ON CONFLICT DO NOTHING;
+			END IF;
+		END $$`,
+		// Content still waiting for review was held through hidden_at.
+		`UPDATE ideas SET held_at = hidden_at, hidden_at = NULL
+			WHERE hidden_at IS NOT NULL AND held_at IS NULL
+				AND EXISTS (SELECT 1 FROM screening_results WHERE content_type = 'idea' AND content_id = ideas.id AND review_status = 'pending')`,
+		`UPDATE messages SET held_at = hidden_at, hidden_at = NULL
+			WHERE hidden_at IS NOT NULL AND held_at IS NULL
+				AND EXISTS (SELECT 1 FROM screening_results WHERE content_type = 'message' AND content_id = messages.id AND review_status = 'pending')`,
+		`UPDATE idea_updates SET held_at = hidden_at, hidden_at = NULL
+			WHERE hidden_at IS NOT NULL AND held_at IS NULL
+				AND EXISTS (SELECT 1 FROM screening_results WHERE content_type = 'update' AND content_id = idea_updates.id AND review_status = 'pending')`,
+		`UPDATE idea_impressions SET session_started_at = last_seen_at WHERE session_started_at IS NULL`,
 	}
 
 	for _, query := range queries {
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A repeat view inside the same dedup window is not counted again. Windows
+// are fixed buckets of this length, so the unique index can enforce them.
+const (
+	userViewDedupWindow      = 30 * time.Minute
+	anonymousViewDedupWindow = 6 * time.Hour
+)
+
+// Views are written by a few workers from a bounded queue; views arriving
+// while it is full are dropped rather than piling up goroutines.
+const (
+	viewQueueSize     = 1024
+	viewRecorderCount = 4
+)
+
+type ideaView struct {
+	ideaID         int
+	viewerID       *string
+	key, role      string
+	referrer, host string
+	bucket         int64
+}
+
+var ideaViews = make(chan ideaView, viewQueueSize)
+
+// recordIdeaView queues a view for StartViewRecorder. Owners viewing their
+// own ideas are not counted.
+func recordIdeaView(c *gin.Context, ideaID int, ownerID string, v viewer) {
+	if v.ID != "" && v.ID == ownerID {
+		return
+	}
+
+	key, role, window := v.ID, v.Role, userViewDedupWindow
+	if key == "" {
+		sum := sha256.Sum256([]byte(c.ClientIP() + "|" + c.Request.UserAgent()))
+		key, role, window = "anon:"+hex.EncodeToString(sum[:16]), "anonymous", anonymousViewDedupWindow
+	}
+	if role == "" {
+		role = "anonymous"
+	}
+	var viewerID *string
+	if v.ID != "" {
+		viewerID = &v.ID
+	}
+
+	referrer := c.Query("ref")
+	if referrer == "" {
+		referrer = c.GetHeader("Referer")
+	}
+	if len(referrer) > 2048 {
+		referrer = referrer[:2048]
+	}
+
+	view := ideaView{
+		ideaID: ideaID, viewerID: viewerID, key: key, role: role,
+		referrer: referrer, host: referrerHost(referrer),
+		bucket: time.Now().Unix() / int64(window/time.Second),
+	}
+	select {
+	case ideaViews <- view:
+	default:
+		fmt.Printf("Dropped idea view for idea %d: view queue is full\n", ideaID)
+	}
+}
+
+// StartViewRecorder writes queued idea views until ctx is cancelled.
+func StartViewRecorder(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < viewRecorderCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case view := <-ideaViews:
+					// A write in progress finishes even during shutdown.
+					if err := insertIdeaView(context.Background(), view); err != nil {
+						fmt.Printf("Failed to record idea view: %v\n", err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func insertIdeaView(ctx context.Context, view ideaView) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// The unique (idea_id, viewer_key, dedup_bucket) index makes concurrent
+	// repeats of the same view lose the race instead of double counting.
+	result, err := tx.Exec(ctx, `
+		INSERT INTO idea_view_events (idea_id, viewer_id, viewer_key, viewer_role, referrer, referrer_host, dedup_bucket)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (idea_id, viewer_key, dedup_bucket) DO NOTHING`,
+		view.ideaID, view.viewerID, view.key, view.role, view.referrer, view.host, view.bucket)
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return nil
+	}
+
+	if err := bumpIdeaStat(ctx, tx, view.ideaID, statViews, 1); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO idea_viewers (idea_id, viewer_key, viewer_id, viewer_role)
+		VALUES ($1, $2, $3, $4) ON CONFLICT (idea_id, viewer_key) DO NOTHING`, view.ideaID, view.key, view.viewerID, view.role)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetIdeaAnalytics returns view analytics for the owner of an idea. Daily
+// numbers come from rollups, so the current day lags by up to one rollup interval.
+func GetIdeaAnalytics(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+	days := parseLimit(c.Query("days"), 30, 365)
+
+	stats := models.IdeaAnalytics{IdeaID: ideaID, Days: days, Daily: []models.DailyViews{}, TopReferrers: []models.ReferrerViews{}}
+	var ownerID string
+	err = database.DB.QueryRow(c, "SELECT i.user_id, COALESCE(s.views_count, 0) FROM ideas i LEFT JOIN idea_stats s ON s.idea_id = i.id WHERE i.id = $1 AND i.deleted_at IS NULL", ideaID).Scan(&ownerID, &stats.Views)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return
+	}
+	if ownerID != userID {
+		utils.RespondWithError(c, http.StatusForbidden, "Only the owner can see analytics")
+		return
+	}
+
+	var likedViewers, signedInViewers int
+	err = database.DB.QueryRow(c, `
+		SELECT
+			(SELECT COUNT(*) FROM idea_viewers WHERE idea_id = $1),
+			(SELECT COUNT(*) FROM idea_viewers WHERE idea_id = $1 AND viewer_role = 'Investor'),
+			(SELECT COUNT(*) FROM idea_viewers WHERE idea_id = $1 AND viewer_id IS NOT NULL),
+			(SELECT COUNT(*) FROM idea_viewers v JOIN idea_reactions l ON l.idea_id = v.idea_id AND l.user_id = v.viewer_id AND l.kind = 'like' WHERE v.idea_id = $1),
+			(SELECT COUNT(*) FROM idea_reactions WHERE idea_id = $1 AND kind = 'like'),
+			(SELECT COALESCE(SUM(investor_views), 0) FROM idea_view_daily WHERE idea_id = $1)`, ideaID).Scan(
+		&stats.UniqueViewers, &stats.InvestorUniqueViewers, &signedInViewers, &likedViewers, &stats.Likes, &stats.InvestorViews)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch analytics")
+		return
+	}
+	if signedInViewers > 0 {
+		stats.LikeConversion = float64(likedViewers) / float64(signedInViewers)
+	}
+
+	rows, err := database.DB.Query(c, `
+		SELECT to_char(d.day, 'YYYY-MM-DD'), COALESCE(v.views, 0), COALESCE(v.unique_viewers, 0), COALESCE(v.investor_views, 0)
+		FROM generate_series(CURRENT_DATE - ($2::int - 1), CURRENT_DATE, INTERVAL '1 day') AS d(day)
+		LEFT JOIN idea_view_daily v ON v.idea_id = $1 AND v.day = d.day::date
+		ORDER BY d.day`, ideaID, days)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch analytics")
+		return
+	}
+	for rows.Next() {
+		var d models.DailyViews
+		if err := rows.Scan(&d.Day, &d.Views, &d.UniqueViewers, &d.InvestorViews); err != nil {
+			continue
+		}
+		stats.Daily = append(stats.Daily, d)
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(c, `
+		SELECT referrer_host, SUM(views) AS total FROM idea_referrer_daily
+		WHERE idea_id = $1 AND day > CURRENT_DATE - $2::int
+		GROUP BY referrer_host ORDER BY total DESC LIMIT 10`, ideaID, days)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch analytics")
+		return
+	}
+	for rows.Next() {
+		var r models.ReferrerViews
+		if err := rows.Scan(&r.Host, &r.Views); err != nil {
+			continue
+		}
+		stats.TopReferrers = append(stats.TopReferrers, r)
+	}
+	rows.Close()
+
+	utils.RespondWithJSON(c, http.StatusOK, stats)
+}
+
+// RollupIdeaViews recomputes daily rollups from the day before the latest
+// rollup onwards. Older days are final because the events table is append-only.
+func RollupIdeaViews(ctx context.Context) error {
+	_, err := database.DB.Exec(ctx, `
+		WITH bounds AS (SELECT COALESCE(MAX(day) - 1, DATE '1970-01-01') AS since FROM idea_view_daily)
+		INSERT INTO idea_view_daily (idea_id, day, views, unique_viewers, investor_views)
+		SELECT e.idea_id, e.created_at::date, COUNT(*), COUNT(DISTINCT e.viewer_key), COUNT(*) FILTER (WHERE e.viewer_role = 'Investor')
+		FROM idea_view_events e, bounds
+		WHERE e.created_at >= bounds.since
+		GROUP BY e.idea_id, e.created_at::date
+		ON CONFLICT (idea_id, day) DO UPDATE SET
+			views = EXCLUDED.views,
+			unique_viewers = EXCLUDED.unique_viewers,
+			investor_views = EXCLUDED.investor_views`)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Exec(ctx, `
+		WITH bounds AS (SELECT COALESCE(MAX(day) - 1, DATE '1970-01-01') AS since FROM idea_referrer_daily)
+		INSERT INTO idea_referrer_daily (idea_id, day, referrer_host, views)
+		SELECT e.idea_id, e.created_at::date, COALESCE(NULLIF(e.referrer_host, ''), 'direct'), COUNT(*)
+		FROM idea_view_events e, bounds
+		WHERE e.created_at >= bounds.since
+		GROUP BY 1, 2, 3
+		ON CONFLICT (idea_id, day, referrer_host) DO UPDATE SET views = EXCLUDED.views`)
+	return err
+}
+
+// StartViewRollups keeps the daily view rollups current.
+func StartViewRollups(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "idea view rollups", interval, RollupIdeaViews)
+}
+
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		// Campaign tags such as ?ref=newsletter are kept as-is.
+		host := strings.ToLower(strings.TrimSpace(referrer))
+		if len(host) > 255 {
+			host = host[:255]
+		}
+		return host
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/middleware"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/storage"
+	"invesa_backend/internal/utils"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	defaultMaxAttachmentBytes = 25 << 20
+	maxAttachmentsPerIdea     = 20
+	attachmentURLTTL          = 15 * time.Minute
+)
+
+// allowedAttachmentTypes lists the sniffed MIME types accepted for upload.
+// The declared Content-Type and file extension are never trusted.
+var allowedAttachmentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         true,
+	"application/vnd.ms-powerpoint":                                             true,
+	"application/msword":                                                        true,
+	"application/vnd.oasis.opendocument.presentation":                           true,
+	"text/plain": true,
+}
+
+var validAttachmentKinds = map[string]bool{
+	"pitch_deck": true,
+	"one_pager":  true,
+	"image":      true,
+	"other":      true,
+}
+
+// UploadAttachment stores a multipart "file" on an idea owned by the caller.
+func UploadAttachment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+
+	if !requireIdeaRole(c, database.DB, ideaID, roleEditor, "Only the owner and editors can add files to this idea") {
+		return
+	}
+
+	var count int
+	if err := database.DB.QueryRow(c, "SELECT COUNT(*) FROM idea_attachments WHERE idea_id=$1", ideaID).Scan(&count); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count >= maxAttachmentsPerIdea {
+		utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("An idea can have at most %d files", maxAttachmentsPerIdea))
+		return
+	}
+
+	maxBytes := maxAttachmentBytes()
+	// Allow some headroom for multipart framing around the file itself.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			utils.RespondWithError(c, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		utils.RespondWithError(c, http.StatusBadRequest, "Missing file")
+		return
+	}
+	if fileHeader.Size > maxBytes {
+		utils.RespondWithError(c, http.StatusRequestEntityTooLarge, "File is too large")
+		return
+	}
+
+	kind := c.DefaultPostForm("kind", "other")
+	if !validAttachmentKinds[kind] {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid attachment kind")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Could not read file")
+		return
+	}
+	defer file.Close()
+
+	detected, err := mimetype.DetectReader(file)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Could not read file")
+		return
+	}
+	contentType := detected.String()
+	if !isAllowedAttachmentType(detected) {
+		utils.RespondWithError(c, http.StatusUnsupportedMediaType, "Unsupported file type: "+contentType)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Could not read file")
+		return
+	}
+
+	attachment := models.Attachment{
+		IdeaID:      ideaID,
+		UploaderID:  userID,
+		FileName:    sanitizeFileName(fileHeader.Filename),
+		ContentType: contentType,
+		SizeBytes:   fileHeader.Size,
+		Kind:        kind,
+	}
+	storageKey := fmt.Sprintf("ideas/%d/%s", ideaID, uuid.New().String())
+
+	hasher := sha256.New()
+	if err := storage.Default.Put(c, storageKey, io.TeeReader(file, hasher), fileHeader.Size, contentType); err != nil {
+		log.Printf("Failed to store attachment: %v", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to store file")
+		return
+	}
+	attachment.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+
+	err = database.DB.QueryRow(c, `
+		INSERT INTO idea_attachments (idea_id, uploader_id, storage_key, file_name, content_type, size_bytes, sha256, kind)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`,
+		attachment.IdeaID, attachment.UploaderID, storageKey, attachment.FileName, attachment.ContentType,
+		attachment.SizeBytes, attachment.SHA256, attachment.Kind).Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		deleteBlobs([]string{storageKey})
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to save file")
+		return
+	}
+
+	if err := withDownloadURL(c, &attachment, storageKey); err != nil {
+		log.Printf("Failed to sign attachment URL: %v", err)
+	}
+
+	utils.LogActivity(c, userID, "UPLOAD_ATTACHMENT", fmt.Sprintf("Uploaded %s to idea %d", attachment.FileName, ideaID))
+
+	utils.RespondWithJSON(c, http.StatusCreated, attachment)
+}
+
+// GetAttachments lists an idea's files with short-lived download URLs.
+func GetAttachments(c *gin.Context) {
+	ideaID := c.Param("id")
+	if _, ok := loadViewableIdea(c, ideaID); !ok {
+		return
+	}
+
+	rows, err := database.DB.Query(c, `
+		SELECT id, idea_id, COALESCE(uploader_id::text, ''), storage_key, file_name, content_type, size_bytes, sha256, kind, created_at
+		FROM idea_attachments WHERE idea_id = $1 ORDER BY created_at ASC`, ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch files")
+		return
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		var a models.Attachment
+		var storageKey string
+		if err := rows.Scan(&a.ID, &a.IdeaID, &a.UploaderID, &storageKey, &a.FileName, &a.ContentType, &a.SizeBytes, &a.SHA256, &a.Kind, &a.CreatedAt); err != nil {
+			continue
+		}
+		if err := withDownloadURL(c, &a, storageKey); err != nil {
+			log.Printf("Failed to sign attachment URL: %v", err)
+			continue
+		}
+		attachments = append(attachments, a)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": attachments})
+}
+
+// DeleteAttachment removes a single file from an idea the caller can edit.
+func DeleteAttachment(c *gin.Context) {
+	ideaID := c.Param("id")
+	attachmentID := c.Param("attachmentId")
+
+	if !requireIdeaRole(c, database.DB, ideaID, roleEditor, "Only the owner and editors can remove files from this idea") {
+		return
+	}
+
+	var storageKey string
+	err := database.DB.QueryRow(c, `
+		DELETE FROM idea_attachments
+		WHERE id = $1 AND idea_id = $2
+		RETURNING storage_key`, attachmentID, ideaID).Scan(&storageKey)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			utils.RespondWithError(c, http.StatusNotFound, "File not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to delete file")
+		return
+	}
+
+	deleteBlobs([]string{storageKey})
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "File deleted"})
+}
+
+// ServeFile streams a blob from the local store after checking its signed URL.
+func ServeFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !storage.Verify(key, c.Query("expires"), c.Query("sig")) {
+		utils.RespondWithError(c, http.StatusForbidden, "Link is invalid or has expired")
+		return
+	}
+
+	var fileName, contentType, sum string
+	var size int64
+	var createdAt time.Time
+	err := database.DB.QueryRow(c,
+		`SELECT a.file_name, a.content_type, a.size_bytes, a.sha256, a.created_at FROM idea_attachments a
+		JOIN ideas i ON i.id = a.idea_id WHERE a.storage_key = $1 AND i.deleted_at IS NULL`, key).Scan(&fileName, &contentType, &size, &sum, &createdAt)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	// Stored blobs never change, so their content hash is a strong validator.
+	c.Header("Cache-Control", "private, max-age=300")
+	if middleware.CheckNotModified(c, `"`+sum+`"`, createdAt) {
+		return
+	}
+
+	blob, err := storage.Default.Open(c, key)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "File not found")
+		return
+	}
+	defer blob.Close()
+
+	c.DataFromReader(http.StatusOK, size, contentType, blob, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", fileName),
+	})
+}
+
+// ideaAttachmentKeys returns the storage keys of an idea's files so they can be
+// removed once the idea row is gone.
+func ideaAttachmentKeys(ctx context.Context, ideaID string) ([]string, error) {
+	rows, err := database.DB.Query(ctx, "SELECT storage_key FROM idea_attachments WHERE idea_id = $1", ideaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// deleteBlobs removes blobs in the background; failures are only logged.
+func deleteBlobs(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		for _, key := range keys {
+			if err := storage.Default.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete blob %s: %v", key, err)
+			}
+		}
+	}()
+}
+
+func withDownloadURL(c *gin.Context, a *models.Attachment, storageKey string) error {
+	expires := time.Now().Add(attachmentURLTTL)
+	url, err := storage.Default.URL(c, storageKey, expires)
+	if err != nil {
+		return err
+	}
+	a.URL = url
+	a.URLExpiresAt = expires
+	return nil
+}
+
+func isAllowedAttachmentType(detected *mimetype.MIME) bool {
+	for allowed := range allowedAttachmentTypes {
+		if detected.Is(allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxFileNameBytes is the longest file name most filesystems accept.
+const maxFileNameBytes = 255
+
+func sanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == '"' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		name = "file"
+	}
+	if len(name) > maxFileNameBytes {
+		// Cut the stem, not the extension, and only between characters.
+		ext := path.Ext(name)
+		if len(ext) > maxFileNameBytes/2 {
+			ext = ""
+		}
+		stem := name[:len(name)-len(ext)]
+		cut := maxFileNameBytes - len(ext)
+		for cut > 0 && !utf8.RuneStart(stem[cut]) {
+			cut--
+		}
+		name = stem[:cut] + ext
+	}
+	return name
+}
+
+func maxAttachmentBytes() int64 {
+	if v, err := strconv.ParseInt(os.Getenv("MAX_ATTACHMENT_BYTES"), 10, 64); err == nil && v > 0 {
+		return v
+	}
+	return defaultMaxAttachmentBytes
+}
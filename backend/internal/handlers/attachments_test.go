@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFileName(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "pitch.pdf", "pitch.pdf"},
+		{"windows path", `C:\Users\me\pitch.pdf`, "pitch.pdf"},
+		{"unix path", "../../etc/passwd", "passwd"},
+		{"control characters and quotes", "pi\"tch\x00.pdf", "pitch.pdf"},
+		{"empty", "", "file"},
+		{"dot", ".", "file"},
+		{"long keeps extension", long + ".pdf", strings.Repeat("a", 251) + ".pdf"},
+		// 2-byte runes: 251 bytes of stem room cuts one in half.
+		{"long multibyte", strings.Repeat("é", 200) + ".pdf", strings.Repeat("é", 125) + ".pdf"},
+		{"long extension", "a." + long, ("a." + long)[:255]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeFileName(tt.in)
+			if got != tt.want {
+				t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if len(got) > maxFileNameBytes || !utf8.ValidString(got) {
+				t.Errorf("sanitizeFileName(%q) = %q: not a valid name of at most %d bytes", tt.in, got, maxFileNameBytes)
+			}
+		})
+	}
+}
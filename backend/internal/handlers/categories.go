@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"errors"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const defaultCategorySlug = "other"
+
+type CategoryRequest struct {
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	SortOrder *int   `json:"sort_order"`
+	IsActive  *bool  `json:"is_active"`
+}
+
+// GetCategories lists the active categories in display order.
+func GetCategories(c *gin.Context) {
+	categories, err := listCategories(c, false)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch categories")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": categories})
+}
+
+// AdminGetCategories lists every category, including inactive ones.
+func AdminGetCategories(c *gin.Context) {
+	categories, err := listCategories(c, true)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch categories")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": categories})
+}
+
+func AdminCreateCategory(c *gin.Context) {
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Name is required")
+		return
+	}
+	slug := slugify(req.Slug)
+	if slug == "" {
+		slug = slugify(req.Name)
+	}
+	if slug == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Slug must contain letters or digits")
+		return
+	}
+
+	category := models.Category{Slug: slug, Name: req.Name, IsActive: true}
+	if req.SortOrder != nil {
+		category.SortOrder = *req.SortOrder
+	}
+	if req.IsActive != nil {
+		category.IsActive = *req.IsActive
+	}
+
+	err := database.DB.QueryRow(c,
+		"INSERT INTO categories (slug, name, sort_order, is_active) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		category.Slug, category.Name, category.SortOrder, category.IsActive).Scan(&category.ID, &category.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(c, http.StatusConflict, "Category slug already exists")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create category")
+		return
+	}
+
+	invalidateAllIdeas(c)
+
+	utils.RespondWithJSON(c, http.StatusCreated, category)
+}
+
+// AdminUpdateCategory applies a partial update. Renaming a slug cascades to ideas.
+func AdminUpdateCategory(c *gin.Context) {
+	id := c.Param("id")
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var slug, name *string
+	if req.Slug != "" {
+		s := slugify(req.Slug)
+		if s == "" {
+			utils.RespondWithError(c, http.StatusBadRequest, "Slug must contain letters or digits")
+			return
+		}
+		slug = &s
+	}
+	if n := strings.TrimSpace(req.Name); n != "" {
+		name = &n
+	}
+
+	var category models.Category
+	err := database.DB.QueryRow(c, `
+		UPDATE categories SET
+			slug = COALESCE($1, slug),
+			name = COALESCE($2, name),
+			sort_order = COALESCE($3, sort_order),
+			is_active = COALESCE($4, is_active)
+		WHERE id = $5
+		RETURNING id, slug, name, sort_order, is_active, created_at`,
+		slug, name, req.SortOrder, req.IsActive, id).Scan(
+		&category.ID, &category.Slug, &category.Name, &category.SortOrder, &category.IsActive, &category.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			utils.RespondWithError(c, http.StatusNotFound, "Category not found")
+			return
+		}
+		if isUniqueViolation(err) {
+			utils.RespondWithError(c, http.StatusConflict, "Category slug already exists")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update category")
+		return
+	}
+
+	invalidateAllIdeas(c)
+
+	utils.RespondWithJSON(c, http.StatusOK, category)
+}
+
+// AdminDeleteCategory removes an unused category. Categories that still have
+// ideas must be deactivated instead so existing ideas keep their label.
+func AdminDeleteCategory(c *gin.Context) {
+	id := c.Param("id")
+
+	var inUse bool
+	err := database.DB.QueryRow(c,
+		"SELECT EXISTS(SELECT 1 FROM ideas i JOIN categories cat ON cat.slug = i.category WHERE cat.id = $1)", id).Scan(&inUse)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if inUse {
+		utils.RespondWithError(c, http.StatusConflict, "Category is in use; deactivate it instead")
+		return
+	}
+
+	result, err := database.DB.Exec(c, "DELETE FROM categories WHERE id = $1", id)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	invalidateAllIdeas(c)
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Category deleted"})
+}
+
+func listCategories(c *gin.Context, includeInactive bool) ([]models.Category, error) {
+	query := "SELECT id, slug, name, sort_order, is_active, created_at FROM categories"
+	if !includeInactive {
+		query += " WHERE is_active"
+	}
+	query += " ORDER BY sort_order, name"
+
+	rows, err := database.DB.Query(c, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var cat models.Category
+		if err := rows.Scan(&cat.ID, &cat.Slug, &cat.Name, &cat.SortOrder, &cat.IsActive, &cat.CreatedAt); err != nil {
+			return nil, err
+		}
+		categories = append(categories, cat)
+	}
+	return categories, rows.Err()
+}
+
+// resolveActiveCategory maps a slug or display name to an active category slug.
+func resolveActiveCategory(c *gin.Context, value string) (string, bool) {
+	slug := slugify(value)
+	if slug == "" {
+		slug = defaultCategorySlug
+	}
+	var active bool
+	err := database.DB.QueryRow(c, "SELECT is_active FROM categories WHERE slug = $1", slug).Scan(&active)
+	if err != nil || !active {
+		return "", false
+	}
+	return slug, true
+}
+
+// slugify lowercases value and collapses every run of non-alphanumeric ASCII
+// characters into a single dash. It mirrors the SQL used by the category
+// migration in database.CreateTables.
+func slugify(value string) string {
+	var b strings.Builder
+	pendingDash := false
+	for _, r := range strings.ToLower(value) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if pendingDash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingDash = false
+			b.WriteRune(r)
+			continue
+		}
+		pendingDash = true
+	}
+	slug := b.String()
+	if len(slug) > 50 {
+		slug = strings.TrimRight(slug[:50], "-")
+	}
+	return slug
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"invesa_backend/internal/database"
 	"invesa_backend/internal/models"
 	"invesa_backend/internal/utils"
 	"net/http"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 func SendMessage(c *gin.Context) {
@@ -15,34 +20,121 @@ func SendMessage(c *gin.Context) {
 		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	msg.SenderID = c.GetString("user_id")
 
-	_, err := database.DB.Exec(c, "INSERT INTO messages (sender_id, receiver_id, content) VALUES ($1, $2, $3)", msg.SenderID, msg.ReceiverID, msg.Content)
+	result, err := sendMessage(c, c.ClientIP(), &msg)
 	if err != nil {
+		var invalid messageError
+		if errors.As(err, &invalid) {
+			utils.RespondWithError(c, http.StatusBadRequest, invalid.Error())
+			return
+		}
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to send message")
 		return
 	}
+	if result.rejected() {
+		respondRejected(c, result)
+		return
+	}
+	if result.held() {
+		utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Message held for review", "held_for_review": true})
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Message sent", "id": msg.ID})
+}
+
+// messageError is a message the sender has to fix; its text is shown to them.
+type messageError string
+
+func (e messageError) Error() string { return string(e) }
+
+// sendMessage validates, screens and stores a message in its conversation,
+// then pushes it to both participants' chat sockets. Both REST and the chat
+// socket send through it. Invalid messages fail with a messageError;
+// rejected ones are not stored and the caller reports them. ip is the
+// sender's address, for screening and the activity log.
+func sendMessage(ctx context.Context, ip string, msg *models.Message) (screened, error) {
+	msg.Content = strings.TrimSpace(msg.Content)
+	switch {
+	case msg.ReceiverID == "" || uuid.Validate(msg.ReceiverID) != nil:
+		return screened{}, messageError("Invalid receiver")
+	case msg.ReceiverID == msg.SenderID:
+		return screened{}, messageError("Cannot send a message to yourself")
+	case msg.Content == "":
+		return screened{}, messageError("Message cannot be empty")
+	case utf8.RuneCountInString(msg.Content) > maxChatMessageRunes:
+		return screened{}, messageError("Message is too long")
+	}
+	var exists bool
+	if err := database.DB.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", msg.ReceiverID).Scan(&exists); err != nil {
+		return screened{}, err
+	}
+	if !exists {
+		return screened{}, messageError("Invalid receiver")
+	}
+
+	result := screenFrom(ctx, ip, reportMessage, msg.SenderID, msg.Content)
+	if result.rejected() {
+		return result, nil
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback(ctx)
+
+	if msg.ConversationID, err = conversationFor(ctx, tx, msg.SenderID, msg.ReceiverID); err != nil {
+		if isForeignKeyViolation(err) {
+			// The receiver was deleted since the check above.
+			return result, messageError("Invalid receiver")
+		}
+		return result, err
+	}
+	// Held messages are not delivered until a moderator approves them.
+	err = tx.QueryRow(ctx, "INSERT INTO messages (sender_id, receiver_id, conversation_id, content, held_at) VALUES ($1, $2, $3, $4, CASE WHEN $5 THEN CURRENT_TIMESTAMP END) RETURNING id, created_at",
+		msg.SenderID, msg.ReceiverID, msg.ConversationID, msg.Content, result.held()).Scan(&msg.ID, &msg.CreatedAt)
+	if err != nil {
+		return result, err
+	}
+	if !result.held() {
+		if err := bumpConversation(ctx, tx, *msg); err != nil {
+			return result, err
+		}
+	}
+	if err := result.save(ctx, tx, &msg.ID); err != nil {
+		return result, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return result, err
+	}
 
 	// Log activity
-	utils.LogActivity(c, msg.SenderID, "SEND_MESSAGE", "Sent a message to "+msg.ReceiverID)
+	utils.LogActivityFrom(ip, msg.SenderID, "SEND_MESSAGE", "Sent a message to "+msg.ReceiverID)
 
-	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Message sent"})
+	if !result.held() {
+		publishMessage(ctx, *msg)
+	}
+	return result, nil
 }
 
+// GetMessages returns the caller's messages with the user in ?with=.
 func GetMessages(c *gin.Context) {
-	user1 := c.Query("user1")
-	user2 := c.Query("user2")
+	user1 := c.GetString("user_id")
+	user2 := c.Query("with")
 	limit := parseLimit(c.Query("limit"), 50, 200)
 	offset := parseOffset(c.Query("offset"))
 
-	if user1 == "" || user2 == "" {
-		utils.RespondWithError(c, http.StatusBadRequest, "Missing user1 or user2")
+	if user2 == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Missing with")
 		return
 	}
 
 	rows, err := database.DB.Query(c, `
-		SELECT id, sender_id, receiver_id, content, created_at 
-		FROM messages 
-		WHERE (sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1)
+		SELECT id, sender_id, receiver_id, COALESCE(conversation_id, 0), content, created_at, delivered_at, read_at
+		FROM messages
+		WHERE ((sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1))
+			AND hidden_at IS NULL AND held_at IS NULL
 		ORDER BY created_at ASC
 		LIMIT $3 OFFSET $4`, user1, user2, limit, offset)
 
@@ -55,7 +147,7 @@ func GetMessages(c *gin.Context) {
 	var messages []models.Message
 	for rows.Next() {
 		var m models.Message
-		if err := rows.Scan(&m.ID, &m.SenderID, &m.ReceiverID, &m.Content, &m.CreatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.SenderID, &m.ReceiverID, &m.ConversationID, &m.Content, &m.CreatedAt, &m.DeliveredAt, &m.ReadAt); err != nil {
 			continue
 		}
 		messages = append(messages, m)
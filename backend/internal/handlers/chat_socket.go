@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/middleware"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/realtime"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// Chat socket events. Clients send "send", "ack" and "pong"; the server
+// pushes the rest.
+const (
+	chatEventReady      = "ready"      // {"user_id", "online": [partner ids], "last_id"}
+	chatEventMessage    = "message"    // A models.Message, to sender and receiver
+	chatEventSent       = "sent"       // {"client_id", "message"}, to the sending socket
+	chatEventHeld       = "held"       // {"client_id"}: held for review, not delivered
+	chatEventError      = "error"      // {"client_id", "error", "reasons"}
+	chatEventDelivered  = "delivered"  // {"user_id", "message_ids", "delivered_at"}, to the sender
+	chatEventRead       = "read"       // {"conversation_id", "user_id", "message_ids", "read_at"}, to both participants
+	chatEventPresence   = "presence"   // {"user_id", "online"}, to conversation partners
+	chatEventIncomplete = "incomplete" // {"last_id"}: resume hit its limit; reload over REST
+	chatEventPing       = "ping"
+
+	chatEventSend = "send" // {"client_id", "receiver_id", "content"}
+	chatEventAck  = "ack"  // {"message_id"}: everything received up to this id
+	chatEventPong = "pong"
+)
+
+const (
+	chatPingInterval = 25 * time.Second
+	// Clients answer pings with pong, so a silent socket is gone.
+	chatReadTimeout  = 2 * chatPingInterval
+	chatWriteTimeout = 10 * time.Second
+
+	maxChatFrameBytes   = 64 << 10
+	maxChatMessageRunes = 5000
+	// maxChatResume caps how many missed messages a reconnect replays.
+	maxChatResume  = 1000
+	chatResumePage = 200
+)
+
+// chatIncoming is a frame sent by the client.
+type chatIncoming struct {
+	Type       string `json:"type"`
+	ClientID   string `json:"client_id"`
+	ReceiverID string `json:"receiver_id"`
+	Content    string `json:"content"`
+	MessageID  int    `json:"message_id"`
+}
+
+// ChatSocket upgrades to a WebSocket that pushes new messages, delivery acks
+// and presence, replacing polling of GET /messages. Browsers authenticate with
+// the "bearer, <jwt>" subprotocol. ?after_id= resumes after the last message
+// the client has, replaying what it missed while disconnected.
+func ChatSocket(allowedOrigins []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("user_id")
+		afterID, _ := strconv.Atoi(c.Query("after_id"))
+
+		server := websocket.Server{
+			Handshake: func(config *websocket.Config, req *http.Request) error {
+				if origin := req.Header.Get("Origin"); origin != "" && !slices.Contains(allowedOrigins, origin) {
+					return fmt.Errorf("origin %q not allowed", origin)
+				}
+				// Echo the auth subprotocol; the token itself stays private.
+				if slices.Contains(config.Protocol, "bearer") {
+					config.Protocol = []string{"bearer"}
+				} else {
+					config.Protocol = nil
+				}
+				return nil
+			},
+			Handler: func(ws *websocket.Conn) {
+				serveChatSocket(c, ws, userID, afterID)
+			},
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// chatSocket is one connected client. The writer goroutine owns every write
+// and the resume position; the reader hands it replies through out.
+type chatSocket struct {
+	ws     *websocket.Conn
+	userID string
+	out    chan realtime.Event
+	lastID int // Highest message id sent to the client; resume continues after it
+	// replayed lists, oldest first, the ids resume sent that may still arrive
+	// as live events. Anything else is pushed, however old its id.
+	replayed []int
+	ip       string // Client address, for screening and the activity log
+}
+
+func serveChatSocket(c *gin.Context, ws *websocket.Conn, userID string, afterID int) {
+	defer ws.Close()
+	// gin recycles c once the handler returns, and its Done is nil, so the
+	// socket runs on its own context, cancelled when the writer stops.
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	// The HTTP server's timeouts still apply to the hijacked connection.
+	ws.SetDeadline(time.Time{})
+	ws.MaxPayloadBytes = maxChatFrameBytes
+
+	// Partners only hear about the user's first connection anywhere.
+	wasOnline := realtime.Default.Online(userID)
+	sub, _ := realtime.Default.Subscribe(userID)
+	defer func() {
+		if sub.Close() && !realtime.Default.Online(userID) {
+			announcePresence(context.Background(), userID, false)
+		}
+	}()
+
+	partners, err := chatPartners(ctx, userID)
+	if err != nil {
+		fmt.Printf("Chat partners error: %v\n", err)
+	}
+	online := []string{}
+	for _, id := range partners {
+		if realtime.Default.Online(id) {
+			online = append(online, id)
+		}
+	}
+	// Without after_id the client loads history over REST, so only messages
+	// from now on are pushed.
+	resuming := afterID > 0
+	if !resuming {
+		if err := database.DB.QueryRow(ctx, "SELECT COALESCE(MAX(id), 0) FROM messages").Scan(&afterID); err != nil {
+			fmt.Printf("Chat resume position error: %v\n", err)
+			return
+		}
+	}
+
+	s := &chatSocket{ws: ws, userID: userID, out: make(chan realtime.Event, 16), lastID: afterID, ip: c.ClientIP()}
+	if !s.write(realtime.Event{Type: chatEventReady, Data: gin.H{"user_id": userID, "online": online, "last_id": afterID}}) {
+		return
+	}
+	if !wasOnline {
+		announcePresence(ctx, userID, true)
+	}
+	if resuming && !s.resume(ctx) {
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.read(ctx)
+	}()
+	// Closing the connection unblocks the reader's Receive; wait for it so
+	// nothing outlives the handler.
+	defer func() {
+		cancel()
+		ws.Close()
+		<-done
+	}()
+
+	ping := time.NewTicker(chatPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-sub.Dropped():
+			// Events were lost; the client reconnects and resumes.
+			return
+		case e := <-sub.C:
+			if e.Type == realtime.EventSync {
+				if !s.resume(ctx) {
+					return
+				}
+				continue
+			}
+			if e.Type == chatEventMessage {
+				id := chatMessageID(e)
+				if s.wasReplayed(id) {
+					continue
+				}
+				s.lastID = max(s.lastID, id)
+			}
+			if !s.write(e) {
+				return
+			}
+		case e := <-s.out:
+			if !s.write(e) {
+				return
+			}
+		case <-ping.C:
+			if !s.write(realtime.Event{Type: chatEventPing}) {
+				return
+			}
+		}
+	}
+}
+
+func (s *chatSocket) write(e realtime.Event) bool {
+	s.ws.SetWriteDeadline(time.Now().Add(chatWriteTimeout))
+	return websocket.JSON.Send(s.ws, e) == nil
+}
+
+// reply queues an event for this socket only. It gives up once ctx is
+// cancelled, which happens when the writer exits, or when the writer has
+// not taken the event within chatWriteTimeout.
+func (s *chatSocket) reply(ctx context.Context, e realtime.Event) {
+	select {
+	case s.out <- e:
+	case <-ctx.Done():
+	case <-time.After(chatWriteTimeout):
+	}
+}
+
+// read handles client frames until the socket closes or goes quiet.
+func (s *chatSocket) read(ctx context.Context) {
+	for {
+		s.ws.SetReadDeadline(time.Now().Add(chatReadTimeout))
+		var in chatIncoming
+		if err := websocket.JSON.Receive(s.ws, &in); err != nil {
+			return
+		}
+		switch in.Type {
+		case chatEventSend:
+			s.send(ctx, in)
+		case chatEventAck:
+			if err := ackMessages(ctx, s.userID, in.MessageID); err != nil {
+				fmt.Printf("Chat ack error: %v\n", err)
+			}
+		case chatEventPong:
+		default:
+			s.reply(ctx, realtime.Event{Type: chatEventError, Data: gin.H{"client_id": in.ClientID, "error": "Unknown event type"}})
+		}
+	}
+}
+
+// send stores a message written on the socket, like POST /messages.
+func (s *chatSocket) send(ctx context.Context, in chatIncoming) {
+	fail := func(msg string) {
+		s.reply(ctx, realtime.Event{Type: chatEventError, Data: gin.H{"client_id": in.ClientID, "error": msg}})
+	}
+	// The socket outlives the suspension check made when it connected.
+	if until, ok := middleware.SuspendedUntil(ctx, s.userID); ok {
+		s.reply(ctx, realtime.Event{Type: chatEventError, Data: gin.H{"client_id": in.ClientID, "error": "Account suspended", "suspended_until": until}})
+		return
+	}
+
+	msg := models.Message{SenderID: s.userID, ReceiverID: in.ReceiverID, Content: in.Content}
+	result, err := sendMessage(ctx, s.ip, &msg)
+	var invalid messageError
+	switch {
+	case errors.As(err, &invalid):
+		fail(invalid.Error())
+	case err != nil:
+		fmt.Printf("Chat send error: %v\n", err)
+		fail("Failed to send message")
+	case result.rejected():
+		if err := result.save(ctx, database.DB, nil); err != nil {
+			fmt.Printf("Failed to save screening result: %v\n", err)
+		}
+		s.reply(ctx, realtime.Event{Type: chatEventError, Data: gin.H{
+			"client_id": in.ClientID, "error": "Your message was rejected by our content filters", "reasons": result.Messages(),
+		}})
+	case result.held():
+		s.reply(ctx, realtime.Event{Type: chatEventHeld, Data: gin.H{"client_id": in.ClientID}})
+	default:
+		s.reply(ctx, realtime.Event{Type: chatEventSent, Data: gin.H{"client_id": in.ClientID, "message": msg}})
+	}
+}
+
+// resume replays the messages after lastID, oldest first. It reports false
+// if the socket failed.
+func (s *chatSocket) resume(ctx context.Context) bool {
+	for sent := 0; sent < maxChatResume; {
+		messages, err := messagesAfter(ctx, s.userID, s.lastID, chatResumePage)
+		if err != nil {
+			fmt.Printf("Chat resume error: %v\n", err)
+			return false
+		}
+		for _, m := range messages {
+			if !s.write(realtime.Event{Type: chatEventMessage, Data: m}) {
+				return false
+			}
+			s.lastID = max(s.lastID, m.ID)
+			if len(s.replayed) == maxChatResume {
+				s.replayed = s.replayed[1:]
+			}
+			s.replayed = append(s.replayed, m.ID)
+		}
+		sent += len(messages)
+		if len(messages) < chatResumePage {
+			return true
+		}
+	}
+	return s.write(realtime.Event{Type: chatEventIncomplete, Data: gin.H{"last_id": s.lastID}})
+}
+
+// wasReplayed reports whether resume already sent message id, and forgets
+// it so a later event for the same id is pushed.
+func (s *chatSocket) wasReplayed(id int) bool {
+	i := slices.Index(s.replayed, id)
+	if i < 0 {
+		return false
+	}
+	s.replayed = slices.Delete(s.replayed, i, i+1)
+	return true
+}
+
+func messagesAfter(ctx context.Context, userID string, afterID, limit int) ([]models.Message, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT id, sender_id, receiver_id, COALESCE(conversation_id, 0), content, created_at, delivered_at, read_at
+		FROM messages
+		WHERE (sender_id = $1 OR receiver_id = $1) AND id > $2 AND hidden_at IS NULL AND held_at IS NULL
+		ORDER BY id
+		LIMIT $3`, userID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var m models.Message
+		if err := rows.Scan(&m.ID, &m.SenderID, &m.ReceiverID, &m.ConversationID, &m.Content, &m.CreatedAt, &m.DeliveredAt, &m.ReadAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// ackMessages marks everything the user received up to messageID as
+// delivered and tells the senders.
+func ackMessages(ctx context.Context, userID string, messageID int) error {
+	rows, err := database.DB.Query(ctx, `
+		UPDATE messages SET delivered_at = CURRENT_TIMESTAMP
+		WHERE receiver_id = $1 AND id <= $2 AND delivered_at IS NULL AND hidden_at IS NULL AND held_at IS NULL
+		RETURNING id, sender_id, delivered_at`, userID, messageID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	bySender := map[string][]int{}
+	var deliveredAt time.Time
+	for rows.Next() {
+		var id int
+		var senderID string
+		if err := rows.Scan(&id, &senderID, &deliveredAt); err != nil {
+			return err
+		}
+		bySender[senderID] = append(bySender[senderID], id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for senderID, ids := range bySender {
+		realtime.Default.Publish(ctx, []string{senderID}, realtime.Event{Type: chatEventDelivered, Data: gin.H{
+			"user_id": userID, "message_ids": ids, "delivered_at": deliveredAt,
+		}})
+	}
+	return nil
+}
+
+// publishMessage pushes a stored, visible message to both participants.
+func publishMessage(ctx context.Context, m models.Message) {
+	realtime.Default.Publish(ctx, []string{m.SenderID, m.ReceiverID}, realtime.Event{Type: chatEventMessage, Data: m})
+}
+
+// announcePresence tells the user's conversation partners that they came
+// online or went offline.
+func announcePresence(ctx context.Context, userID string, online bool) {
+	partners, err := chatPartners(ctx, userID)
+	if err != nil {
+		fmt.Printf("Chat partners error: %v\n", err)
+		return
+	}
+	if len(partners) > 0 {
+		realtime.Default.Publish(ctx, partners, realtime.Event{Type: chatEventPresence, Data: gin.H{"user_id": userID, "online": online}})
+	}
+}
+
+// chatPartners lists everyone the user has a conversation with.
+func chatPartners(ctx context.Context, userID string) ([]string, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT CASE WHEN c.user_one_id = $1 THEN c.user_two_id ELSE c.user_one_id END
+		FROM conversation_participants p
+		JOIN conversations c ON c.id = p.conversation_id
+		WHERE p.user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partners []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		partners = append(partners, id)
+	}
+	return partners, rows.Err()
+}
+
+// chatMessageID returns the id of a message event, whether it was published
+// here or relayed as JSON from another instance.
+func chatMessageID(e realtime.Event) int {
+	switch data := e.Data.(type) {
+	case models.Message:
+		return data.ID
+	case json.RawMessage:
+		var m struct {
+			ID int `json:"id"`
+		}
+		if json.Unmarshal(data, &m) == nil {
+			return m.ID
+		}
+	}
+	return 0
+}
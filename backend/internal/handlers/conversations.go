@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/realtime"
+	"invesa_backend/internal/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// conversationFor returns the conversation between two users, creating it
+// with both participants on their first message.
+func conversationFor(ctx context.Context, db queryRower, userID, otherID string) (int, error) {
+	var id int
+	err := db.QueryRow(ctx, `
+		WITH conv AS (
+			INSERT INTO conversations (user_one_id, user_two_id)
+			VALUES (LEAST($1::uuid, $2::uuid), GREATEST($1::uuid, $2::uuid))
+			ON CONFLICT (user_one_id, user_two_id) DO UPDATE SET user_one_id = EXCLUDED.user_one_id
+			RETURNING id
+		), participants AS (
+			INSERT INTO conversation_participants (conversation_id, user_id)
+			SELECT conv.id, p.user_id FROM conv, unnest(ARRAY[$1::uuid, $2::uuid]) AS p(user_id)
+			ON CONFLICT DO NOTHING
+		)
+		SELECT id FROM conv`, userID, otherID).Scan(&id)
+	return id, err
+}
+
+// bumpConversation moves a conversation to the top of both participants'
+// lists and counts the message as unread for its receiver. Call it when the
+// message becomes visible, in the same transaction.
+func bumpConversation(ctx context.Context, db execer, m models.Message) error {
+	_, err := db.Exec(ctx, `
+		UPDATE conversation_participants SET
+			updated_at = CURRENT_TIMESTAMP,
+			unread_count = unread_count + CASE WHEN user_id = $2 AND last_read_message_id < $3 THEN 1 ELSE 0 END
+		WHERE conversation_id = $1`, m.ConversationID, m.ReceiverID, m.ID)
+	return err
+}
+
+// GetConversations lists the caller's conversations, most recent first, with
+// the other participant, a preview of the latest message and unread counts.
+func GetConversations(c *gin.Context) {
+	userID := c.GetString("user_id")
+	limit := parseLimit(c.Query("limit"), 20, 100)
+	offset := parseOffset(c.Query("offset"))
+
+	// Conversations whose messages are all held or hidden are left out.
+	rows, err := database.DB.Query(c, `
+		SELECT c.id, u.id, COALESCE(u.username, ''), COALESCE(u.full_name, ''), u.avatar_url,
+			lm.id, lm.sender_id, LEFT(lm.content, 200), lm.created_at, p.unread_count, p.updated_at
+		FROM conversation_participants p
+		JOIN conversations c ON c.id = p.conversation_id
+		JOIN users u ON u.id = CASE WHEN c.user_one_id = p.user_id THEN c.user_two_id ELSE c.user_one_id END
+		CROSS JOIN LATERAL (
+			SELECT id, sender_id::text, content, created_at FROM messages
+			WHERE conversation_id = c.id AND hidden_at IS NULL AND held_at IS NULL
+			ORDER BY id DESC
+			LIMIT 1
+		) lm
+		WHERE p.user_id = $1
+		ORDER BY p.updated_at DESC, c.id DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		fmt.Printf("GetConversations error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch conversations")
+		return
+	}
+	defer rows.Close()
+
+	items := []models.Conversation{}
+	for rows.Next() {
+		var conv models.Conversation
+		u, m := &conv.Counterpart, &conv.LastMessage
+		if err := rows.Scan(&conv.ID, &u.ID, &u.Username, &u.FullName, &u.AvatarURL,
+			&m.ID, &m.SenderID, &m.Content, &m.CreatedAt, &conv.UnreadCount, &conv.UpdatedAt); err != nil {
+			continue
+		}
+		items = append(items, conv)
+	}
+	rows.Close()
+
+	var unread int
+	if err := database.DB.QueryRow(c, "SELECT COALESCE(SUM(unread_count), 0) FROM conversation_participants WHERE user_id = $1", userID).Scan(&unread); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch conversations")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": items, "unread_count": unread, "limit": limit, "offset": offset})
+}
+
+// MarkConversationRead marks the caller's received messages as read up to
+// message_id, or all of them when the body is empty, and tells the sender.
+func MarkConversationRead(c *gin.Context) {
+	userID := c.GetString("user_id")
+	conversationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid conversation id")
+		return
+	}
+	var req struct {
+		MessageID int `json:"message_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to mark conversation read")
+		return
+	}
+	defer tx.Rollback(c)
+
+	var lastRead int
+	err = tx.QueryRow(c, "SELECT last_read_message_id FROM conversation_participants WHERE conversation_id = $1 AND user_id = $2 FOR UPDATE",
+		conversationID, userID).Scan(&lastRead)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondWithError(c, http.StatusNotFound, "Conversation not found")
+		return
+	} else if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to mark conversation read")
+		return
+	}
+
+	// Reading past the latest message would also mark future ones as read.
+	var upTo int
+	if err := tx.QueryRow(c, "SELECT COALESCE(MAX(id), 0) FROM messages WHERE conversation_id = $1", conversationID).Scan(&upTo); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to mark conversation read")
+		return
+	}
+	if req.MessageID > 0 {
+		upTo = min(upTo, req.MessageID)
+	}
+
+	rows, err := tx.Query(c, `
+		UPDATE messages SET read_at = CURRENT_TIMESTAMP, delivered_at = COALESCE(delivered_at, CURRENT_TIMESTAMP)
+		WHERE conversation_id = $1 AND receiver_id = $2 AND id > $3 AND id <= $4 AND read_at IS NULL AND hidden_at IS NULL AND held_at IS NULL
+		RETURNING id, sender_id::text, read_at`, conversationID, userID, lastRead, upTo)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to mark conversation read")
+		return
+	}
+	var ids []int
+	var senderID string
+	var readAt *time.Time
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id, &senderID, &readAt); err != nil {
+			rows.Close()
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to mark conversation read")
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to mark conversation read")
+		return
+	}
+
+	// Recounting keeps unread_count exact even if messages were hidden after
+	// they were counted.
+	var unread int
+	err = tx.QueryRow(c, `
+		UPDATE conversation_participants SET
+			last_read_message_id = GREATEST(last_read_message_id, $3),
+			unread_count = (
+				SELECT COUNT(*) FROM messages
+				WHERE conversation_id = $1 AND receiver_id = $2 AND hidden_at IS NULL AND held_at IS NULL AND id > GREATEST(conversation_participants.last_read_message_id, $3)
+			)
+		WHERE conversation_id = $1 AND user_id = $2
+		RETURNING last_read_message_id, unread_count`, conversationID, userID, upTo).Scan(&lastRead, &unread)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to mark conversation read")
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to mark conversation read")
+		return
+	}
+
+	// The reader's other tabs update their badges; the sender sees read receipts.
+	if len(ids) > 0 {
+		realtime.Default.Publish(c, []string{senderID, userID}, realtime.Event{Type: chatEventRead, Data: gin.H{
+			"conversation_id": conversationID, "user_id": userID, "message_ids": ids, "read_at": readAt,
+		}})
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{
+		"conversation_id":      conversationID,
+		"last_read_message_id": lastRead,
+		"unread_count":         unread,
+	})
+}
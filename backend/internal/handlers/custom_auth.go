@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"invesa_backend/internal/database"
 	"invesa_backend/internal/utils"
 	"net/http"
@@ -97,8 +98,9 @@ func Login(c *gin.Context) {
 	}
 
 	var userID, username, role, passwordHash string
+	var suspendedUntil *time.Time
 	err := database.DB.QueryRow(context.Background(),
-		"SELECT id, username, role, password_hash FROM users WHERE email=$1", input.Email).Scan(&userID, &username, &role, &passwordHash)
+		"SELECT id, username, role, password_hash, CASE WHEN suspended_until > CURRENT_TIMESTAMP THEN suspended_until END FROM users WHERE email=$1", input.Email).Scan(&userID, &username, &role, &passwordHash, &suspendedUntil)
 
 	if err != nil {
 		utils.RespondWithError(c, http.StatusUnauthorized, "Invalid credentials")
@@ -110,6 +112,11 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if suspendedUntil != nil {
+		utils.RespondWithError(c, http.StatusForbidden, "Account suspended until "+suspendedUntil.UTC().Format(time.RFC1123))
+		return
+	}
+
 	token, err := utils.GenerateToken(userID)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to generate token")
@@ -216,10 +223,12 @@ func UpdateProfile(c *gin.Context) {
 	}
 
 	var input struct {
-		Username string `json:"username"`
-		FullName string `json:"full_name"`
-		Bio      string `json:"bio"`
-		Avatar   string `json:"avatar_url"`
+		Username        string   `json:"username"`
+		FullName        string   `json:"full_name"`
+		Bio             string   `json:"bio"`
+		Avatar          string   `json:"avatar_url"`
+		InterestSectors []string `json:"interest_sectors"`
+		InterestStages  []string `json:"interest_stages"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -227,14 +236,30 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// Interests are optional; nil leaves the stored values untouched.
+	if input.InterestSectors != nil {
+		sectors, err := normalizeTags(input.InterestSectors)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("Choose at most %d interest sectors", maxTagsPerIdea))
+			return
+		}
+		input.InterestSectors = sectors
+	}
+	for _, stage := range input.InterestStages {
+		if !validStages[stage] {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid stage: "+stage)
+			return
+		}
+	}
+
 	// Update query
 	// Note: We might want to handle partial updates more dynamically, but for now this works if frontend sends all fields
 	// Or we can use COALESCE in SQL or build query dynamically.
 	// For simplicity in this fix, let's assume standard update.
 
 	_, err := database.DB.Exec(context.Background(),
-		"UPDATE users SET full_name=$1, bio=$2, avatar_url=$3 WHERE id=$4",
-		input.FullName, input.Bio, input.Avatar, userId)
+		"UPDATE users SET full_name=$1, bio=$2, avatar_url=$3, interest_sectors=COALESCE($4, interest_sectors), interest_stages=COALESCE($5, interest_stages) WHERE id=$6",
+		input.FullName, input.Bio, input.Avatar, input.InterestSectors, input.InterestStages, userId)
 
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update profile")
@@ -252,3 +277,52 @@ func Logout(c *gin.Context) {
 	}
 	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
+
+// AdminSetUserVerified marks a user as verified (or not). Verified investors
+// can see investors-only ideas.
+func AdminSetUserVerified(c *gin.Context) {
+	var input struct {
+		Verified bool `json:"verified"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := database.DB.Exec(context.Background(),
+		"UPDATE users SET is_verified=$1 WHERE id=$2", input.Verified, c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "User updated", "verified": input.Verified})
+}
+
+// AdminSetModerator grants or revokes access to the moderation queue.
+func AdminSetModerator(c *gin.Context) {
+	var input struct {
+		IsModerator bool `json:"is_moderator"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := database.DB.Exec(context.Background(),
+		"UPDATE users SET is_moderator=$1 WHERE id=$2", input.IsModerator, c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "User updated", "is_moderator": input.IsModerator})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"invesa_backend/internal/database"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBErr  error
+)
+
+// requireTestDB points database.DB at TEST_DATABASE_URL, creating the schema
+// on first use. Tests that need Postgres are skipped without it.
+func requireTestDB(t *testing.T) {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	testDBOnce.Do(func() {
+		database.DB, testDBErr = pgxpool.New(context.Background(), url)
+		if testDBErr == nil {
+			testDBErr = database.CreateTables()
+		}
+	})
+	if testDBErr != nil {
+		t.Fatalf("test database: %v", testDBErr)
+	}
+}
+
+// createTestUser inserts a user that is deleted, with everything it owns,
+// when the test ends.
+func createTestUser(t *testing.T) string {
+	t.Helper()
+	id := uuid.New().String()
+	_, err := database.DB.Exec(context.Background(),
+		"INSERT INTO users (id, username, email, password_hash) VALUES ($1, $1, $1 || '@example.com', '')", id)
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() {
+		database.DB.Exec(context.Background(), "DELETE FROM users WHERE id = $1", id)
+	})
+	return id
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var validStages = map[string]bool{
+	"idea":          true,
+	"prototype":     true,
+	"mvp":           true,
+	"early_revenue": true,
+	"growth":        true,
+}
+
+// Feed scoring weights. A followed founder outweighs a declared sector, which
+// outweighs a declared stage; likes in a category grow logarithmically.
+const (
+	feedWeightFollow       = 4.0
+	feedWeightSector       = 3.0
+	feedWeightStage        = 2.0
+	feedWeightLikedCat     = 1.5
+	feedWeightDismissedCat = 1.0
+	feedWeightSeen         = 1.5
+	feedWeightTrending     = 10.0
+	feedWeightRecency      = 2.0
+)
+
+// feedSession groups a user's feed requests: an idea counts as seen once per
+// session, and only impressions from earlier sessions lower its score, so
+// paging through the feed does not reshuffle it. A session lasts this long
+// from the idea's first impression in it, however often it is shown again.
+const feedSession = "30 minutes"
+
+type FeedItem struct {
+	models.Idea
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+type FeedResponse struct {
+	Items  []FeedItem `json:"items"`
+	Limit  int        `json:"limit"`
+	Offset int        `json:"offset"`
+}
+
+type feedSignals struct {
+	followsAuthor  bool
+	authorName     string
+	sectorMatch    bool
+	stageMatch     bool
+	likedInCat     int
+	dismissedInCat int
+}
+
+// GetFeed ranks recent ideas for the authenticated user from their declared
+// interests, liked categories, followed founders and feed history.
+func GetFeed(c *gin.Context) {
+	userID := c.GetString("user_id")
+	limit := parseLimit(c.Query("limit"), 20, 100)
+	offset := parseOffset(c.Query("offset"))
+
+	query := fmt.Sprintf(`
+		WITH me AS (
+			SELECT interest_sectors, interest_stages, role = 'Investor' AND COALESCE(is_verified, FALSE) AS is_investor
+			FROM users WHERE id = $1
+		),
+		liked AS (
+			SELECT i.category, COUNT(*) AS cnt FROM idea_reactions l JOIN ideas i ON i.id = l.idea_id
+			WHERE l.user_id = $1 AND l.kind = 'like' GROUP BY i.category
+		),
+		dismissed AS (
+			SELECT i.category, COUNT(*) AS cnt FROM idea_dismissals d JOIN ideas i ON i.id = d.idea_id
+			WHERE d.user_id = $1 GROUP BY i.category
+		),
+		candidates AS (
+			SELECT ideas.id,
+				EXISTS(SELECT 1 FROM user_follows f WHERE f.follower_id = $1 AND f.followed_id = ideas.user_id) AS follows_author,
+				ideas.category = ANY(me.interest_sectors)
+					OR EXISTS(SELECT 1 FROM category_follows cf WHERE cf.user_id = $1 AND cf.category_slug = ideas.category) AS sector_match,
+				ideas.stage <> '' AND ideas.stage = ANY(me.interest_stages) AS stage_match,
+				COALESCE(liked.cnt, 0) AS liked_in_cat,
+				COALESCE(dismissed.cnt, 0) AS dismissed_in_cat,
+				COALESCE(imp.seen_count - CASE WHEN imp.session_started_at >= NOW() - INTERVAL '%[11]s' THEN 1 ELSE 0 END, 0) AS seen_count,
+				COALESCE(r.trending_score, 0) AS trending_score,
+				EXTRACT(EPOCH FROM (NOW() - ideas.created_at)) / 86400 AS age_days
+			FROM ideas
+			CROSS JOIN me
+			LEFT JOIN liked ON liked.category = ideas.category
+			LEFT JOIN dismissed ON dismissed.category = ideas.category
+			LEFT JOIN idea_impressions imp ON imp.user_id = $1 AND imp.idea_id = ideas.id
+			LEFT JOIN idea_rankings r ON r.idea_id = ideas.id
+			WHERE ideas.user_id <> $1
+				AND (ideas.visibility = 'public' OR (ideas.visibility = 'investors_only' AND me.is_investor))
+				AND ideas.hidden_at IS NULL AND ideas.held_at IS NULL AND ideas.deleted_at IS NULL
+				AND ideas.created_at >= NOW() - INTERVAL '180 days'
+				AND NOT EXISTS (SELECT 1 FROM idea_dismissals d WHERE d.user_id = $1 AND d.idea_id = ideas.id)
+		),
+		scored AS (
+			SELECT *,
+				%[1]s * follows_author::int
+				+ %[2]s * sector_match::int
+				+ %[3]s * stage_match::int
+				+ %[4]s * LN(1 + liked_in_cat)
+				- %[5]s * dismissed_in_cat
+				- %[6]s * LEAST(seen_count, 3)
+				+ %[7]s * trending_score
+				+ %[8]s * EXP(-GREATEST(age_days, 0) / 7) AS score
+			FROM candidates
+		)
+		SELECT %[9]s, u.username, s.follows_author, s.sector_match, s.stage_match, s.liked_in_cat, s.dismissed_in_cat, s.score
+		FROM scored s
+		JOIN ideas ON ideas.id = s.id
+		LEFT JOIN users u ON u.id = ideas.user_id
+		%[10]s
+		ORDER BY s.score DESC, ideas.created_at DESC
+		LIMIT $2 OFFSET $3`,
+		ftoa(feedWeightFollow), ftoa(feedWeightSector), ftoa(feedWeightStage), ftoa(feedWeightLikedCat),
+		ftoa(feedWeightDismissedCat), ftoa(feedWeightSeen), ftoa(feedWeightTrending), ftoa(feedWeightRecency),
+		ideaColumns, ideaJoins, feedSession)
+
+	rows, err := database.DB.Query(c, query, userID, limit, offset)
+	if err != nil {
+		fmt.Printf("Feed query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to build feed")
+		return
+	}
+	defer rows.Close()
+
+	items := []FeedItem{}
+	ids := []int{}
+	for rows.Next() {
+		var item FeedItem
+		var sig feedSignals
+		var authorName *string
+		targets := append(ideaScanTargets(&item.Idea), &authorName, &sig.followsAuthor, &sig.sectorMatch, &sig.stageMatch, &sig.likedInCat, &sig.dismissedInCat, &item.Score)
+		if err := rows.Scan(targets...); err != nil {
+			fmt.Printf("Scan error: %v\n", err)
+			continue
+		}
+		if authorName != nil {
+			sig.authorName = *authorName
+		}
+		item.Reason = feedReason(item.Idea, sig)
+		items = append(items, item)
+		ids = append(ids, item.ID)
+	}
+	rows.Close()
+
+	if len(ids) > 0 {
+		if err := recordImpressions(c, userID, ids); err != nil {
+			fmt.Printf("Failed to record impressions: %v\n", err)
+		}
+	}
+
+	ideas := make([]*models.Idea, len(items))
+	for i := range items {
+		ideas[i] = &items[i].Idea
+	}
+	applyViewerFlags(c, userID, ideas)
+
+	utils.RespondWithJSON(c, http.StatusOK, FeedResponse{Items: items, Limit: limit, Offset: offset})
+}
+
+// recordImpressions notes that ideas were shown to userID. Each counts once
+// per feed session; showing it again within the session changes nothing
+// but last_seen_at.
+func recordImpressions(ctx context.Context, userID string, ids []int) error {
+	_, err := database.DB.Exec(ctx, `
+		INSERT INTO idea_impressions (user_id, idea_id)
+		SELECT $1, UNNEST($2::int[])
+		ON CONFLICT (user_id, idea_id) DO UPDATE SET
+			seen_count = idea_impressions.seen_count + CASE WHEN idea_impressions.session_started_at < NOW() - INTERVAL '`+feedSession+`' THEN 1 ELSE 0 END,
+			session_started_at = CASE WHEN idea_impressions.session_started_at < NOW() - INTERVAL '`+feedSession+`'
+				THEN CURRENT_TIMESTAMP ELSE idea_impressions.session_started_at END,
+			last_seen_at = CURRENT_TIMESTAMP`, userID, ids)
+	return err
+}
+
+// NotInterested hides an idea from the user's feed and down-ranks its category.
+func NotInterested(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID := c.Param("id")
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional.
+	_ = c.ShouldBindJSON(&req)
+
+	_, err := database.DB.Exec(c,
+		"INSERT INTO idea_dismissals (user_id, idea_id, reason) VALUES ($1, $2, $3) ON CONFLICT (user_id, idea_id) DO UPDATE SET reason = EXCLUDED.reason",
+		userID, ideaID, req.Reason)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to record feedback")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Got it, you'll see fewer ideas like this"})
+}
+
+// UndoNotInterested restores a dismissed idea to the user's feed.
+func UndoNotInterested(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID := c.Param("id")
+
+	_, err := database.DB.Exec(c, "DELETE FROM idea_dismissals WHERE user_id = $1 AND idea_id = $2", userID, ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update feedback")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Feedback removed"})
+}
+
+// feedReason explains the strongest positive signal behind a feed item.
+func feedReason(idea models.Idea, sig feedSignals) string {
+	best, reason := 0.0, "Trending on Invesa"
+
+	consider := func(weight float64, text string) {
+		if weight > best {
+			best, reason = weight, text
+		}
+	}
+	if sig.followsAuthor {
+		name := sig.authorName
+		if name == "" {
+			name = "this founder"
+		}
+		consider(feedWeightFollow, "Because you follow "+name)
+	}
+	if sig.sectorMatch {
+		consider(feedWeightSector, "Because you're interested in "+idea.CategoryName)
+	}
+	if sig.stageMatch {
+		consider(feedWeightStage, "Because you're interested in "+idea.Stage+"-stage ideas")
+	}
+	if sig.likedInCat > 0 {
+		noun := "ideas"
+		if sig.likedInCat == 1 {
+			noun = "idea"
+		}
+		consider(feedWeightLikedCat*math.Log1p(float64(sig.likedInCat)), fmt.Sprintf("Because you liked %d %s %s", sig.likedInCat, idea.CategoryName, noun))
+	}
+	return reason
+}
+
+func ftoa(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"invesa_backend/internal/database"
+	"testing"
+)
+
+func TestRecordImpressionsSession(t *testing.T) {
+	requireTestDB(t)
+	ctx := context.Background()
+	author := createTestUser(t)
+	reader := createTestUser(t)
+
+	var ideaID int
+	err := database.DB.QueryRow(ctx, "INSERT INTO ideas (user_id, title, description) VALUES ($1, 'Feed session', 'Shown again and again') RETURNING id",
+		author).Scan(&ideaID)
+	if err != nil {
+		t.Fatalf("insert idea: %v", err)
+	}
+
+	// Each step moves the existing impression back in time by ago, as if
+	// that long had passed, then shows the idea again.
+	tests := []struct {
+		name      string
+		ago       string
+		wantCount int
+	}{
+		{"first impression", "", 1},
+		{"20 minutes later", "20 minutes", 1},
+		// Only 20 minutes since the last impression, but 40 since the
+		// session started.
+		{"another 20 minutes later", "20 minutes", 2},
+		{"right away", "", 2},
+		{"an hour later", "1 hour", 3},
+	}
+	for _, tt := range tests {
+		if tt.ago != "" {
+			_, err := database.DB.Exec(ctx, `UPDATE idea_impressions SET
+				session_started_at = session_started_at - $3::interval, last_seen_at = last_seen_at - $3::interval
+				WHERE user_id = $1 AND idea_id = $2`, reader, ideaID, tt.ago)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		if err := recordImpressions(ctx, reader, []int{ideaID}); err != nil {
+			t.Fatalf("%s: recordImpressions: %v", tt.name, err)
+		}
+		var count int
+		err := database.DB.QueryRow(ctx, "SELECT seen_count FROM idea_impressions WHERE user_id = $1 AND idea_id = $2", reader, ideaID).Scan(&count)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if count != tt.wantCount {
+			t.Errorf("%s: seen_count = %d, want %d", tt.name, count, tt.wantCount)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"invesa_backend/internal/database"
 	"invesa_backend/internal/utils"
 	"net/http"
@@ -21,14 +22,25 @@ func SubmitFeedback(c *gin.Context) {
 		return
 	}
 
-	_, err := database.DB.Exec(c,
-		"INSERT INTO feedback (email, message, created_at) VALUES ($1, $2, $3)",
+	// Feedback is anonymous, so the history-based screeners go by client IP.
+	result := screen(c, screeningFeedback, "", input.Message)
+	if result.rejected() {
+		respondRejected(c, result)
+		return
+	}
+
+	var id int
+	err := database.DB.QueryRow(c,
+		"INSERT INTO feedback (email, message, created_at) VALUES ($1, $2, $3) RETURNING id",
 		input.Email, input.Message, time.Now(),
-	)
+	).Scan(&id)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to submit feedback")
 		return
 	}
+	if err := result.save(c, database.DB, &id); err != nil {
+		fmt.Printf("Failed to save screening result: %v\n", err)
+	}
 
 	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Thanks for the feedback"})
 }
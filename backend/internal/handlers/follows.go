@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FollowingFeedItem is one entry in the following feed. Type says which of the
+// optional payload fields is set; "update" items also carry their idea.
+type FollowingFeedItem struct {
+	Type      string             `json:"type"` // idea or update
+	Idea      *models.Idea       `json:"idea,omitempty"`
+	Update    *models.IdeaUpdate `json:"update,omitempty"`
+	Reason    string             `json:"reason"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// GetUserProfile returns a user's public profile with follower counts.
+func GetUserProfile(c *gin.Context) {
+	targetID := c.Param("id")
+	viewerID := c.GetString("user_id")
+
+	var p models.Profile
+	err := database.DB.QueryRow(c, `
+		SELECT u.id, u.username, COALESCE(u.full_name, ''), COALESCE(u.bio, ''), u.role, u.avatar_url, u.created_at,
+			(SELECT COUNT(*) FROM user_follows WHERE followed_id = u.id),
+			(SELECT COUNT(*) FROM user_follows WHERE follower_id = u.id),
+			EXISTS(SELECT 1 FROM user_follows WHERE follower_id = $2 AND followed_id = u.id)
+		FROM users u WHERE u.id = $1`, targetID, nullableID(viewerID)).Scan(
+		&p.ID, &p.Username, &p.FullName, &p.Bio, &p.Role, &p.AvatarURL, &p.CreatedAt,
+		&p.FollowerCount, &p.FollowingCount, &p.IsFollowing)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, p)
+}
+
+// FollowUser makes the caller follow another user and notifies them.
+func FollowUser(c *gin.Context) {
+	userID := c.GetString("user_id")
+	targetID := c.Param("id")
+	if targetID == userID {
+		utils.RespondWithError(c, http.StatusBadRequest, "You cannot follow yourself")
+		return
+	}
+
+	var username string
+	if err := database.DB.QueryRow(c, "SELECT username FROM users WHERE id = $1", userID).Scan(&username); err != nil {
+		utils.RespondWithError(c, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	var exists bool
+	if err := database.DB.QueryRow(c, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", targetID).Scan(&exists); err != nil || !exists {
+		utils.RespondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	result, err := database.DB.Exec(c,
+		"INSERT INTO user_follows (follower_id, followed_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, targetID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to follow user")
+		return
+	}
+	// Only a new follow notifies, so repeated requests do not spam the user.
+	if result.RowsAffected() > 0 {
+		notify(c, targetID, userID, notificationFollow, nil, username+" started following you")
+		utils.LogActivity(c, userID, "FOLLOW_USER", "Followed user "+targetID)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Following", "is_following": true})
+}
+
+// UnfollowUser removes a follow. Unfollowing someone not followed is a no-op.
+func UnfollowUser(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	_, err := database.DB.Exec(c, "DELETE FROM user_follows WHERE follower_id = $1 AND followed_id = $2", userID, c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to unfollow user")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Unfollowed", "is_following": false})
+}
+
+// FollowCategory makes the caller follow an active category.
+func FollowCategory(c *gin.Context) {
+	userID := c.GetString("user_id")
+	slug, ok := resolveActiveCategory(c, c.Param("slug"))
+	if !ok {
+		utils.RespondWithError(c, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	_, err := database.DB.Exec(c,
+		"INSERT INTO category_follows (user_id, category_slug) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, slug)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to follow category")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Following", "category": slug, "is_following": true})
+}
+
+// UnfollowCategory removes a category follow.
+func UnfollowCategory(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	_, err := database.DB.Exec(c, "DELETE FROM category_follows WHERE user_id = $1 AND category_slug = $2", userID, slugify(c.Param("slug")))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to unfollow category")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Unfollowed", "is_following": false})
+}
+
+// GetFollowers lists the users following :id, most recent first.
+func GetFollowers(c *gin.Context) {
+	listFollows(c, `
+		SELECT u.id, u.username, COALESCE(u.full_name, ''), u.avatar_url, f.created_at
+		FROM user_follows f JOIN users u ON u.id = f.follower_id
+		WHERE f.followed_id = $1
+		ORDER BY f.created_at DESC LIMIT $2 OFFSET $3`, false)
+}
+
+// GetFollowing lists the users :id follows, most recent first. The first page
+// also carries the categories they follow.
+func GetFollowing(c *gin.Context) {
+	listFollows(c, `
+		SELECT u.id, u.username, COALESCE(u.full_name, ''), u.avatar_url, f.created_at
+		FROM user_follows f JOIN users u ON u.id = f.followed_id
+		WHERE f.follower_id = $1
+		ORDER BY f.created_at DESC LIMIT $2 OFFSET $3`, true)
+}
+
+func listFollows(c *gin.Context, query string, withCategories bool) {
+	targetID := c.Param("id")
+	limit := parseLimit(c.Query("limit"), 20, 100)
+	offset := parseOffset(c.Query("offset"))
+
+	var exists bool
+	if err := database.DB.QueryRow(c, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", targetID).Scan(&exists); err != nil || !exists {
+		utils.RespondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	rows, err := database.DB.Query(c, query, targetID, limit, offset)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch follows")
+		return
+	}
+	users := []models.UserSummary{}
+	for rows.Next() {
+		var u models.UserSummary
+		if err := rows.Scan(&u.ID, &u.Username, &u.FullName, &u.AvatarURL, &u.FollowedAt); err != nil {
+			continue
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+
+	resp := gin.H{"items": users, "limit": limit, "offset": offset}
+	if withCategories && offset == 0 {
+		categories, err := followedCategories(c, targetID)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch follows")
+			return
+		}
+		resp["categories"] = categories
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+func followedCategories(c *gin.Context, userID string) ([]models.Category, error) {
+	rows, err := database.DB.Query(c, `
+		SELECT cat.id, cat.slug, cat.name, cat.sort_order, cat.is_active, cat.created_at
+		FROM category_follows cf JOIN categories cat ON cat.slug = cf.category_slug
+		WHERE cf.user_id = $1 ORDER BY cat.sort_order, cat.name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var cat models.Category
+		if err := rows.Scan(&cat.ID, &cat.Slug, &cat.Name, &cat.SortOrder, &cat.IsActive, &cat.CreatedAt); err != nil {
+			return nil, err
+		}
+		categories = append(categories, cat)
+	}
+	return categories, rows.Err()
+}
+
+// GetFollowingFeed is a reverse-chronological stream of new ideas and founder
+// updates from the founders and categories the caller follows. Supporter
+// updates are only included for followers of the founder, verified investors
+// and the idea's team, as on the idea page.
+func GetFollowingFeed(c *gin.Context) {
+	userID := c.GetString("user_id")
+	limit := parseLimit(c.Query("limit"), 20, 100)
+	offset := parseOffset(c.Query("offset"))
+	v := currentViewer(c)
+
+	rows, err := database.DB.Query(c, `
+		WITH followed AS (
+			SELECT ideas.id, COALESCE(ideas.published_at, ideas.created_at) AS published_at,
+				EXISTS(SELECT 1 FROM user_follows f WHERE f.follower_id = $1 AND f.followed_id = ideas.user_id) AS follows_author,
+				EXISTS(SELECT 1 FROM idea_members m WHERE m.idea_id = ideas.id AND m.user_id = $1) AS on_team
+			FROM ideas
+			WHERE ideas.user_id <> $1
+				AND ideas.visibility = ANY($2)
+				AND ideas.hidden_at IS NULL AND ideas.held_at IS NULL AND ideas.deleted_at IS NULL
+				AND (
+					EXISTS(SELECT 1 FROM user_follows f WHERE f.follower_id = $1 AND f.followed_id = ideas.user_id)
+					OR EXISTS(SELECT 1 FROM category_follows cf WHERE cf.user_id = $1 AND cf.category_slug = ideas.category)
+				)
+		), entries AS (
+			SELECT f.id AS idea_id, NULL::int AS update_id, f.published_at AS at FROM followed f
+			UNION ALL
+			SELECT f.id, up.id, up.published_at
+			FROM followed f JOIN idea_updates up ON up.idea_id = f.id
+			WHERE up.published_at IS NOT NULL AND up.hidden_at IS NULL AND up.held_at IS NULL
+				AND (up.visibility = $3 OR f.follows_author OR f.on_team OR $4)
+		)
+		SELECT `+ideaColumns+`, COALESCE(u.username, ''), f.follows_author,
+			COALESCE(up.id, 0), up.author_id::text, COALESCE(NULLIF(a.full_name, ''), a.username, ''), COALESCE(up.title, ''), COALESCE(up.body, ''),
+			COALESCE(up.visibility, ''), up.published_at, COALESCE(up.created_at, e.at), COALESCE(up.updated_at, e.at)
+		FROM entries e
+		JOIN followed f ON f.id = e.idea_id
+		JOIN ideas ON ideas.id = e.idea_id
+		`+ideaJoins+`
+		LEFT JOIN users u ON u.id = ideas.user_id
+		LEFT JOIN idea_updates up ON up.id = e.update_id
+		LEFT JOIN users a ON a.id = up.author_id
+		ORDER BY e.at DESC, ideas.id DESC, COALESCE(e.update_id, 0) DESC
+		LIMIT $5 OFFSET $6`, userID, v.listedVisibilities(), updateVisibilityPublic, v.IsVerifiedInvestor, limit, offset)
+	if err != nil {
+		fmt.Printf("Following feed query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to build feed")
+		return
+	}
+	defer rows.Close()
+
+	items := []FollowingFeedItem{}
+	var ideas []*models.Idea
+	for rows.Next() {
+		idea := &models.Idea{}
+		update := &models.IdeaUpdate{}
+		var authorName string
+		var followsAuthor bool
+		targets := append(ideaScanTargets(idea), &authorName, &followsAuthor,
+			&update.ID, &update.AuthorID, &update.AuthorName, &update.Title, &update.Body,
+			&update.Visibility, &update.PublishedAt, &update.CreatedAt, &update.UpdatedAt)
+		if err := rows.Scan(targets...); err != nil {
+			fmt.Printf("Scan error: %v\n", err)
+			continue
+		}
+
+		item := FollowingFeedItem{Type: "idea", Idea: idea, CreatedAt: idea.CreatedAt}
+		if idea.PublishedAt != nil {
+			item.CreatedAt = *idea.PublishedAt
+		}
+		verb := "New"
+		if update.ID != 0 {
+			update.IdeaID = idea.ID
+			item.Type, item.Update, item.CreatedAt = "update", update, *update.PublishedAt
+			verb = "Update"
+		}
+		if followsAuthor {
+			item.Reason = verb + " from " + authorName
+		} else {
+			item.Reason = verb + " in " + idea.CategoryName
+		}
+		items = append(items, item)
+		ideas = append(ideas, idea)
+	}
+	rows.Close()
+
+	applyViewerFlags(c, userID, ideas)
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": items, "limit": limit, "offset": offset})
+}
+
+// nullableID maps an anonymous caller to NULL so it can be compared with UUID columns.
+func nullableID(id string) *string {
+	if id == "" {
+		return nil
+	}
+	return &id
+}
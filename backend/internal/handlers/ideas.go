@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"invesa_backend/internal/cache"
 	"invesa_backend/internal/database"
 	"invesa_backend/internal/models"
 	"invesa_backend/internal/utils"
 	"net/http"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -18,183 +21,509 @@ func CreateIdea(c *gin.Context) {
 		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	idea.UserID = c.GetString("user_id")
 
 	// Default category if empty
 	if idea.Category == "" {
-		idea.Category = "Other"
+		idea.Category = defaultCategorySlug
+	}
+	category, ok := resolveActiveCategory(c, idea.Category)
+	if !ok {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid category")
+		return
+	}
+	idea.Category = category
+	tags, err := normalizeTags(idea.Tags)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	idea.Tags = tags
+	if idea.Stage != "" && !validStages[idea.Stage] {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid stage")
+		return
+	}
+	if idea.Visibility == "" {
+		idea.Visibility = visibilityPublic
+	}
+	if _, ok := visibilityTransitions[idea.Visibility]; !ok {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid visibility")
+		return
+	}
+	// With publish_at the idea stays a draft until the scheduler publishes it
+	// with the requested visibility.
+	target := idea.Visibility
+	var scheduledVisibility *string
+	if idea.PublishAt != nil {
+		if msg := scheduleError(*idea.PublishAt, target); msg != "" {
+			utils.RespondWithError(c, http.StatusBadRequest, msg)
+			return
+		}
+		scheduledVisibility = &target
+		idea.Visibility = visibilityDraft
+	}
+
+	result := screen(c, reportIdea, idea.UserID, idea.Title+"\n\n"+idea.Description)
+	if result.rejected() {
+		respondRejected(c, result)
+		return
+	}
+
+	var similar []models.SimilarIdea
+	if mode := duplicateMode(); mode != duplicateModeOff {
+		var err error
+		similar, err = findDuplicateIdeas(c, loadViewer(c, idea.UserID), idea.Title, idea.Description, duplicateThreshold())
+		if err != nil {
+			// The check is advisory; a failure must not stop the post.
+			fmt.Printf("Duplicate check error: %v\n", err)
+		}
+		if mode == duplicateModeBlock && len(similar) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "A very similar idea already exists", "similar_ideas": similar})
+			return
+		}
 	}
 
-	_, err := database.DB.Exec(c, "INSERT INTO ideas (user_id, title, description, category) VALUES ($1, $2, $3, $4)", idea.UserID, idea.Title, idea.Description, idea.Category)
+	var shareToken *string
+	if target == visibilityUnlisted {
+		token, err := newShareToken()
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create share link")
+			return
+		}
+		shareToken = &token
+	}
+
+	tx, err := database.DB.Begin(c)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create idea")
 		return
 	}
+	defer tx.Rollback(c)
 
-	clearIdeasCache()
+	// Held ideas are saved hidden until a moderator approves them.
+	err = tx.QueryRow(c, `INSERT INTO ideas (user_id, title, description, category, stage, visibility, share_token, published_at, held_at, publish_at, scheduled_visibility)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CASE WHEN $6 = 'draft' THEN NULL ELSE CURRENT_TIMESTAMP END, CASE WHEN $8 THEN CURRENT_TIMESTAMP END, $9::timestamptz, $10) RETURNING id`,
+		idea.UserID, idea.Title, idea.Description, idea.Category, idea.Stage, idea.Visibility, shareToken, result.held(),
+		idea.PublishAt, scheduledVisibility).Scan(&idea.ID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create idea")
+		return
+	}
+	if err := result.save(c, tx, &idea.ID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create idea")
+		return
+	}
+	if _, err := tx.Exec(c, "INSERT INTO idea_stats (idea_id) VALUES ($1)", idea.ID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create idea")
+		return
+	}
 
-	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Idea posted successfully"})
+	if err := setIdeaTags(c, tx, idea.ID, idea.Tags); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to save tags")
+		return
+	}
+	if idea.Visibility != visibilityDraft && !result.held() {
+		if err := announceIdea(c, tx, idea.ID); err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create idea")
+			return
+		}
+	}
+
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create idea")
+		return
+	}
+
+	invalidateIdeaListings(c, idea.Category, idea.UserID)
+
+	resp := gin.H{"message": "Idea posted successfully", "id": idea.ID, "visibility": idea.Visibility}
+	if shareToken != nil {
+		resp["share_token"] = *shareToken
+	}
+	if scheduledVisibility != nil {
+		resp["message"] = "Idea scheduled"
+		resp["publish_at"] = idea.PublishAt
+		resp["scheduled_visibility"] = target
+	}
+	if result.held() {
+		resp["message"] = "Idea submitted and held for review"
+		resp["held_for_review"] = true
+	}
+	if len(similar) > 0 {
+		resp["warning"] = "This idea looks very similar to existing ideas"
+		resp["similar_ideas"] = similar
+	}
+	utils.RespondWithJSON(c, http.StatusOK, resp)
 }
 
-func GetIdeas(c *gin.Context) {
-	category := c.Query("category")
-	search := c.Query("search")
-	userID := c.Query("user_id")
-	limit := parseLimit(c.Query("limit"), 20, 100)
-	offset := parseOffset(c.Query("offset"))
-
-	cacheKey := ideasCacheKey(category, search, userID, limit, offset)
-	if search == "" && userID == "" {
-		if cached, ok := getIdeasCache(cacheKey); ok {
-			utils.RespondWithJSON(c, http.StatusOK, cached)
+type UpdateIdeaRequest struct {
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	Category    *string   `json:"category"`
+	Stage       *string   `json:"stage"`
+	Tags        *[]string `json:"tags"`
+}
+
+// UpdateIdea edits an idea's content. The owner and editors can use it;
+// visibility and publishing stay with the owner. Fields left out are kept.
+func UpdateIdea(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+	var req UpdateIdeaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var tags []string
+	if req.Tags != nil {
+		if tags, err = normalizeTags(*req.Tags); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
 			return
 		}
 	}
 
-	query := "SELECT id, user_id, title, description, category, created_at, (SELECT COUNT(*) FROM idea_likes WHERE idea_id = ideas.id) as likes_count FROM ideas WHERE 1=1"
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update idea")
+		return
+	}
+	defer tx.Rollback(c)
+
+	if !requireIdeaRole(c, tx, ideaID, roleEditor, "Only the owner and editors can edit this idea") {
+		return
+	}
+	var idea models.Idea
+	err = tx.QueryRow(c, "SELECT user_id, title, description, category, stage FROM ideas WHERE id = $1 FOR UPDATE", ideaID).
+		Scan(&idea.UserID, &idea.Title, &idea.Description, &idea.Category, &idea.Stage)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update idea")
+		return
+	}
+	oldCategory := idea.Category
+
+	if req.Title != nil {
+		idea.Title = strings.TrimSpace(*req.Title)
+		if idea.Title == "" {
+			utils.RespondWithError(c, http.StatusBadRequest, "Title cannot be empty")
+			return
+		}
+	}
+	if req.Description != nil {
+		idea.Description = *req.Description
+	}
+	if req.Category != nil {
+		category, ok := resolveActiveCategory(c, *req.Category)
+		if !ok {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid category")
+			return
+		}
+		idea.Category = category
+	}
+	if req.Stage != nil {
+		if *req.Stage != "" && !validStages[*req.Stage] {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid stage")
+			return
+		}
+		idea.Stage = *req.Stage
+	}
+
+	// Only new text is screened, and it is screened as the editor's.
+	var result screened
+	textChanged := req.Title != nil || req.Description != nil
+	if textChanged {
+		result = screen(c, reportIdea, userID, idea.Title+"\n\n"+idea.Description)
+		if result.rejected() {
+			respondRejected(c, result)
+			return
+		}
+	}
+
+	// Held edits hide the idea until a moderator approves them. An idea held
+	// since it was posted keeps its held_at, so releasing it still announces it.
+	_, err = tx.Exec(c, `
+		UPDATE ideas SET title = $1, description = $2, category = $3, stage = $4, updated_at = CURRENT_TIMESTAMP,
+			held_at = CASE WHEN $5 THEN COALESCE(held_at, CURRENT_TIMESTAMP) ELSE held_at END
+		WHERE id = $6`,
+		idea.Title, idea.Description, idea.Category, idea.Stage, textChanged && result.held(), ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update idea")
+		return
+	}
+	if textChanged {
+		if err := result.save(c, tx, &ideaID); err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update idea")
+			return
+		}
+	}
+	if req.Tags != nil {
+		if err := setIdeaTags(c, tx, ideaID, tags); err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to save tags")
+			return
+		}
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update idea")
+		return
+	}
+
+	utils.LogActivity(c, userID, "UPDATE_IDEA", fmt.Sprintf("Edited idea %d", ideaID))
+	invalidateIdeaListings(c, idea.Category, idea.UserID)
+	if oldCategory != idea.Category {
+		invalidateIdeaListings(c, oldCategory, idea.UserID)
+	}
+
+	resp := gin.H{"message": "Idea updated"}
+	if textChanged && result.held() {
+		resp["message"] = "Idea updated and held for review"
+		resp["held_for_review"] = true
+	}
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+func GetIdeas(c *gin.Context) {
+	q := ideasQuery{
+		Category:     c.Query("category"),
+		Search:       c.Query("search"),
+		UserID:       c.Query("user_id"),
+		Tags:         parseTagsQuery(c.Query("tags")),
+		MatchAllTags: c.Query("tag_mode") == "all",
+		Sort:         parseSort(c.Query("sort")),
+		Limit:        parseLimit(c.Query("limit"), 20, 100),
+		Offset:       parseOffset(c.Query("offset")),
+	}
+	if q.Category == "All" {
+		q.Category = ""
+	}
+	if q.Category != "" {
+		q.Category = slugify(q.Category)
+	}
+	if q.Sort == sortTop {
+		q.Window = parseTopWindow(c.Query("window"))
+	}
+	v := currentViewer(c)
+	// Owners browsing their own ideas also see their drafts and non-public
+	// ideas; hidden and held ideas stay out of listings for everyone.
+	if q.UserID == "" || q.UserID != v.ID {
+		q.Visibilities = v.listedVisibilities()
+	}
+
+	response, err := q.fetch(c, v)
+	if err != nil {
+		fmt.Printf("Query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch ideas")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, withViewerFlags(c, v.ID, response))
+}
+
+// ideasQuery is a GetIdeas listing. Category is a slug; a nil Visibilities
+// means no visibility filter.
+type ideasQuery struct {
+	Category     string
+	Search       string
+	UserID       string
+	Tags         []string
+	MatchAllTags bool
+	Sort         string
+	Window       string
+	Limit        int
+	Offset       int
+	Visibilities []string
+}
+
+// fetch runs q for v, going through the listings cache when that is safe.
+// Cached listings are shared by everyone in the same audience, so they must
+// only ever contain ideas every member of that audience may see. Searches and
+// owners' own listings are never cached.
+func (q ideasQuery) fetch(ctx context.Context, v viewer) (IdeasResponse, error) {
+	if q.Search != "" || q.Visibilities == nil {
+		return q.load(ctx)
+	}
+	var response IdeasResponse
+	err := cache.Default.Fetch(ctx, "ideas|"+v.audience()+"|"+q.cacheKey(), q.cacheTags(), &response, func(ctx context.Context) (any, error) {
+		return q.load(ctx)
+	})
+	return response, err
+}
+
+func (q ideasQuery) load(ctx context.Context) (IdeasResponse, error) {
+	// Ideas hidden by moderation or held by screening never appear in listings,
+	// not even the owner's.
+	query := "SELECT " + ideaColumns + " FROM ideas " + ideaJoins + " LEFT JOIN idea_rankings r ON r.idea_id = ideas.id WHERE ideas.hidden_at IS NULL AND ideas.held_at IS NULL AND ideas.deleted_at IS NULL"
 	args := []interface{}{}
 	argId := 1
 
-	if category != "" && category != "All" {
-		query += fmt.Sprintf(" AND category = $%d", argId)
-		args = append(args, category)
+	if q.Category != "" {
+		query += fmt.Sprintf(" AND ideas.category = $%d", argId)
+		args = append(args, q.Category)
 		argId++
 	}
 
-	if search != "" {
-		query += fmt.Sprintf(" AND (title ILIKE $%d OR description ILIKE $%d)", argId, argId)
-		args = append(args, "%"+search+"%")
+	if q.Search != "" {
+		query += fmt.Sprintf(" AND (ideas.title ILIKE $%d OR ideas.description ILIKE $%d)", argId, argId)
+		args = append(args, "%"+q.Search+"%")
 		argId++
 	}
 
-	if userID != "" {
-		query += fmt.Sprintf(" AND user_id = $%d", argId)
-		args = append(args, userID)
+	if q.UserID != "" {
+		query += fmt.Sprintf(" AND ideas.user_id = $%d", argId)
+		args = append(args, q.UserID)
 		argId++
 	}
 
-	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", argId, argId+1)
-	args = append(args, limit, offset)
+	if q.Visibilities != nil {
+		query += fmt.Sprintf(" AND ideas.visibility = ANY($%d)", argId)
+		args = append(args, q.Visibilities)
+		argId++
+	}
+
+	if len(q.Tags) > 0 {
+		tagQuery := fmt.Sprintf("SELECT it.idea_id FROM idea_tags it JOIN tags t ON t.id = it.tag_id WHERE t.name = ANY($%d)", argId)
+		args = append(args, q.Tags)
+		argId++
+		if q.MatchAllTags {
+			tagQuery += fmt.Sprintf(" GROUP BY it.idea_id HAVING COUNT(*) = $%d", argId)
+			args = append(args, len(q.Tags))
+			argId++
+		}
+		query += " AND ideas.id IN (" + tagQuery + ")"
+	}
 
-	rows, err := database.DB.Query(c, query, args...)
+	if span := topWindows[q.Window]; span > 0 {
+		query += fmt.Sprintf(" AND ideas.created_at >= NOW() - make_interval(secs => $%d)", argId)
+		args = append(args, span.Seconds())
+		argId++
+	}
+
+	query += ideasOrderBy(q.Sort)
+	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argId, argId+1)
+	args = append(args, q.Limit, q.Offset)
+
+	rows, err := database.DB.Query(ctx, query, args...)
 	if err != nil {
-		fmt.Printf("Query error: %v\n", err)
-		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch ideas")
-		return
+		return IdeasResponse{}, err
 	}
 	defer rows.Close()
 
 	var ideas []models.Idea
 	for rows.Next() {
 		var i models.Idea
-		if err := rows.Scan(&i.ID, &i.UserID, &i.Title, &i.Description, &i.Category, &i.CreatedAt, &i.LikesCount); err != nil {
+		if err := rows.Scan(ideaScanTargets(&i)...); err != nil {
 			fmt.Printf("Scan error: %v\n", err)
 			continue
 		}
 		ideas = append(ideas, i)
 	}
 
-	response := IdeasResponse{
-		Items:  ideas,
-		Limit:  limit,
-		Offset: offset,
-	}
-
-	if search == "" && userID == "" {
-		setIdeasCache(cacheKey, response)
-	}
-
-	utils.RespondWithJSON(c, http.StatusOK, response)
+	return IdeasResponse{Items: ideas, Limit: q.Limit, Offset: q.Offset}, rows.Err()
 }
 
-type LikeRequest struct {
-	UserID string `json:"user_id"` // UUID
-}
-
-type IdeasResponse struct {
-	Items  []models.Idea `json:"items"`
-	Limit  int           `json:"limit"`
-	Offset int           `json:"offset"`
+// withViewerFlags returns a copy of resp with the viewer's reactions and
+// bookmarks set for userID. The input may be a shared cache entry, so it is
+// never mutated.
+func withViewerFlags(c *gin.Context, userID string, resp IdeasResponse) IdeasResponse {
+	items := make([]models.Idea, len(resp.Items))
+	copy(items, resp.Items)
+	resp.Items = items
+
+	ptrs := make([]*models.Idea, len(items))
+	for i := range items {
+		ptrs[i] = &items[i]
+	}
+	applyViewerFlags(c, userID, ptrs)
+	return resp
 }
 
-func LikeIdea(c *gin.Context) {
-	ideaID := c.Param("id")
-	var req LikeRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request")
-		return
+// applyViewerFlags sets MyReactions, IsLiked and IsBookmarked on ideas for userID.
+func applyViewerFlags(c *gin.Context, userID string, ideas []*models.Idea) {
+	for _, idea := range ideas {
+		idea.MyReactions = []string{}
+		idea.IsLiked = false
+		idea.IsBookmarked = false
 	}
-
-	// Check if already liked
-	var exists bool
-	err := database.DB.QueryRow(c, "SELECT EXISTS(SELECT 1 FROM idea_likes WHERE user_id=$1 AND idea_id=$2)", req.UserID, ideaID).Scan(&exists)
-	if err != nil {
-		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+	if userID == "" || len(ideas) == 0 {
 		return
 	}
-
-	if exists {
-		// Unlike
-		_, err = database.DB.Exec(c, "DELETE FROM idea_likes WHERE user_id=$1 AND idea_id=$2", req.UserID, ideaID)
-	} else {
-		// Like
-		_, err = database.DB.Exec(c, "INSERT INTO idea_likes (user_id, idea_id) VALUES ($1, $2)", req.UserID, ideaID)
+	ids := make([]int, len(ideas))
+	for i, idea := range ideas {
+		ids[i] = idea.ID
 	}
 
+	rows, err := database.DB.Query(c, `
+		SELECT idea_id, kind FROM idea_reactions WHERE user_id = $1 AND idea_id = ANY($2)
+		UNION ALL
+		SELECT DISTINCT wi.idea_id, 'bookmark' FROM watchlist_items wi JOIN watchlists w ON w.id = wi.watchlist_id
+		WHERE w.user_id = $1 AND wi.idea_id = ANY($2)
+		ORDER BY 2`, userID, ids)
 	if err != nil {
-		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update like")
+		fmt.Printf("Viewer flags query error: %v\n", err)
 		return
 	}
+	defer rows.Close()
 
-	clearIdeasCache()
-
-	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Success", "liked": !exists})
-}
-
-type ideasCacheEntry struct {
-	value     IdeasResponse
-	expiresAt time.Time
+	reactions := map[int][]string{}
+	bookmarked := map[int]bool{}
+	for rows.Next() {
+		var id int
+		var kind string
+		if err := rows.Scan(&id, &kind); err != nil {
+			continue
+		}
+		if kind == "bookmark" {
+			bookmarked[id] = true
+			continue
+		}
+		reactions[id] = append(reactions[id], kind)
+	}
+	for _, idea := range ideas {
+		if mine, ok := reactions[idea.ID]; ok {
+			idea.MyReactions = mine
+		}
+		for _, kind := range idea.MyReactions {
+			if kind == reactionLike {
+				idea.IsLiked = true
+			}
+		}
+		idea.IsBookmarked = bookmarked[idea.ID]
+	}
 }
 
-var (
-	ideasCacheMu sync.Mutex
-	ideasCache   = map[string]ideasCacheEntry{}
-)
+// ideaColumns lists the columns scanned by ideaScanTargets. Queries using it
+// must select FROM ideas with ideaJoins applied.
+const ideaColumns = `ideas.id, ideas.user_id, ideas.title, ideas.description, ideas.category, COALESCE(cat.name, ideas.category), ideas.stage, ideas.visibility, ideas.published_at, ideas.publish_at, COALESCE(ideas.scheduled_visibility, ''), ideas.created_at, COALESCE(ideas.updated_at, ideas.created_at),
+	COALESCE(stats.likes_count, 0), COALESCE(stats.comments_count, 0), COALESCE(stats.views_count, 0),
+	ideas.confidential_details <> '' AND EXISTS(SELECT 1 FROM idea_ndas WHERE idea_id = ideas.id) as nda_required, (ideas.hidden_at IS NOT NULL OR ideas.held_at IS NOT NULL),
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM idea_tags it JOIN tags t ON t.id = it.tag_id WHERE it.idea_id = ideas.id), '{}') as tags,
+	` + reactionCountsExpr + `, ` + ideaTeamExpr
 
-const ideasCacheTTL = 10 * time.Second
+// reactionCountsExpr builds the reactions object from idea_stats joined as "stats".
+const reactionCountsExpr = `jsonb_build_object('like', COALESCE(stats.likes_count, 0), 'insightful', COALESCE(stats.insightful_count, 0), 'would_invest', COALESCE(stats.would_invest_count, 0))`
 
-func ideasCacheKey(category, search, userID string, limit, offset int) string {
-	return fmt.Sprintf("category=%s|search=%s|user=%s|limit=%d|offset=%d", category, search, userID, limit, offset)
-}
+// ideaTeamExpr lists the owner and editors; viewers are only shown to the team.
+const ideaTeamExpr = `COALESCE((SELECT jsonb_agg(jsonb_build_object('user_id', t.user_id, 'username', COALESCE(u.username, ''), 'full_name', COALESCE(u.full_name, ''), 'role', t.role) ORDER BY t.rank, u.username)
+	FROM (SELECT ideas.user_id, 'owner' AS role, 0 AS rank UNION ALL SELECT m.user_id, m.role, 1 FROM idea_members m WHERE m.idea_id = ideas.id AND m.role = 'editor') t
+	JOIN users u ON u.id = t.user_id), '[]') as team`
 
-func getIdeasCache(key string) (IdeasResponse, bool) {
-	ideasCacheMu.Lock()
-	defer ideasCacheMu.Unlock()
+const ideaJoins = "LEFT JOIN categories cat ON cat.slug = ideas.category LEFT JOIN idea_stats stats ON stats.idea_id = ideas.id"
 
-	entry, ok := ideasCache[key]
-	if !ok {
-		return IdeasResponse{}, false
-	}
-	if time.Now().After(entry.expiresAt) {
-		delete(ideasCache, key)
-		return IdeasResponse{}, false
-	}
-	return entry.value, true
+func ideaScanTargets(i *models.Idea) []interface{} {
+	return []interface{}{&i.ID, &i.UserID, &i.Title, &i.Description, &i.Category, &i.CategoryName, &i.Stage, &i.Visibility, &i.PublishedAt, &i.PublishAt, &i.ScheduledVisibility, &i.CreatedAt, &i.UpdatedAt, &i.LikesCount, &i.CommentsCount, &i.ViewsCount, &i.NDARequired, &i.IsHidden, &i.Tags, &i.Reactions, &i.Team}
 }
 
-func setIdeasCache(key string, value IdeasResponse) {
-	ideasCacheMu.Lock()
-	defer ideasCacheMu.Unlock()
-	ideasCache[key] = ideasCacheEntry{
-		value:     value,
-		expiresAt: time.Now().Add(ideasCacheTTL),
-	}
+type IdeasResponse struct {
+	Items  []models.Idea `json:"items"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
 }
 
-func clearIdeasCache() {
-	ideasCacheMu.Lock()
-	defer ideasCacheMu.Unlock()
-	ideasCache = map[string]ideasCacheEntry{}
-}
+// DeleteIdea moves one of the caller's ideas to the trash. It can be restored
+// until the purge job removes it for good. Only the owner can delete; editors
+// are told so rather than that the idea is not theirs.
 func DeleteIdea(c *gin.Context) {
 	ideaID := c.Param("id")
 	userID, exists := c.Get("user_id")
@@ -203,30 +532,34 @@ func DeleteIdea(c *gin.Context) {
 		return
 	}
 
-	// Verify ownership
-	var ownerID string
-	err := database.DB.QueryRow(c, "SELECT user_id FROM ideas WHERE id=$1", ideaID).Scan(&ownerID)
+	role, err := ideaRole(c, database.DB, ideaID, userID.(string))
 	if err != nil {
 		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
 		return
 	}
-
-	if ownerID != userID.(string) {
+	switch role {
+	case roleOwner:
+	case "":
 		utils.RespondWithError(c, http.StatusForbidden, "You can only delete your own ideas")
 		return
+	default:
+		utils.RespondWithError(c, http.StatusForbidden, "Only the idea's owner can delete it")
+		return
 	}
 
-	// Delete
-	_, err = database.DB.Exec(c, "DELETE FROM ideas WHERE id=$1", ideaID)
+	// Trash; reactions, comments and attachments stay until the idea is purged.
+	var deletedAt time.Time
+	var ownerID, category string
+	err = database.DB.QueryRow(c, "UPDATE ideas SET deleted_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id=$1 AND deleted_at IS NULL RETURNING deleted_at, user_id, category", ideaID).Scan(&deletedAt, &ownerID, &category)
 	if err != nil {
 		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to delete idea")
 		return
 	}
 
 	// Log activity
-	utils.LogActivity(c, userID.(string), "DELETE_IDEA", "Deleted idea "+ideaID)
+	utils.LogActivity(c, userID.(string), "DELETE_IDEA", "Moved idea "+ideaID+" to the trash")
 
-	clearIdeasCache()
+	invalidateIdeaListings(c, category, ownerID)
 
-	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Idea deleted successfully"})
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Idea moved to trash", "restorable_until": purgeTime(deletedAt)})
 }
@@ -0,0 +1,654 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/middleware"
+	"invesa_backend/internal/screening"
+	"invesa_backend/internal/utils"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxImportBytes = 5 << 20
+	maxImportRows  = 1000
+
+	bulkFormatCSV    = "csv"
+	bulkFormatNDJSON = "ndjson"
+	bulkFormatXLSX   = "xlsx" // CSV tuned for spreadsheets: BOM, CRLF, no formulas
+
+	// exportFlushEvery bounds how many rows sit in buffers before reaching the client.
+	exportFlushEvery = 200
+	// exportWriteTimeout replaces the server's WriteTimeout for each batch, so
+	// an export runs as long as the client keeps reading.
+	exportWriteTimeout = 30 * time.Second
+)
+
+// importRow is one idea in an import file. CSV headers use the JSON names;
+// unknown columns and fields, such as those added by an export, are ignored.
+type importRow struct {
+	ExternalID  string   `json:"external_id"`
+	UserID      string   `json:"user_id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`
+	Stage       string   `json:"stage"`
+	Visibility  string   `json:"visibility"` // Empty keeps an existing idea's visibility
+	Tags        []string `json:"tags"`
+}
+
+type importError struct {
+	Row        int    `json:"row"` // 1-based, not counting the CSV header
+	ExternalID string `json:"external_id,omitempty"`
+	Message    string `json:"message"`
+}
+
+type importReport struct {
+	DryRun    bool          `json:"dry_run"`
+	Committed bool          `json:"committed"`
+	Total     int           `json:"total"`
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Held      int           `json:"held"` // Saved hidden until a moderator approves them
+	Errors    []importError `json:"errors"`
+}
+
+// importRowError is a problem with the row itself, safe to show to the importer.
+type importRowError string
+
+func (e importRowError) Error() string { return string(e) }
+
+// ImportIdeas creates or updates the caller's ideas from a CSV or NDJSON body.
+// Rows are matched on external_id, so re-running an import is safe. Nothing is
+// written unless every row is valid; ?dry_run=true only returns the report.
+func ImportIdeas(c *gin.Context) {
+	importIdeas(c, c.GetString("user_id"))
+}
+
+// AdminImportIdeas imports ideas on behalf of their owners; every row needs a user_id.
+func AdminImportIdeas(c *gin.Context) {
+	importIdeas(c, "")
+}
+
+func importIdeas(c *gin.Context, ownerID string) {
+	format := bulkFormat(c)
+	if format != bulkFormatCSV && format != bulkFormatNDJSON {
+		utils.RespondWithError(c, http.StatusBadRequest, "Send the import as text/csv or application/x-ndjson")
+		return
+	}
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	var rows []importRow
+	var parseErrors []importError
+	var err error
+	if format == bulkFormatCSV {
+		rows, parseErrors, err = parseImportCSV(body)
+	} else {
+		rows, parseErrors, err = parseImportNDJSON(body)
+	}
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := importReport{DryRun: c.Query("dry_run") == "true", Total: len(rows), Errors: parseErrors}
+	categories, err := activeCategorySlugs(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to import ideas")
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to import ideas")
+		return
+	}
+	defer tx.Rollback(c)
+
+	unparsed := map[int]bool{}
+	for _, e := range parseErrors {
+		unparsed[e.Row] = true
+	}
+	firstSeen := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		n := i + 1
+		if unparsed[n] {
+			continue
+		}
+		if ownerID != "" {
+			if row.UserID != "" && row.UserID != ownerID {
+				report.Errors = append(report.Errors, importError{Row: n, ExternalID: row.ExternalID, Message: "user_id must be your own"})
+				continue
+			}
+			row.UserID = ownerID
+		}
+		if msg := validateImportRow(row, categories); msg != "" {
+			report.Errors = append(report.Errors, importError{Row: n, ExternalID: row.ExternalID, Message: msg})
+			continue
+		}
+		key := row.UserID + "|" + row.ExternalID
+		if first, ok := firstSeen[key]; ok {
+			report.Errors = append(report.Errors, importError{Row: n, ExternalID: row.ExternalID, Message: fmt.Sprintf("Duplicate external_id (first used on row %d)", first)})
+			continue
+		}
+		firstSeen[key] = n
+
+		result := screenImportRow(c, *row)
+		if result.rejected() {
+			report.Errors = append(report.Errors, importError{Row: n, ExternalID: row.ExternalID,
+				Message: "Rejected by content filters: " + strings.Join(result.Messages(), "; ")})
+			continue
+		}
+
+		// A savepoint per row keeps one failure from aborting the whole
+		// transaction, so the report lists every bad row at once.
+		sp, err := tx.Begin(c)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to import ideas")
+			return
+		}
+		created, err := upsertImportRow(c, sp, *row, result)
+		if err == nil {
+			err = sp.Commit(c)
+		}
+		if err != nil {
+			sp.Rollback(c)
+			msg := "Failed to save idea"
+			var rowErr importRowError
+			if errors.As(err, &rowErr) {
+				msg = rowErr.Error()
+			} else {
+				fmt.Printf("Import row error: %v\n", err)
+			}
+			report.Errors = append(report.Errors, importError{Row: n, ExternalID: row.ExternalID, Message: msg})
+			continue
+		}
+		if created {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+		if result.held() {
+			report.Held++
+		}
+	}
+
+	if report.Errors == nil {
+		report.Errors = []importError{}
+	}
+	if len(report.Errors) > 0 {
+		utils.RespondWithJSON(c, http.StatusUnprocessableEntity, report)
+		return
+	}
+	if report.DryRun {
+		utils.RespondWithJSON(c, http.StatusOK, report)
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to import ideas")
+		return
+	}
+	report.Committed = true
+
+	invalidateAllIdeas(c)
+	if ownerID != "" {
+		utils.LogActivity(c, ownerID, "IMPORT_IDEAS", fmt.Sprintf("%d created, %d updated", report.Created, report.Updated))
+	}
+	utils.RespondWithJSON(c, http.StatusOK, report)
+}
+
+// bulkFormat reads ?format=, falling back to the Content-Type for imports.
+func bulkFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	contentType := c.ContentType()
+	switch {
+	case strings.Contains(contentType, "csv"):
+		return bulkFormatCSV
+	case strings.Contains(contentType, "ndjson"), strings.Contains(contentType, "jsonl"):
+		return bulkFormatNDJSON
+	}
+	return ""
+}
+
+func parseImportCSV(body io.Reader) ([]importRow, []importError, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, errors.New("Could not read the CSV header")
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, required := range []string{"external_id", "title"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("The CSV header has no %s column", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []importRow
+	var parseErrors []importError
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) == maxImportRows {
+			return nil, nil, fmt.Errorf("Imports are limited to %d rows", maxImportRows)
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, importRow{})
+			parseErrors = append(parseErrors, importError{Row: len(rows), Message: "Malformed CSV: " + parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, importReadError(err)
+		}
+		row := importRow{
+			ExternalID:  field(record, "external_id"),
+			UserID:      field(record, "user_id"),
+			Title:       field(record, "title"),
+			Description: field(record, "description"),
+			Category:    field(record, "category"),
+			Stage:       field(record, "stage"),
+			Visibility:  field(record, "visibility"),
+		}
+		if tags := field(record, "tags"); tags != "" {
+			row.Tags = strings.Split(tags, ",")
+		}
+		rows = append(rows, row)
+	}
+	return rows, parseErrors, nil
+}
+
+func parseImportNDJSON(body io.Reader) ([]importRow, []importError, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxImportBytes)
+
+	var rows []importRow
+	var parseErrors []importError
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if len(rows) == maxImportRows {
+			return nil, nil, fmt.Errorf("Imports are limited to %d rows", maxImportRows)
+		}
+		var row importRow
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			rows = append(rows, importRow{})
+			parseErrors = append(parseErrors, importError{Row: len(rows), Message: "Malformed JSON: " + err.Error()})
+			continue
+		}
+		row.ExternalID = strings.TrimSpace(row.ExternalID)
+		row.Title = strings.TrimSpace(row.Title)
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, importReadError(err)
+	}
+	return rows, parseErrors, nil
+}
+
+func importReadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("Imports are limited to %d MB", maxImportBytes>>20)
+	}
+	return errors.New("Could not read the import")
+}
+
+// validateImportRow normalizes row in place and returns a message if it is invalid.
+func validateImportRow(row *importRow, categories map[string]bool) string {
+	switch {
+	case row.ExternalID == "":
+		return "external_id is required"
+	case len(row.ExternalID) > 255:
+		return "external_id must be at most 255 characters"
+	case row.UserID == "":
+		return "user_id is required"
+	case uuid.Validate(row.UserID) != nil:
+		return "user_id must be a UUID"
+	case row.Title == "":
+		return "title is required"
+	case len(row.Title) > 255:
+		return "title must be at most 255 characters"
+	case strings.TrimSpace(row.Description) == "":
+		return "description is required"
+	}
+
+	row.Category = slugify(row.Category)
+	if row.Category == "" {
+		row.Category = defaultCategorySlug
+	}
+	if !categories[row.Category] {
+		return "Invalid category: " + row.Category
+	}
+	if row.Stage != "" && !validStages[row.Stage] {
+		return "Invalid stage: " + row.Stage
+	}
+	if _, ok := visibilityTransitions[row.Visibility]; row.Visibility != "" && !ok {
+		return "Invalid visibility: " + row.Visibility
+	}
+	tags, err := normalizeTags(row.Tags)
+	if err != nil {
+		return err.Error()
+	}
+	row.Tags = tags
+	return ""
+}
+
+func activeCategorySlugs(c *gin.Context) (map[string]bool, error) {
+	rows, err := database.DB.Query(c, "SELECT slug FROM categories WHERE is_active")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	slugs := map[string]bool{}
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		slugs[slug] = true
+	}
+	return slugs, rows.Err()
+}
+
+// screenImportRow screens a row as a create or edit. Imports are capped in size
+// instead of going through the history-based screeners.
+func screenImportRow(c *gin.Context, row importRow) screened {
+	content := screening.Content{Kind: reportIdea, UserID: row.UserID, IP: c.ClientIP(), Text: row.Title + "\n\n" + row.Description, Bulk: true}
+	return screened{content: content, Result: screening.Default.Screen(c, content)}
+}
+
+// upsertImportRow writes one validated row and reports whether it created a new
+// idea. Held rows are hidden until a moderator approves them.
+func upsertImportRow(c *gin.Context, tx pgx.Tx, row importRow, result screened) (bool, error) {
+	var ideaID int
+	var from string
+	var shareToken *string
+	var trashed bool
+	err := tx.QueryRow(c,
+		"SELECT id, visibility, share_token, deleted_at IS NOT NULL FROM ideas WHERE user_id = $1 AND external_id = $2 FOR UPDATE",
+		row.UserID, row.ExternalID).Scan(&ideaID, &from, &shareToken, &trashed)
+	created := errors.Is(err, pgx.ErrNoRows)
+	if err != nil && !created {
+		return false, err
+	}
+	if trashed {
+		return false, importRowError("This idea is in the trash; restore it before importing over it")
+	}
+
+	to := row.Visibility
+	if to == "" {
+		to = from
+		if created {
+			to = visibilityPublic
+		}
+	}
+	if !created && to != from && !canTransition(from, to) {
+		return false, importRowError(fmt.Sprintf("Cannot change visibility from %s to %s", from, to))
+	}
+	if to == visibilityUnlisted && shareToken == nil {
+		token, err := newShareToken()
+		if err != nil {
+			return false, err
+		}
+		shareToken = &token
+	}
+
+	if created {
+		err = tx.QueryRow(c, `INSERT INTO ideas (user_id, external_id, title, description, category, stage, visibility, share_token, published_at, held_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CASE WHEN $7 = 'draft' THEN NULL ELSE CURRENT_TIMESTAMP END, CASE WHEN $9 THEN CURRENT_TIMESTAMP END) RETURNING id`,
+			row.UserID, row.ExternalID, row.Title, row.Description, row.Category, row.Stage, to, shareToken, result.held()).Scan(&ideaID)
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return false, importRowError("Unknown user_id")
+			}
+			return false, err
+		}
+		if _, err := tx.Exec(c, "INSERT INTO idea_stats (idea_id) VALUES ($1)", ideaID); err != nil {
+			return false, err
+		}
+	} else {
+		_, err = tx.Exec(c, `UPDATE ideas SET title = $1, description = $2, category = $3, stage = $4, visibility = $5, share_token = $6, updated_at = CURRENT_TIMESTAMP,
+			published_at = CASE WHEN $5 = 'draft' THEN NULL ELSE COALESCE(published_at, CURRENT_TIMESTAMP) END,
+			held_at = CASE WHEN $8 THEN COALESCE(held_at, CURRENT_TIMESTAMP) ELSE held_at END,
+			-- Publishing through an import supersedes any scheduled publish.
+			publish_at = CASE WHEN $5 = 'draft' THEN publish_at END,
+			scheduled_visibility = CASE WHEN $5 = 'draft' THEN scheduled_visibility END
+			WHERE id = $7`,
+			row.Title, row.Description, row.Category, row.Stage, to, shareToken, ideaID, result.held())
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if err := setIdeaTags(c, tx, ideaID, row.Tags); err != nil {
+		return false, err
+	}
+	if err := result.save(c, tx, &ideaID); err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// exportRow is the shape of an exported idea. It is a superset of importRow,
+// so an export can be edited and imported back.
+type exportRow struct {
+	ID int `json:"id"`
+	importRow
+	LikesCount    int        `json:"likes_count"`
+	CommentsCount int        `json:"comments_count"`
+	ViewsCount    int        `json:"views_count"`
+	PublishedAt   *time.Time `json:"published_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+var exportColumns = []string{"id", "external_id", "user_id", "title", "description", "category", "stage", "visibility", "tags",
+	"likes_count", "comments_count", "views_count", "published_at", "created_at"}
+
+func (r exportRow) record() []string {
+	published := ""
+	if r.PublishedAt != nil {
+		published = r.PublishedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{fmt.Sprint(r.ID), r.ExternalID, r.UserID, r.Title, r.Description, r.Category, r.Stage, r.Visibility,
+		strings.Join(r.Tags, ","), fmt.Sprint(r.LikesCount), fmt.Sprint(r.CommentsCount), fmt.Sprint(r.ViewsCount),
+		published, r.CreatedAt.UTC().Format(time.RFC3339)}
+}
+
+// ExportIdeas streams the caller's own ideas, drafts and hidden ideas included.
+func ExportIdeas(c *gin.Context) {
+	exportIdeas(c, c.GetString("user_id"))
+}
+
+// AdminExportIdeas streams every idea; ?user_id= narrows it to one owner.
+func AdminExportIdeas(c *gin.Context) {
+	exportIdeas(c, c.Query("user_id"))
+}
+
+// exportIdeas writes rows as the database returns them, so memory use does
+// not grow with the size of the export. Filters: category, tags, tag_mode,
+// visibility, stage and created_from/created_to (YYYY-MM-DD or RFC 3339).
+func exportIdeas(c *gin.Context, ownerID string) {
+	format := c.DefaultQuery("format", bulkFormatCSV)
+	if format != bulkFormatCSV && format != bulkFormatNDJSON && format != bulkFormatXLSX {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid format")
+		return
+	}
+
+	query := `SELECT ideas.id, COALESCE(ideas.external_id, ''), ideas.user_id, ideas.title, ideas.description, ideas.category, ideas.stage, ideas.visibility,
+		COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM idea_tags it JOIN tags t ON t.id = it.tag_id WHERE it.idea_id = ideas.id), '{}'),
+		COALESCE(stats.likes_count, 0), COALESCE(stats.comments_count, 0), COALESCE(stats.views_count, 0), ideas.published_at, ideas.created_at
+		FROM ideas LEFT JOIN idea_stats stats ON stats.idea_id = ideas.id WHERE ideas.deleted_at IS NULL`
+	args := []interface{}{}
+	argId := 1
+	filter := func(clause string, value interface{}) {
+		query += fmt.Sprintf(" AND "+clause, argId)
+		args = append(args, value)
+		argId++
+	}
+
+	if ownerID != "" {
+		filter("ideas.user_id = $%d", ownerID)
+	}
+	if category := c.Query("category"); category != "" {
+		filter("ideas.category = $%d", slugify(category))
+	}
+	if stage := c.Query("stage"); stage != "" {
+		filter("ideas.stage = $%d", stage)
+	}
+	if visibility := c.Query("visibility"); visibility != "" {
+		filter("ideas.visibility = ANY($%d)", strings.Split(visibility, ","))
+	}
+	if tags := parseTagsQuery(c.Query("tags")); len(tags) > 0 {
+		tagQuery := fmt.Sprintf("SELECT it.idea_id FROM idea_tags it JOIN tags t ON t.id = it.tag_id WHERE t.name = ANY($%d)", argId)
+		args = append(args, tags)
+		argId++
+		if c.Query("tag_mode") == "all" {
+			tagQuery += fmt.Sprintf(" GROUP BY it.idea_id HAVING COUNT(*) = $%d", argId)
+			args = append(args, len(tags))
+			argId++
+		}
+		query += " AND ideas.id IN (" + tagQuery + ")"
+	}
+	for param, clause := range map[string]string{"created_from": "ideas.created_at >= $%d", "created_to": "ideas.created_at < $%d"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseExportTime(value)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid "+param)
+			return
+		}
+		if param == "created_to" && len(value) == len(time.DateOnly) {
+			t = t.AddDate(0, 0, 1) // a bare date includes the whole day
+		}
+		filter(clause, t)
+	}
+	query += " ORDER BY ideas.id"
+
+	rows, err := database.DB.Query(c, query, args...)
+	if err != nil {
+		fmt.Printf("Export query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to export ideas")
+		return
+	}
+	defer rows.Close()
+
+	extension, contentType := "csv", "text/csv; charset=utf-8"
+	if format == bulkFormatNDJSON {
+		extension, contentType = "ndjson", "application/x-ndjson"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"ideas-%s.%s\"", time.Now().UTC().Format("20060102"), extension))
+	c.Status(http.StatusOK)
+	extendDeadline := func() {
+		if err := middleware.ExtendWriteDeadline(c, exportWriteTimeout); err != nil {
+			fmt.Printf("Export write deadline error: %v\n", err)
+		}
+	}
+	extendDeadline()
+
+	var write func(exportRow) error
+	var flush func() error
+	switch format {
+	case bulkFormatNDJSON:
+		encoder := json.NewEncoder(c.Writer)
+		write = func(r exportRow) error { return encoder.Encode(r) }
+		flush = func() error { return nil }
+	default:
+		w := csv.NewWriter(c.Writer)
+		spreadsheet := format == bulkFormatXLSX
+		if spreadsheet {
+			// Excel needs the BOM to read UTF-8 and expects CRLF line endings.
+			w.UseCRLF = true
+			c.Writer.WriteString("\ufeff")
+		}
+		w.Write(exportColumns)
+		write = func(r exportRow) error {
+			record := r.record()
+			if spreadsheet {
+				for i, value := range record {
+					record[i] = neutralizeFormula(value)
+				}
+			}
+			return w.Write(record)
+		}
+		flush = func() error {
+			w.Flush()
+			return w.Error()
+		}
+	}
+
+	count := 0
+	for rows.Next() {
+		var r exportRow
+		err := rows.Scan(&r.ID, &r.ExternalID, &r.UserID, &r.Title, &r.Description, &r.Category, &r.Stage, &r.Visibility,
+			&r.Tags, &r.LikesCount, &r.CommentsCount, &r.ViewsCount, &r.PublishedAt, &r.CreatedAt)
+		if err == nil {
+			err = write(r)
+		}
+		if err != nil {
+			// Headers are gone; all we can do is stop and leave a truncated file.
+			fmt.Printf("Export error after %d rows: %v\n", count, err)
+			return
+		}
+		count++
+		if count%exportFlushEvery == 0 {
+			extendDeadline()
+			if flush() != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+	if err := rows.Err(); err != nil {
+		fmt.Printf("Export error after %d rows: %v\n", count, err)
+	}
+	flush()
+}
+
+func parseExportTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// neutralizeFormula stops spreadsheets from evaluating user text as a formula.
+func neutralizeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
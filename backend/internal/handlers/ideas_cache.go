@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"invesa_backend/internal/cache"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cached idea listings are tagged with what they depend on, so a write only
+// drops the listings that could contain the idea it touched.
+const (
+	ideasTagAll        = "ideas"            // Every listing
+	ideasTagUnfiltered = "ideas:unfiltered" // Listings not narrowed to a category or author
+	ideasTagRankings   = "ideas:rankings"   // Trending listings
+)
+
+func categoryTag(slug string) string { return "ideas:category:" + slug }
+
+func authorTag(userID string) string { return "ideas:author:" + userID }
+
+func (q ideasQuery) cacheKey() string {
+	return fmt.Sprintf("category=%s|search=%s|user=%s|tags=%s|all=%t|sort=%s|window=%s|limit=%d|offset=%d",
+		q.Category, q.Search, q.UserID, strings.Join(q.Tags, ","), q.MatchAllTags, q.Sort, q.Window, q.Limit, q.Offset)
+}
+
+func (q ideasQuery) cacheTags() []string {
+	tags := []string{ideasTagAll}
+	if q.Category != "" {
+		tags = append(tags, categoryTag(q.Category))
+	}
+	if q.UserID != "" {
+		tags = append(tags, authorTag(q.UserID))
+	}
+	if q.Category == "" && q.UserID == "" {
+		tags = append(tags, ideasTagUnfiltered)
+	}
+	if q.Sort == sortTrending {
+		tags = append(tags, ideasTagRankings)
+	}
+	return tags
+}
+
+// invalidateIdeaListings drops the cached listings that can contain an idea
+// in category by authorID.
+func invalidateIdeaListings(ctx context.Context, category, authorID string) {
+	cache.Default.Invalidate(ctx, categoryTag(category), authorTag(authorID), ideasTagUnfiltered)
+}
+
+// invalidateIdea is invalidateIdeaListings for an idea looked up by id. If the
+// lookup fails every listing is dropped.
+func invalidateIdea(ctx context.Context, ideaID any) {
+	var category, authorID string
+	err := database.DB.QueryRow(ctx, "SELECT category, user_id FROM ideas WHERE id = $1", ideaID).Scan(&category, &authorID)
+	if err != nil {
+		invalidateAllIdeas(ctx)
+		return
+	}
+	invalidateIdeaListings(ctx, category, authorID)
+}
+
+func invalidateAllIdeas(ctx context.Context) {
+	cache.Default.Invalidate(ctx, ideasTagAll)
+}
+
+// AdminCacheStats reports hit/miss counters for the shared cache.
+func AdminCacheStats(c *gin.Context) {
+	utils.RespondWithJSON(c, http.StatusOK, cache.Default.Stats())
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// runPeriodically runs job immediately and then on every tick until ctx is
+// cancelled. Failures are logged and retried on the next tick.
+func runPeriodically(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	run := func() {
+		if err := job(ctx); err != nil {
+			log.Printf("Job %s failed: %v", name, err)
+		}
+	}
+
+	run()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
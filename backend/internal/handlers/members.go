@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Idea team roles, from most to least privileged. The owner is ideas.user_id;
+// editors and viewers are rows in idea_members.
+const (
+	roleOwner  = "owner"
+	roleEditor = "editor"
+	roleViewer = "viewer"
+
+	notificationTeamInvitation = "team_invitation"
+	notificationTeam           = "team"
+)
+
+var roleRank = map[string]int{roleViewer: 1, roleEditor: 2, roleOwner: 3}
+
+// memberRoleExpr selects the member role of the user $2 on the idea in
+// "ideas", or an empty string when they are not a member.
+const memberRoleExpr = `COALESCE((SELECT role FROM idea_members WHERE idea_id = ideas.id AND user_id = NULLIF($2, '')::uuid), '')`
+
+// memberRoles are the roles that can be given through invitations.
+var memberRoles = map[string]bool{roleEditor: true, roleViewer: true}
+
+// ideaRole returns the user's role on an idea, or "" if they are not on its
+// team. It returns pgx.ErrNoRows if the idea does not exist.
+func ideaRole(ctx context.Context, db queryRower, ideaID any, userID string) (string, error) {
+	var role string
+	err := db.QueryRow(ctx, `
+		SELECT CASE WHEN i.user_id = NULLIF($2, '')::uuid THEN 'owner' ELSE COALESCE(m.role, '') END
+		FROM ideas i
+		LEFT JOIN idea_members m ON m.idea_id = i.id AND m.user_id = NULLIF($2, '')::uuid
+		WHERE i.id = $1 AND i.deleted_at IS NULL`, ideaID, userID).Scan(&role)
+	return role, err
+}
+
+// requireIdeaRole checks that the caller has at least role on the idea,
+// writing an error with the forbidden message and returning false otherwise.
+func requireIdeaRole(c *gin.Context, db queryRower, ideaID any, role, forbidden string) bool {
+	have, err := ideaRole(c, db, ideaID, c.GetString("user_id"))
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return false
+	}
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	if roleRank[have] < roleRank[role] {
+		utils.RespondWithError(c, http.StatusForbidden, forbidden)
+		return false
+	}
+	return true
+}
+
+// GetIdeaMembers lists an idea's team. Owners and editors also see pending
+// invitations.
+func GetIdeaMembers(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID := c.Param("id")
+
+	role, err := ideaRole(c, database.DB, ideaID, userID)
+	if err != nil || role == "" {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return
+	}
+
+	rows, err := database.DB.Query(c, `
+		SELECT t.user_id, COALESCE(u.username, ''), COALESCE(u.full_name, ''), t.role, t.joined_at
+		FROM (
+			SELECT user_id, 'owner' AS role, created_at AS joined_at, 0 AS rank FROM ideas WHERE id = $1
+			UNION ALL
+			SELECT user_id, role, created_at, CASE role WHEN 'editor' THEN 1 ELSE 2 END FROM idea_members WHERE idea_id = $1
+		) t
+		JOIN users u ON u.id = t.user_id
+		ORDER BY t.rank, t.joined_at`, ideaID)
+	if err != nil {
+		fmt.Printf("Members query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch team")
+		return
+	}
+	defer rows.Close()
+
+	members := []models.IdeaMember{}
+	for rows.Next() {
+		var m models.IdeaMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.FullName, &m.Role, &m.JoinedAt); err != nil {
+			fmt.Printf("Scan error: %v\n", err)
+			continue
+		}
+		members = append(members, m)
+	}
+	rows.Close()
+
+	resp := gin.H{"members": members}
+	if roleRank[role] >= roleRank[roleEditor] {
+		invitations, err := queryInvitations(c, "inv.idea_id = $1 AND inv.status = 'pending'", ideaID)
+		if err != nil {
+			fmt.Printf("Invitations query error: %v\n", err)
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch team")
+			return
+		}
+		resp["invitations"] = invitations
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+type InvitationRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"` // editor (default) or viewer
+}
+
+// InviteToIdea invites a user by username or email to the caller's idea.
+// Email invitations are never matched to an account by address, since sign-up
+// does not verify it; the emailed link carries a single-use token instead.
+func InviteToIdea(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+
+	var req InvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if req.Role == "" {
+		req.Role = roleEditor
+	}
+	if !memberRoles[req.Role] {
+		utils.RespondWithError(c, http.StatusBadRequest, "Role must be editor or viewer")
+		return
+	}
+	if (req.Username == "") == (req.Email == "") {
+		utils.RespondWithError(c, http.StatusBadRequest, "Provide either a username or an email")
+		return
+	}
+
+	if !requireIdeaRole(c, database.DB, ideaID, roleOwner, "Only the owner can invite people to this idea") {
+		return
+	}
+
+	var inviteeID *string
+	if req.Username != "" {
+		var id string
+		err := database.DB.QueryRow(c, "SELECT id FROM users WHERE username = $1", req.Username).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			utils.RespondWithError(c, http.StatusNotFound, "User not found")
+			return
+		}
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		inviteeID = &id
+	}
+
+	if inviteeID != nil {
+		role, err := ideaRole(c, database.DB, ideaID, *inviteeID)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if role != "" {
+			utils.RespondWithError(c, http.StatusConflict, "This user is already on the team")
+			return
+		}
+	}
+
+	email := req.Email
+	var token string
+	var tokenHash *string
+	if inviteeID == nil {
+		if token, err = newShareToken(); err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to send invitation")
+			return
+		}
+		tokenHash = invitationTokenHash(token)
+	}
+	var inv models.IdeaInvitation
+	err = database.DB.QueryRow(c, `
+		INSERT INTO idea_invitations (idea_id, inviter_id, invitee_id, email, role, token_hash)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at`,
+		ideaID, userID, inviteeID, email, req.Role, tokenHash).Scan(&inv.ID, &inv.Status, &inv.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(c, http.StatusConflict, "An invitation is already pending")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to send invitation")
+		return
+	}
+	inv.IdeaID, inv.InviterID, inv.InviteeID, inv.Email, inv.Role = ideaID, &userID, inviteeID, email, req.Role
+
+	var title, inviterName string
+	if err := database.DB.QueryRow(c, `
+		SELECT i.title, COALESCE(NULLIF(u.full_name, ''), u.username, 'Someone')
+		FROM ideas i, users u WHERE i.id = $1 AND u.id = $2`, ideaID, userID).Scan(&title, &inviterName); err != nil {
+		fmt.Printf("Invitation lookup error: %v\n", err)
+	}
+	inv.IdeaTitle, inv.InviterName = title, inviterName
+
+	if inviteeID != nil {
+		notify(c, *inviteeID, userID, notificationTeamInvitation, &ideaID,
+			fmt.Sprintf("%s invited you to join %q as %s", inviterName, title, req.Role))
+	} else {
+		go utils.SendInvitationEmail(email, inviterName, title, req.Role, inv.ID, token)
+	}
+
+	utils.LogActivity(c, userID, "INVITE_MEMBER", fmt.Sprintf("Invited a %s to idea %d", req.Role, ideaID))
+	utils.RespondWithJSON(c, http.StatusCreated, inv)
+}
+
+// RevokeInvitation withdraws a pending invitation to the caller's idea.
+func RevokeInvitation(c *gin.Context) {
+	ideaID := c.Param("id")
+	if !requireIdeaRole(c, database.DB, ideaID, roleOwner, "Only the owner can manage invitations") {
+		return
+	}
+
+	result, err := database.DB.Exec(c, `
+		UPDATE idea_invitations SET status = 'revoked', responded_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND idea_id = $2 AND status = 'pending'`, c.Param("invitationId"), ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to revoke invitation")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "Invitation not found")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// GetMyInvitations lists the caller's pending invitations by username. Email
+// invitations are only reachable through their emailed link.
+func GetMyInvitations(c *gin.Context) {
+	userID := c.GetString("user_id")
+	invitations, err := queryInvitations(c, "inv.status = 'pending' AND i.deleted_at IS NULL AND "+invitedCond, userID)
+	if err != nil {
+		fmt.Printf("Invitations query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch invitations")
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": invitations})
+}
+
+// invitedCond matches invitations addressed to the user $1 by username.
+const invitedCond = `(inv.invitee_id = $1 AND inv.token_hash IS NULL)`
+
+// invitationTokenHash is what idea_invitations stores of an emailed token, so
+// a leaked table cannot be used to accept invitations. Empty tokens match
+// nothing.
+func invitationTokenHash(token string) *string {
+	if token == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(token))
+	hash := hex.EncodeToString(sum[:])
+	return &hash
+}
+
+func queryInvitations(ctx context.Context, where string, args ...interface{}) ([]models.IdeaInvitation, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT inv.id, inv.idea_id, i.title, inv.inviter_id, COALESCE(NULLIF(u.full_name, ''), u.username, ''),
+			inv.invitee_id, inv.email, inv.role, inv.status, inv.created_at, inv.responded_at
+		FROM idea_invitations inv
+		JOIN ideas i ON i.id = inv.idea_id
+		LEFT JOIN users u ON u.id = inv.inviter_id
+		WHERE `+where+`
+		ORDER BY inv.created_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []models.IdeaInvitation{}
+	for rows.Next() {
+		var inv models.IdeaInvitation
+		if err := rows.Scan(&inv.ID, &inv.IdeaID, &inv.IdeaTitle, &inv.InviterID, &inv.InviterName,
+			&inv.InviteeID, &inv.Email, &inv.Role, &inv.Status, &inv.CreatedAt, &inv.RespondedAt); err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, inv)
+	}
+	return invitations, rows.Err()
+}
+
+// AcceptInvitation joins the idea's team with the invited role. Email
+// invitations need {"token": ...} from the emailed link.
+func AcceptInvitation(c *gin.Context) {
+	respondToInvitation(c, true)
+}
+
+// DeclineInvitation turns an invitation down.
+func DeclineInvitation(c *gin.Context) {
+	respondToInvitation(c, false)
+}
+
+func respondToInvitation(c *gin.Context, accept bool) {
+	userID := c.GetString("user_id")
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(c)
+
+	var ideaID int
+	var role string
+	var inviterID *string
+	err = tx.QueryRow(c, `
+		SELECT inv.idea_id, inv.role, inv.inviter_id
+		FROM idea_invitations inv JOIN ideas i ON i.id = inv.idea_id
+		WHERE inv.id = $2 AND inv.status = 'pending' AND i.deleted_at IS NULL
+			AND (`+invitedCond+` OR inv.token_hash = $3)
+		FOR UPDATE OF inv`, userID, c.Param("id"), invitationTokenHash(req.Token)).Scan(&ideaID, &role, &inviterID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondWithError(c, http.StatusNotFound, "Invitation not found")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	status, verb := "declined", "declined"
+	if accept {
+		status, verb = "accepted", "joined"
+		// Ownership may have moved to the invitee since; owners are never members.
+		_, err := tx.Exec(c, `
+			INSERT INTO idea_members (idea_id, user_id, role, added_by)
+			SELECT $1, $2, $3, $4 FROM ideas WHERE id = $1 AND user_id <> $2
+			ON CONFLICT (idea_id, user_id) DO UPDATE SET role = EXCLUDED.role`,
+			ideaID, userID, role, inviterID)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to join the team")
+			return
+		}
+	}
+	_, err = tx.Exec(c, `
+		UPDATE idea_invitations SET status = $1, invitee_id = $2, token_hash = NULL, responded_at = CURRENT_TIMESTAMP
+		WHERE id = $3`, status, userID, c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update invitation")
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update invitation")
+		return
+	}
+
+	if accept {
+		invalidateIdea(c, ideaID)
+	}
+	if inviterID != nil {
+		notify(c, *inviterID, userID, notificationTeam, &ideaID, teamMessage(c, userID, verb, ideaID))
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Invitation " + status})
+}
+
+// UpdateMemberRole changes a member between editor and viewer.
+func UpdateMemberRole(c *gin.Context) {
+	ideaID := c.Param("id")
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !memberRoles[req.Role] {
+		utils.RespondWithError(c, http.StatusBadRequest, "Role must be editor or viewer")
+		return
+	}
+	if !requireIdeaRole(c, database.DB, ideaID, roleOwner, "Only the owner can change roles") {
+		return
+	}
+
+	result, err := database.DB.Exec(c, "UPDATE idea_members SET role = $1 WHERE idea_id = $2 AND user_id = $3",
+		req.Role, ideaID, c.Param("userId"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update role")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "Member not found")
+		return
+	}
+
+	invalidateIdea(c, ideaID)
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// RemoveMember takes someone off an idea's team. The owner can remove
+// anyone; members can remove themselves to leave.
+func RemoveMember(c *gin.Context) {
+	userID := c.GetString("user_id")
+	memberID := c.Param("userId")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+
+	leaving := memberID == userID
+	if !leaving && !requireIdeaRole(c, database.DB, ideaID, roleOwner, "Only the owner can remove members") {
+		return
+	}
+
+	result, err := database.DB.Exec(c, "DELETE FROM idea_members WHERE idea_id = $1 AND user_id = $2", ideaID, memberID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to remove member")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		if leaving {
+			// Owners cannot leave; they transfer the idea first.
+			utils.RespondWithError(c, http.StatusNotFound, "You are not a member of this idea")
+			return
+		}
+		utils.RespondWithError(c, http.StatusNotFound, "Member not found")
+		return
+	}
+
+	invalidateIdea(c, ideaID)
+	if !leaving {
+		var title string
+		_ = database.DB.QueryRow(c, "SELECT title FROM ideas WHERE id = $1", ideaID).Scan(&title)
+		notify(c, memberID, userID, notificationTeam, &ideaID, fmt.Sprintf("You were removed from the team of %q", title))
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// TransferIdeaOwnership hands the caller's idea to an existing member. The
+// previous owner stays on the team as an editor.
+func TransferIdeaOwnership(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+	var req struct {
+		UserID string `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(c)
+
+	var ownerID, category string
+	err = tx.QueryRow(c, "SELECT user_id, category FROM ideas WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", ideaID).Scan(&ownerID, &category)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return
+	}
+	if ownerID != userID {
+		utils.RespondWithError(c, http.StatusForbidden, "Only the owner can transfer this idea")
+		return
+	}
+
+	result, err := tx.Exec(c, "DELETE FROM idea_members WHERE idea_id = $1 AND user_id = $2", ideaID, req.UserID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to transfer idea")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusBadRequest, "The new owner must already be on the team")
+		return
+	}
+	if _, err := tx.Exec(c, "UPDATE ideas SET user_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", req.UserID, ideaID); err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(c, http.StatusConflict, "The new owner already has an idea with this external_id")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to transfer idea")
+		return
+	}
+	if _, err := tx.Exec(c, "INSERT INTO idea_members (idea_id, user_id, role, added_by) VALUES ($1, $2, $3, $2)",
+		ideaID, userID, roleEditor); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to transfer idea")
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to transfer idea")
+		return
+	}
+
+	invalidateIdea(c, ideaID)
+	invalidateIdeaListings(c, category, userID)
+	notify(c, req.UserID, userID, notificationTeam, &ideaID, teamMessage(c, userID, "transferred ownership of", ideaID))
+	utils.LogActivity(c, userID, "TRANSFER_IDEA", fmt.Sprintf("Transferred idea %d to %s", ideaID, req.UserID))
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Ownership transferred"})
+}
+
+// teamMessage builds "<actor> <verb> "<idea title>"" for team notifications.
+func teamMessage(ctx context.Context, actorID, verb string, ideaID int) string {
+	var name, title string
+	err := database.DB.QueryRow(ctx, `
+		SELECT COALESCE(NULLIF(u.full_name, ''), u.username, 'Someone'), i.title
+		FROM users u, ideas i WHERE u.id = $1 AND i.id = $2`, actorID, ideaID).Scan(&name, &title)
+	if err != nil {
+		fmt.Printf("Team notification lookup error: %v\n", err)
+		return "Your idea team changed"
+	}
+	return fmt.Sprintf("%s %s %q", name, verb, title)
+}
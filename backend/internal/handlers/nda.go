@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+type ConfidentialRequest struct {
+	Details string `json:"confidential_details"`
+	NDABody string `json:"nda_body"`
+}
+
+type AcceptNDARequest struct {
+	Version      int    `json:"version" binding:"required"`
+	DocumentHash string `json:"document_hash" binding:"required"`
+	FullName     string `json:"full_name" binding:"required"`
+	Agree        bool   `json:"agree"`
+}
+
+// UpdateConfidentialDetails sets an idea's gated details and its NDA text.
+// Changing the NDA text publishes a new version, which existing signers must accept again.
+func UpdateConfidentialDetails(c *gin.Context) {
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+
+	var req ConfidentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.NDABody = strings.TrimSpace(req.NDABody)
+	if strings.TrimSpace(req.Details) != "" && req.NDABody == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "An NDA is required to protect confidential details")
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(c)
+
+	// The update below locks the idea until the new NDA version is saved.
+	if !requireIdeaRole(c, tx, ideaID, roleEditor, "Only the owner and editors can change this idea") {
+		return
+	}
+
+	if _, err := tx.Exec(c, "UPDATE ideas SET confidential_details = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", req.Details, ideaID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update idea")
+		return
+	}
+
+	var nda *models.NDA
+	if req.NDABody != "" {
+		current, err := currentNDA(c, tx, ideaID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		hash := documentHash(req.NDABody)
+		if current != nil && current.DocumentHash == hash {
+			nda = current
+		} else {
+			nda = &models.NDA{IdeaID: ideaID, Version: 1, Body: req.NDABody, DocumentHash: hash}
+			if current != nil {
+				nda.Version = current.Version + 1
+			}
+			err := tx.QueryRow(c,
+				"INSERT INTO idea_ndas (idea_id, version, body, document_hash) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+				nda.IdeaID, nda.Version, nda.Body, nda.DocumentHash).Scan(&nda.ID, &nda.CreatedAt)
+			if err != nil {
+				utils.RespondWithError(c, http.StatusInternalServerError, "Failed to save NDA")
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update idea")
+		return
+	}
+
+	invalidateIdea(c, ideaID)
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Confidential details updated", "nda": nda})
+}
+
+// GetNDA returns the current NDA for an idea and whether the caller has accepted it.
+func GetNDA(c *gin.Context) {
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+	if _, ok := loadViewableIdea(c, c.Param("id")); !ok {
+		return
+	}
+
+	nda, err := currentNDA(c, database.DB, ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "This idea has no NDA")
+		return
+	}
+
+	accepted := false
+	if userID := c.GetString("user_id"); userID != "" {
+		accepted = hasNDAAccess(c, ideaID, userID)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"nda": nda, "accepted": accepted})
+}
+
+// AcceptNDA records a click-through signature of the current NDA version.
+// The client echoes back the version and hash it displayed so a signature can
+// never apply to text the signer did not see.
+func AcceptNDA(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+
+	var req AcceptNDARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !req.Agree {
+		utils.RespondWithError(c, http.StatusBadRequest, "You must agree to the NDA")
+		return
+	}
+
+	ownerID, ok := loadViewableIdea(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if ownerID == userID {
+		utils.RespondWithError(c, http.StatusBadRequest, "You cannot sign your own NDA")
+		return
+	}
+
+	var role string
+	if err := database.DB.QueryRow(c, "SELECT role FROM users WHERE id = $1", userID).Scan(&role); err != nil || role != "Investor" {
+		utils.RespondWithError(c, http.StatusForbidden, "Only investors can sign NDAs")
+		return
+	}
+
+	nda, err := currentNDA(c, database.DB, ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "This idea has no NDA")
+		return
+	}
+	if req.Version != nda.Version || req.DocumentHash != nda.DocumentHash {
+		utils.RespondWithError(c, http.StatusConflict, "The NDA has changed; please review the latest version")
+		return
+	}
+
+	var acceptance models.NDAAcceptance
+	err = database.DB.QueryRow(c, `
+		INSERT INTO nda_acceptances (nda_id, idea_id, signer_id, signer_name, nda_version, document_hash, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, accepted_at`,
+		nda.ID, ideaID, userID, strings.TrimSpace(req.FullName), nda.Version, nda.DocumentHash, c.ClientIP(), c.Request.UserAgent()).Scan(&acceptance.ID, &acceptance.AcceptedAt)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to record signature")
+		return
+	}
+
+	utils.LogActivity(c, userID, "ACCEPT_NDA", gin.H{
+		"idea_id":       ideaID,
+		"acceptance_id": acceptance.ID,
+		"nda_version":   nda.Version,
+		"document_hash": nda.DocumentHash,
+	})
+
+	utils.RespondWithJSON(c, http.StatusCreated, gin.H{"message": "NDA accepted", "acceptance_id": acceptance.ID, "accepted_at": acceptance.AcceptedAt})
+}
+
+// GetNDASignatures lists every signature on an idea's NDAs for its owner.
+func GetNDASignatures(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID := c.Param("id")
+
+	var ownerID string
+	if err := database.DB.QueryRow(c, "SELECT user_id FROM ideas WHERE id = $1 AND deleted_at IS NULL", ideaID).Scan(&ownerID); err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return
+	}
+	if ownerID != userID {
+		utils.RespondWithError(c, http.StatusForbidden, "Only the owner can see NDA signatures")
+		return
+	}
+
+	rows, err := database.DB.Query(c, `
+		SELECT a.id, a.idea_id, a.signer_id, a.signer_name, COALESCE(u.email, ''), a.nda_version, a.document_hash,
+			COALESCE(a.ip_address, ''), COALESCE(a.user_agent, ''), a.accepted_at, a.revoked_at
+		FROM nda_acceptances a
+		LEFT JOIN users u ON u.id = a.signer_id
+		WHERE a.idea_id = $1
+		ORDER BY a.accepted_at DESC`, ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch signatures")
+		return
+	}
+	defer rows.Close()
+
+	signatures := []models.NDAAcceptance{}
+	for rows.Next() {
+		var a models.NDAAcceptance
+		if err := rows.Scan(&a.ID, &a.IdeaID, &a.SignerID, &a.SignerName, &a.SignerEmail, &a.NDAVersion, &a.DocumentHash,
+			&a.IPAddress, &a.UserAgent, &a.AcceptedAt, &a.RevokedAt); err != nil {
+			continue
+		}
+		signatures = append(signatures, a)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": signatures})
+}
+
+// RevokeNDAAccess revokes a signature, hiding the confidential details from that signer again.
+func RevokeNDAAccess(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID := c.Param("id")
+	acceptanceID := c.Param("acceptanceId")
+
+	var signerID string
+	err := database.DB.QueryRow(c, `
+		UPDATE nda_acceptances a SET revoked_at = CURRENT_TIMESTAMP, revoked_by = $3
+		FROM ideas i
+		WHERE a.id = $1 AND a.idea_id = $2 AND i.id = a.idea_id AND i.user_id = $3 AND i.deleted_at IS NULL AND a.revoked_at IS NULL
+		RETURNING a.signer_id`, acceptanceID, ideaID, userID).Scan(&signerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			utils.RespondWithError(c, http.StatusNotFound, "Signature not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to revoke access")
+		return
+	}
+
+	utils.LogActivity(c, userID, "REVOKE_NDA", fmt.Sprintf("Revoked NDA access %s for %s on idea %s", acceptanceID, signerID, ideaID))
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Access revoked"})
+}
+
+// hasNDAAccess reports whether userID holds an unrevoked signature of the idea's current NDA.
+func hasNDAAccess(c *gin.Context, ideaID int, userID string) bool {
+	var ok bool
+	err := database.DB.QueryRow(c, `
+		SELECT EXISTS(
+			SELECT 1 FROM nda_acceptances a
+			WHERE a.idea_id = $1 AND a.signer_id = $2 AND a.revoked_at IS NULL
+				AND a.nda_version = (SELECT MAX(version) FROM idea_ndas WHERE idea_id = $1)
+		)`, ideaID, userID).Scan(&ok)
+	return err == nil && ok
+}
+
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func currentNDA(c *gin.Context, db queryRower, ideaID int) (*models.NDA, error) {
+	var nda models.NDA
+	err := db.QueryRow(c, `
+		SELECT id, idea_id, version, body, document_hash, created_at FROM idea_ndas
+		WHERE idea_id = $1 ORDER BY version DESC LIMIT 1`, ideaID).Scan(
+		&nda.ID, &nda.IdeaID, &nda.Version, &nda.Body, &nda.DocumentHash, &nda.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &nda, nil
+}
+
+// loadViewableIdea returns the owner of an idea the caller may view, writing a
+// 404 and returning false otherwise.
+func loadViewableIdea(c *gin.Context, ideaID string) (string, bool) {
+	var ownerID, visibility string
+	var shareToken *string
+	var hidden bool
+	var memberRole string
+	v := currentViewer(c)
+	err := database.DB.QueryRow(c, "SELECT user_id, visibility, share_token, (hidden_at IS NOT NULL OR held_at IS NOT NULL), "+memberRoleExpr+" FROM ideas WHERE id = $1 AND deleted_at IS NULL", ideaID, v.ID).Scan(&ownerID, &visibility, &shareToken, &hidden, &memberRole)
+	token := ""
+	if shareToken != nil {
+		token = *shareToken
+	}
+	onTeam := memberRole != "" || (v.ID != "" && v.ID == ownerID)
+	if err != nil || !v.canView(onTeam, visibility, token, c.Query("share"), hidden) {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return "", false
+	}
+	return ownerID, true
+}
+
+func documentHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const notificationFollow = "follow"
+
+// notify stores an in-app notification for userID. Notifications are best
+// effort, so failures are only logged. actorID and ideaID may be empty/nil.
+func notify(ctx context.Context, userID, actorID, kind string, ideaID *int, message string) {
+	var actor *string
+	if actorID != "" {
+		actor = &actorID
+	}
+	_, err := database.DB.Exec(ctx,
+		"INSERT INTO notifications (user_id, actor_id, type, idea_id, message) VALUES ($1, $2, $3, $4, $5)",
+		userID, actor, kind, ideaID, message)
+	if err != nil {
+		fmt.Printf("Failed to create notification: %v\n", err)
+	}
+}
+
+// GetNotifications lists the caller's notifications, newest first.
+// ?unread=true limits the list to unread ones.
+func GetNotifications(c *gin.Context) {
+	userID := c.GetString("user_id")
+	limit := parseLimit(c.Query("limit"), 20, 100)
+	offset := parseOffset(c.Query("offset"))
+
+	query := "SELECT id, type, actor_id::text, idea_id, message, read_at, created_at FROM notifications WHERE user_id = $1"
+	if c.Query("unread") == "true" {
+		query += " AND read_at IS NULL"
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3"
+
+	rows, err := database.DB.Query(c, query, userID, limit, offset)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+	defer rows.Close()
+
+	items := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.Type, &n.ActorID, &n.IdeaID, &n.Message, &n.ReadAt, &n.CreatedAt); err != nil {
+			continue
+		}
+		items = append(items, n)
+	}
+	rows.Close()
+
+	var unread int
+	if err := database.DB.QueryRow(c, "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL", userID).Scan(&unread); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": items, "unread_count": unread, "limit": limit, "offset": offset})
+}
+
+// MarkNotificationsRead marks the given notification ids as read, or all of
+// the caller's notifications when no ids are sent.
+func MarkNotificationsRead(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	// The body is optional.
+	_ = c.ShouldBindJSON(&req)
+
+	query := "UPDATE notifications SET read_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND read_at IS NULL"
+	args := []interface{}{userID}
+	if len(req.IDs) > 0 {
+		query += " AND id = ANY($2)"
+		args = append(args, req.IDs)
+	}
+
+	result, err := database.DB.Exec(c, query, args...)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update notifications")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Notifications marked as read", "updated": result.RowsAffected()})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"invesa_backend/internal/cache"
+	"invesa_backend/internal/database"
+	"time"
+)
+
+const (
+	sortNew           = "new"
+	sortTrending      = "trending"
+	sortTop           = "top"
+	sortMostDiscussed = "most_discussed"
+
+	// trendingGravity controls how quickly trending scores decay with age (Hacker News uses 1.8).
+	trendingGravity = 1.8
+)
+
+// topWindows bounds the "top" sort to ideas created within the window. "all" has no bound.
+var topWindows = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+func parseSort(value string) string {
+	switch value {
+	case sortTrending, sortTop, sortMostDiscussed:
+		return value
+	}
+	return sortNew
+}
+
+func parseTopWindow(value string) string {
+	if _, ok := topWindows[value]; ok {
+		return value
+	}
+	return "week"
+}
+
+// ideasOrderBy returns the ORDER BY clause for a sort mode. Trending reads from
+// idea_rankings joined as "r"; top and most discussed follow the idea_stats
+// indexes so they never sort the whole table.
+func ideasOrderBy(sort string) string {
+	switch sort {
+	case sortTrending:
+		return " ORDER BY COALESCE(r.trending_score, 0) DESC, ideas.created_at DESC"
+	case sortTop:
+		return " ORDER BY stats.likes_count DESC NULLS LAST, stats.idea_id DESC"
+	case sortMostDiscussed:
+		return " ORDER BY stats.comments_count DESC NULLS LAST, stats.idea_id DESC"
+	}
+	return " ORDER BY ideas.created_at DESC"
+}
+
+// RefreshIdeaRankings recomputes idea_rankings. The trending score is
+// points / (age_hours + 2)^gravity, where points weighs a comment as two likes
+// and ten views as one like.
+func RefreshIdeaRankings(ctx context.Context) error {
+	_, err := database.DB.Exec(ctx, `
+		INSERT INTO idea_rankings (idea_id, likes_count, comments_count, views_count, trending_score, computed_at)
+		SELECT i.id, COALESCE(s.likes_count, 0), COALESCE(s.comments_count, 0), COALESCE(s.views_count, 0),
+			(COALESCE(s.likes_count, 0) + 2 * COALESCE(s.comments_count, 0) + COALESCE(s.views_count, 0) / 10.0)
+				/ POWER(GREATEST(EXTRACT(EPOCH FROM (NOW() - i.created_at)) / 3600, 0) + 2, $1),
+			NOW()
+		FROM ideas i
+		LEFT JOIN idea_stats s ON s.idea_id = i.id
+		ON CONFLICT (idea_id) DO UPDATE SET
+			likes_count = EXCLUDED.likes_count,
+			comments_count = EXCLUDED.comments_count,
+			views_count = EXCLUDED.views_count,
+			trending_score = EXCLUDED.trending_score,
+			computed_at = EXCLUDED.computed_at`, trendingGravity)
+	return err
+}
+
+// StartRankingRefresher refreshes idea rankings on every tick until ctx is cancelled.
+func StartRankingRefresher(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "idea rankings", interval, func(ctx context.Context) error {
+		if err := RefreshIdeaRankings(ctx); err != nil {
+			return err
+		}
+		cache.Default.Invalidate(ctx, ideasTagRankings)
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	reactionLike        = "like"
+	reactionInsightful  = "insightful"
+	reactionWouldInvest = "would_invest"
+)
+
+var validReactionKinds = map[string]bool{
+	reactionLike:        true,
+	reactionInsightful:  true,
+	reactionWouldInvest: true,
+}
+
+// SetReaction adds the caller's :kind reaction to an idea. Repeating the
+// request is a no-op, so double clicks cannot flip the state.
+func SetReaction(c *gin.Context) {
+	changeReaction(c, `INSERT INTO idea_reactions (user_id, idea_id, kind) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, 1)
+}
+
+// RemoveReaction removes the caller's :kind reaction from an idea, if any.
+func RemoveReaction(c *gin.Context) {
+	changeReaction(c, `DELETE FROM idea_reactions WHERE user_id = $1 AND idea_id = $2 AND kind = $3`, -1)
+}
+
+// changeReaction runs statement and, if it changed a row, moves the idea's
+// counter by delta in the same transaction.
+func changeReaction(c *gin.Context, statement string, delta int) {
+	userID := c.GetString("user_id")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+	kind := c.Param("kind")
+	if !validReactionKinds[kind] {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid reaction")
+		return
+	}
+	if _, ok := loadViewableIdea(c, c.Param("id")); !ok {
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(c)
+
+	result, err := tx.Exec(c, statement, userID, ideaID, kind)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update reaction")
+		return
+	}
+	changed := result.RowsAffected() > 0
+	if changed {
+		if err := bumpIdeaStat(c, tx, ideaID, reactionStatColumns[kind], delta); err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update reaction")
+			return
+		}
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update reaction")
+		return
+	}
+	if changed {
+		invalidateIdea(c, ideaID)
+	}
+
+	var counts map[string]int
+	err = database.DB.QueryRow(c, "SELECT "+reactionCountsExpr+" FROM ideas LEFT JOIN idea_stats stats ON stats.idea_id = ideas.id WHERE ideas.id = $1", ideaID).Scan(&counts)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch reactions")
+		return
+	}
+	idea := models.Idea{ID: ideaID}
+	applyViewerFlags(c, userID, []*models.Idea{&idea})
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"reactions": counts, "my_reactions": idea.MyReactions})
+}
@@ -0,0 +1,506 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	reportIdea    = "idea"
+	reportComment = "comment"
+	reportMessage = "message"
+	reportUser    = "user"
+
+	reportOpen      = "open"
+	reportClaimed   = "claimed"
+	reportResolved  = "resolved"
+	reportDismissed = "dismissed"
+
+	moderationHide    = "hide_content"
+	moderationWarn    = "warn_user"
+	moderationSuspend = "suspend_user"
+
+	defaultSuspensionDays = 7
+	maxSuspensionDays     = 365
+	maxReportDetails      = 2000
+)
+
+const (
+	notificationReportOutcome = "report_outcome"
+	notificationModeration    = "moderation"
+)
+
+var reportReasons = map[string]bool{
+	"spam":                  true,
+	"scam":                  true,
+	"harassment":            true,
+	"hate_speech":           true,
+	"inappropriate":         true,
+	"misleading":            true,
+	"intellectual_property": true,
+	"impersonation":         true,
+	"other":                 true,
+}
+
+type ReportRequest struct {
+	TargetType string `json:"target_type"`
+	TargetID   any    `json:"target_id"` // Numeric id, or a user's UUID
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+}
+
+type ModerationRequest struct {
+	Action string `json:"action"` // hide_content, warn_user, suspend_user
+	Note   string `json:"note"`   // Sent to the content's author
+	Days   int    `json:"days"`   // Suspension length
+}
+
+// CreateReport flags an idea, comment, message or user for the moderators.
+// Each user can have one open report per target.
+func CreateReport(c *gin.Context) {
+	userID := c.GetString("user_id")
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Reason = strings.ToLower(strings.TrimSpace(req.Reason))
+	req.Details = strings.TrimSpace(req.Details)
+	if !reportReasons[req.Reason] {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid reason")
+		return
+	}
+	if req.Reason == "other" && req.Details == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Please describe the problem")
+		return
+	}
+	if len(req.Details) > maxReportDetails {
+		utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("Details must be at most %d characters", maxReportDetails))
+		return
+	}
+
+	targetID, authorID, ok := resolveReportTarget(c, userID, req.TargetType, req.TargetID)
+	if !ok {
+		return
+	}
+	if authorID == userID {
+		utils.RespondWithError(c, http.StatusBadRequest, "You cannot report yourself")
+		return
+	}
+
+	var id int
+	err := database.DB.QueryRow(c, `INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, reason, details)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		userID, req.TargetType, targetID, nullableID(authorID), req.Reason, req.Details).Scan(&id)
+	if isUniqueViolation(err) {
+		utils.RespondWithError(c, http.StatusConflict, "You have already reported this")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Create report error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to submit report")
+		return
+	}
+
+	utils.LogActivity(c, userID, "REPORT", fmt.Sprintf("Reported %s %s for %s", req.TargetType, targetID, req.Reason))
+	utils.RespondWithJSON(c, http.StatusCreated, gin.H{"message": "Report submitted", "id": id})
+}
+
+// resolveReportTarget normalizes the target id and returns the target's author.
+// Reporters can only flag what they can see; anything else is a 404.
+func resolveReportTarget(c *gin.Context, reporterID, targetType string, rawID any) (string, string, bool) {
+	notFound := func() (string, string, bool) {
+		utils.RespondWithError(c, http.StatusNotFound, "Reported content not found")
+		return "", "", false
+	}
+	if rawID == nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "target_id is required")
+		return "", "", false
+	}
+	raw := strings.TrimSpace(fmt.Sprint(rawID))
+
+	switch targetType {
+	case reportIdea, reportComment, reportMessage:
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			return notFound()
+		}
+		targetID := strconv.Itoa(id)
+
+		switch targetType {
+		case reportIdea:
+			ownerID, ok := loadViewableIdea(c, targetID)
+			return targetID, ownerID, ok
+		case reportComment:
+			var authorID string
+			var ideaID int
+			err := database.DB.QueryRow(c,
+				"SELECT user_id::text, idea_id FROM comments WHERE id = $1 AND hidden_at IS NULL", id).Scan(&authorID, &ideaID)
+			if err != nil {
+				return notFound()
+			}
+			if _, ok := loadViewableIdea(c, strconv.Itoa(ideaID)); !ok {
+				return "", "", false
+			}
+			return targetID, authorID, true
+		default:
+			// Only the recipient can report a message.
+			var senderID string
+			err := database.DB.QueryRow(c,
+				"SELECT sender_id::text FROM messages WHERE id = $1 AND receiver_id = $2 AND hidden_at IS NULL AND held_at IS NULL", id, reporterID).Scan(&senderID)
+			if err != nil {
+				return notFound()
+			}
+			return targetID, senderID, true
+		}
+
+	case reportUser:
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return notFound()
+		}
+		var exists bool
+		if err := database.DB.QueryRow(c, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", id).Scan(&exists); err != nil || !exists {
+			return notFound()
+		}
+		return id.String(), id.String(), true
+	}
+
+	utils.RespondWithError(c, http.StatusBadRequest, "target_type must be idea, comment, message or user")
+	return "", "", false
+}
+
+// GetReports is the moderation queue, oldest first. ?status= takes a comma
+// separated list (default open,claimed); ?target_type= and ?reason= narrow it.
+func GetReports(c *gin.Context) {
+	limit := parseLimit(c.Query("limit"), 50, 200)
+	offset := parseOffset(c.Query("offset"))
+
+	statuses := []string{reportOpen, reportClaimed}
+	if status := c.Query("status"); status != "" {
+		statuses = strings.Split(status, ",")
+	}
+	query := `
+		SELECT r.id, r.reporter_id::text, r.target_type, r.target_id, r.target_user_id::text,
+			COALESCE(CASE r.target_type
+				WHEN 'idea' THEN (SELECT title FROM ideas WHERE id = r.target_id::int)
+				WHEN 'comment' THEN (SELECT LEFT(content, 200) FROM comments WHERE id = r.target_id::int)
+				WHEN 'message' THEN (SELECT LEFT(content, 200) FROM messages WHERE id = r.target_id::int)
+				WHEN 'user' THEN (SELECT username FROM users WHERE id = r.target_id::uuid)
+			END, ''),
+			(SELECT COUNT(*) FROM reports o WHERE o.target_type = r.target_type AND o.target_id = r.target_id AND o.status IN ('open', 'claimed')),
+			r.reason, r.details, r.status, r.claimed_by::text, r.claimed_at, r.resolution, r.resolved_by::text, r.resolved_at, r.created_at
+		FROM reports r
+		WHERE r.status = ANY($1)`
+	args := []interface{}{statuses}
+	argId := 2
+	if targetType := c.Query("target_type"); targetType != "" {
+		query += fmt.Sprintf(" AND r.target_type = $%d", argId)
+		args = append(args, targetType)
+		argId++
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query += fmt.Sprintf(" AND r.reason = $%d", argId)
+		args = append(args, reason)
+		argId++
+	}
+	query += fmt.Sprintf(" ORDER BY r.created_at ASC, r.id ASC LIMIT $%d OFFSET $%d", argId, argId+1)
+	args = append(args, limit, offset)
+
+	rows, err := database.DB.Query(c, query, args...)
+	if err != nil {
+		fmt.Printf("Reports query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch reports")
+		return
+	}
+	defer rows.Close()
+
+	reports := []models.Report{}
+	for rows.Next() {
+		var r models.Report
+		if err := rows.Scan(&r.ID, &r.ReporterID, &r.TargetType, &r.TargetID, &r.TargetUserID, &r.TargetPreview, &r.OpenReports,
+			&r.Reason, &r.Details, &r.Status, &r.ClaimedBy, &r.ClaimedAt, &r.Resolution, &r.ResolvedBy, &r.ResolvedAt, &r.CreatedAt); err != nil {
+			fmt.Printf("Report scan error: %v\n", err)
+			continue
+		}
+		reports = append(reports, r)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": reports, "limit": limit, "offset": offset})
+}
+
+// ClaimReport assigns an open report to the calling moderator.
+func ClaimReport(c *gin.Context) {
+	moderatorID := c.GetString("user_id")
+	result, err := database.DB.Exec(c,
+		"UPDATE reports SET status = $1, claimed_by = $2, claimed_at = CURRENT_TIMESTAMP WHERE id = $3 AND status = $4",
+		reportClaimed, nullableID(moderatorID), c.Param("id"), reportOpen)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to claim report")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		var status string
+		if err := database.DB.QueryRow(c, "SELECT status FROM reports WHERE id = $1", c.Param("id")).Scan(&status); err != nil {
+			utils.RespondWithError(c, http.StatusNotFound, "Report not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusConflict, "Report is already "+status)
+		return
+	}
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Report claimed"})
+}
+
+// activeReport is an open or claimed report a moderator is acting on.
+type activeReport struct {
+	ID           int
+	TargetType   string
+	TargetID     string
+	TargetUserID *string
+}
+
+// ideaID is the reported idea, for linking notifications; nil for other targets.
+func (r activeReport) ideaID() *int {
+	if r.TargetType != reportIdea {
+		return nil
+	}
+	id, err := strconv.Atoi(r.TargetID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// loadActiveReport loads the report in the URL for moderatorID, claiming it if
+// it is still open. Reports claimed by someone else can only be taken over
+// with the admin key.
+func loadActiveReport(c *gin.Context, moderatorID string) (activeReport, bool) {
+	var r activeReport
+	var status string
+	var claimedBy *string
+	err := database.DB.QueryRow(c,
+		"SELECT id, target_type, target_id, target_user_id::text, status, claimed_by::text FROM reports WHERE id = $1",
+		c.Param("id")).Scan(&r.ID, &r.TargetType, &r.TargetID, &r.TargetUserID, &status, &claimedBy)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Report not found")
+		return r, false
+	}
+	if status != reportOpen && status != reportClaimed {
+		utils.RespondWithError(c, http.StatusConflict, "Report is already "+status)
+		return r, false
+	}
+	if moderatorID != "" && claimedBy != nil && *claimedBy != moderatorID {
+		utils.RespondWithError(c, http.StatusConflict, "Report is claimed by another moderator")
+		return r, false
+	}
+	if status == reportOpen {
+		_, err := database.DB.Exec(c,
+			"UPDATE reports SET status = $1, claimed_by = $2, claimed_at = CURRENT_TIMESTAMP WHERE id = $3 AND status = $4",
+			reportClaimed, nullableID(moderatorID), r.ID, reportOpen)
+		if err != nil {
+			fmt.Printf("Auto-claim report error: %v\n", err)
+		}
+	}
+	return r, true
+}
+
+// TakeModerationAction applies hide_content, warn_user or suspend_user to the
+// target of a report. The report stays open until it is resolved.
+func TakeModerationAction(c *gin.Context) {
+	moderatorID := c.GetString("user_id")
+	var req ModerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+
+	report, ok := loadActiveReport(c, moderatorID)
+	if !ok {
+		return
+	}
+
+	resp := gin.H{"message": "Action applied", "action": req.Action}
+	switch req.Action {
+	case moderationHide:
+		if !hideReportedContent(c, report, req.Note) {
+			return
+		}
+
+	case moderationWarn:
+		if report.TargetUserID == nil {
+			utils.RespondWithError(c, http.StatusConflict, "The reported user no longer exists")
+			return
+		}
+		message := "You received a warning from the moderators."
+		if req.Note != "" {
+			message += " " + req.Note
+		}
+		notify(c, *report.TargetUserID, "", notificationModeration, report.ideaID(), message)
+
+	case moderationSuspend:
+		if report.TargetUserID == nil {
+			utils.RespondWithError(c, http.StatusConflict, "The reported user no longer exists")
+			return
+		}
+		if req.Days == 0 {
+			req.Days = defaultSuspensionDays
+		}
+		if req.Days < 1 || req.Days > maxSuspensionDays {
+			utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("days must be between 1 and %d", maxSuspensionDays))
+			return
+		}
+		// Suspensions extend an existing one rather than shortening it.
+		var until time.Time
+		err := database.DB.QueryRow(c, `
+			UPDATE users SET suspended_until = GREATEST(COALESCE(suspended_until, CURRENT_TIMESTAMP), CURRENT_TIMESTAMP + make_interval(days => $1))
+			WHERE id = $2 RETURNING suspended_until`, req.Days, *report.TargetUserID).Scan(&until)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to suspend user")
+			return
+		}
+		message := fmt.Sprintf("Your account is suspended for %d days.", req.Days)
+		if req.Note != "" {
+			message += " " + req.Note
+		}
+		notify(c, *report.TargetUserID, "", notificationModeration, report.ideaID(), message)
+		resp["suspended_until"] = until
+
+	default:
+		utils.RespondWithError(c, http.StatusBadRequest, "action must be hide_content, warn_user or suspend_user")
+		return
+	}
+
+	recordModerationAction(c, &report.ID, moderatorID, req.Action, report.TargetType, report.TargetID, req.Note)
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+// hideReportedContent takes the reported idea, comment or message out of
+// circulation. Hidden ideas also leave every cached listing.
+func hideReportedContent(c *gin.Context, report activeReport, note string) bool {
+	var table, set string
+	switch report.TargetType {
+	case reportIdea:
+		table, set = "ideas", ", updated_at = CURRENT_TIMESTAMP"
+	case reportComment:
+		table = "comments"
+	case reportMessage:
+		table = "messages"
+	default:
+		utils.RespondWithError(c, http.StatusBadRequest, "Profiles cannot be hidden; suspend the user instead")
+		return false
+	}
+
+	result, err := database.DB.Exec(c,
+		"UPDATE "+table+" SET hidden_at = COALESCE(hidden_at, CURRENT_TIMESTAMP)"+set+" WHERE id = $1", report.TargetID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to hide content")
+		return false
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "Reported content no longer exists")
+		return false
+	}
+
+	if report.TargetType == reportIdea {
+		invalidateIdea(c, report.TargetID)
+	}
+	if report.TargetUserID != nil {
+		message := fmt.Sprintf("Your %s was hidden by the moderators.", report.TargetType)
+		if note != "" {
+			message += " " + note
+		}
+		notify(c, *report.TargetUserID, "", notificationModeration, report.ideaID(), message)
+	}
+	return true
+}
+
+// ResolveReport closes every open report on the same target as actioned and
+// tells the reporters.
+func ResolveReport(c *gin.Context) {
+	closeReports(c, reportResolved, "We reviewed the %s you reported and took action. Thank you for helping keep Invesa safe.")
+}
+
+// DismissReport closes every open report on the same target without action.
+func DismissReport(c *gin.Context) {
+	closeReports(c, reportDismissed, "We reviewed the %s you reported and found it does not break our guidelines.")
+}
+
+func closeReports(c *gin.Context, status, outcome string) {
+	moderatorID := c.GetString("user_id")
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, ok := loadActiveReport(c, moderatorID)
+	if !ok {
+		return
+	}
+
+	rows, err := database.DB.Query(c, `
+		UPDATE reports SET status = $1, resolution = $2, resolved_by = $3, resolved_at = CURRENT_TIMESTAMP
+		WHERE target_type = $4 AND target_id = $5 AND status IN ('open', 'claimed')
+		RETURNING reporter_id::text`,
+		status, strings.TrimSpace(req.Note), nullableID(moderatorID), report.TargetType, report.TargetID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to close report")
+		return
+	}
+	var reporters []string
+	closed := 0
+	for rows.Next() {
+		var reporterID *string
+		if err := rows.Scan(&reporterID); err != nil {
+			continue
+		}
+		closed++
+		if reporterID != nil {
+			reporters = append(reporters, *reporterID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to close report")
+		return
+	}
+
+	action := "resolve"
+	if status == reportDismissed {
+		action = "dismiss"
+	}
+	recordModerationAction(c, &report.ID, moderatorID, action, report.TargetType, report.TargetID, req.Note)
+	for _, reporterID := range reporters {
+		notify(c, reporterID, "", notificationReportOutcome, report.ideaID(), fmt.Sprintf(outcome, report.TargetType))
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Report " + status, "reports_closed": closed})
+}
+
+// recordModerationAction keeps the audit trail. reportID is nil for actions
+// outside a report. Failures are only logged.
+func recordModerationAction(c *gin.Context, reportID *int, moderatorID, action, targetType, targetID, note string) {
+	_, err := database.DB.Exec(c, `INSERT INTO moderation_actions (report_id, moderator_id, action, target_type, target_id, note)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		reportID, nullableID(moderatorID), action, targetType, targetID, note)
+	if err != nil {
+		fmt.Printf("Failed to record moderation action: %v\n", err)
+	}
+	if moderatorID != "" {
+		utils.LogActivity(c, moderatorID, "MODERATION", fmt.Sprintf("%s on %s %s", action, targetType, targetID))
+	}
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	roundDraft     = "draft"
+	roundOpen      = "open"
+	roundClosed    = "closed"
+	roundCancelled = "cancelled"
+
+	interestInterested = "interested"
+	interestDiligence  = "in_diligence"
+	interestCommitted  = "committed"
+	interestWithdrawn  = "withdrawn"
+)
+
+const notificationInterest = "round_interest"
+
+var validInstruments = map[string]bool{
+	"equity":      true,
+	"safe":        true,
+	"convertible": true,
+}
+
+// roundTransitions is the funding round state machine. Closed and cancelled
+// rounds are final.
+var roundTransitions = map[string][]string{
+	roundDraft:     {roundOpen, roundCancelled},
+	roundOpen:      {roundClosed, roundCancelled},
+	roundClosed:    {},
+	roundCancelled: {},
+}
+
+// interestTransitions is the investor pipeline. The empty state is an investor
+// with no interest registered yet; withdrawn investors may re-engage.
+var interestTransitions = map[string][]string{
+	"":                 {interestInterested, interestDiligence, interestCommitted},
+	interestInterested: {interestDiligence, interestCommitted, interestWithdrawn},
+	interestDiligence:  {interestCommitted, interestWithdrawn},
+	interestCommitted:  {interestWithdrawn},
+	interestWithdrawn:  {interestInterested},
+}
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type RoundRequest struct {
+	Instrument    string     `json:"instrument"`
+	TargetAmount  int64      `json:"target_amount"`
+	Currency      string     `json:"currency"`
+	Valuation     *int64     `json:"valuation"`
+	MinimumTicket *int64     `json:"minimum_ticket"`
+	OpensAt       *time.Time `json:"opens_at"`
+	ClosesAt      *time.Time `json:"closes_at"`
+	Status        string     `json:"status"`
+}
+
+type InterestRequest struct {
+	Status string `json:"status"`
+	Amount *int64 `json:"amount"`
+	Note   string `json:"note"`
+}
+
+func transitionAllowed(transitions map[string][]string, from, to string) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// validate normalizes req and returns a user-facing error message, or "".
+func (req *RoundRequest) validate() string {
+	req.Instrument = strings.ToLower(strings.TrimSpace(req.Instrument))
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	switch {
+	case !validInstruments[req.Instrument]:
+		return "Instrument must be equity, safe or convertible"
+	case req.TargetAmount <= 0:
+		return "Target amount must be positive"
+	case !currencyPattern.MatchString(req.Currency):
+		return "Currency must be a three-letter ISO code"
+	case req.Valuation != nil && *req.Valuation <= 0:
+		return "Valuation must be positive"
+	case req.MinimumTicket != nil && (*req.MinimumTicket <= 0 || *req.MinimumTicket > req.TargetAmount):
+		return "Minimum ticket must be positive and no larger than the target"
+	case req.OpensAt != nil && req.ClosesAt != nil && !req.ClosesAt.After(*req.OpensAt):
+		return "Close date must be after the open date"
+	}
+	return ""
+}
+
+// GetRounds lists an idea's funding rounds. Drafts are only shown to the owner;
+// investors also get their own interest in each round.
+func GetRounds(c *gin.Context) {
+	ownerID, ok := loadViewableIdea(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	userID := c.GetString("user_id")
+
+	rows, err := database.DB.Query(c, `
+		SELECT `+roundColumns+` FROM funding_rounds
+		WHERE idea_id = $1 AND (status <> $2 OR $3::boolean)
+		ORDER BY created_at DESC`, c.Param("id"), roundDraft, userID == ownerID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch rounds")
+		return
+	}
+	defer rows.Close()
+
+	rounds := []models.FundingRound{}
+	for rows.Next() {
+		var r models.FundingRound
+		if err := rows.Scan(roundScanTargets(&r)...); err != nil {
+			fmt.Printf("Scan error: %v\n", err)
+			continue
+		}
+		rounds = append(rounds, r)
+	}
+	rows.Close()
+
+	if userID != "" && userID != ownerID {
+		for i := range rounds {
+			interest, err := loadInterest(c, rounds[i].ID, userID)
+			if err == nil {
+				rounds[i].MyInterest = interest
+			}
+		}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": rounds})
+}
+
+// CreateRound adds a funding round to an idea owned by the caller. Rounds start
+// as drafts unless created with status "open".
+func CreateRound(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+
+	var req RoundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		utils.RespondWithError(c, http.StatusBadRequest, msg)
+		return
+	}
+	if req.Status == "" {
+		req.Status = roundDraft
+	}
+	if req.Status != roundDraft && req.Status != roundOpen {
+		utils.RespondWithError(c, http.StatusBadRequest, "New rounds must be draft or open")
+		return
+	}
+
+	var ownerID string
+	if err := database.DB.QueryRow(c, "SELECT user_id FROM ideas WHERE id = $1 AND deleted_at IS NULL", ideaID).Scan(&ownerID); err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return
+	}
+	if ownerID != userID {
+		utils.RespondWithError(c, http.StatusForbidden, "You can only raise on your own ideas")
+		return
+	}
+
+	var round models.FundingRound
+	err = database.DB.QueryRow(c, `
+		INSERT INTO funding_rounds (idea_id, instrument, target_amount, currency, valuation, minimum_ticket, opens_at, closes_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+roundColumns,
+		ideaID, req.Instrument, req.TargetAmount, req.Currency, req.Valuation, req.MinimumTicket, req.OpensAt, req.ClosesAt, req.Status).Scan(roundScanTargets(&round)...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(c, http.StatusConflict, "This idea already has an open round")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create round")
+		return
+	}
+
+	utils.LogActivity(c, userID, "CREATE_ROUND", fmt.Sprintf("Round %d on idea %d (%s)", round.ID, ideaID, round.Status))
+
+	utils.RespondWithJSON(c, http.StatusCreated, round)
+}
+
+// UpdateRound replaces a round's terms and optionally moves its status.
+// Terms can only change while the round is a draft or open.
+func UpdateRound(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req RoundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		utils.RespondWithError(c, http.StatusBadRequest, msg)
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(c)
+
+	var ownerID, from string
+	err = tx.QueryRow(c, `
+		SELECT i.user_id, r.status FROM funding_rounds r JOIN ideas i ON i.id = r.idea_id
+		WHERE r.id = $1 AND r.idea_id = $2 AND i.deleted_at IS NULL FOR UPDATE OF r`, c.Param("roundId"), c.Param("id")).Scan(&ownerID, &from)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Round not found")
+		return
+	}
+	if ownerID != userID {
+		utils.RespondWithError(c, http.StatusForbidden, "You can only change your own rounds")
+		return
+	}
+	if from != roundDraft && from != roundOpen {
+		utils.RespondWithError(c, http.StatusConflict, "This round is "+from+" and can no longer change")
+		return
+	}
+	if req.Status == "" {
+		req.Status = from
+	}
+	if req.Status != from && !transitionAllowed(roundTransitions, from, req.Status) {
+		utils.RespondWithError(c, http.StatusConflict, fmt.Sprintf("Cannot change round from %s to %s", from, req.Status))
+		return
+	}
+
+	var round models.FundingRound
+	err = tx.QueryRow(c, `
+		UPDATE funding_rounds SET instrument = $1, target_amount = $2, currency = $3, valuation = $4, minimum_ticket = $5,
+			opens_at = $6, closes_at = $7, status = $8, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $9
+		RETURNING `+roundColumns,
+		req.Instrument, req.TargetAmount, req.Currency, req.Valuation, req.MinimumTicket, req.OpensAt, req.ClosesAt, req.Status,
+		c.Param("roundId")).Scan(roundScanTargets(&round)...)
+	if err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(c, http.StatusConflict, "This idea already has an open round")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update round")
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update round")
+		return
+	}
+
+	if from != round.Status {
+		utils.LogActivity(c, userID, "UPDATE_ROUND_STATUS", fmt.Sprintf("Round %d: %s -> %s", round.ID, from, round.Status))
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, round)
+}
+
+// GetRoundProgress gives the owner an aggregated view of a round against its target.
+func GetRoundProgress(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var progress models.RoundProgress
+	var ownerID string
+	targets := append([]interface{}{&ownerID}, roundScanTargets(&progress.Round)...)
+	err := database.DB.QueryRow(c, `
+		SELECT i.user_id, `+roundColumns+` FROM funding_rounds JOIN ideas i ON i.id = funding_rounds.idea_id
+		WHERE funding_rounds.id = $1 AND funding_rounds.idea_id = $2 AND i.deleted_at IS NULL`, c.Param("roundId"), c.Param("id")).Scan(targets...)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Round not found")
+		return
+	}
+	if ownerID != userID {
+		utils.RespondWithError(c, http.StatusForbidden, "Only the owner can see round progress")
+		return
+	}
+
+	rows, err := database.DB.Query(c, `
+		SELECT ri.id, ri.round_id, ri.investor_id, COALESCE(NULLIF(u.full_name, ''), u.username, ''), ri.status, ri.amount, ri.note, ri.created_at, ri.updated_at
+		FROM round_interests ri LEFT JOIN users u ON u.id = ri.investor_id
+		WHERE ri.round_id = $1
+		ORDER BY ri.updated_at DESC`, progress.Round.ID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch round progress")
+		return
+	}
+	defer rows.Close()
+
+	progress.Interests = []models.RoundInterest{}
+	progress.ByStatus = map[string]models.InterestTotals{}
+	for _, status := range []string{interestInterested, interestDiligence, interestCommitted, interestWithdrawn} {
+		progress.ByStatus[status] = models.InterestTotals{}
+	}
+	for rows.Next() {
+		var ri models.RoundInterest
+		if err := rows.Scan(&ri.ID, &ri.RoundID, &ri.InvestorID, &ri.InvestorName, &ri.Status, &ri.Amount, &ri.Note, &ri.CreatedAt, &ri.UpdatedAt); err != nil {
+			continue
+		}
+		progress.Interests = append(progress.Interests, ri)
+
+		totals := progress.ByStatus[ri.Status]
+		totals.Count++
+		if ri.Amount != nil {
+			totals.Amount += *ri.Amount
+		}
+		progress.ByStatus[ri.Status] = totals
+	}
+
+	progress.CommittedAmount = progress.ByStatus[interestCommitted].Amount
+	progress.PipelineAmount = progress.ByStatus[interestInterested].Amount + progress.ByStatus[interestDiligence].Amount
+	progress.PercentCommitted = float64(progress.CommittedAmount) / float64(progress.Round.TargetAmount) * 100
+
+	utils.RespondWithJSON(c, http.StatusOK, progress)
+}
+
+// SetRoundInterest registers or moves the caller's interest in an open round.
+// Only investors can take part, and commitments must meet the minimum ticket.
+func SetRoundInterest(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req InterestRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Status == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Status is required")
+		return
+	}
+	if _, ok := interestTransitions[req.Status]; !ok {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid status")
+		return
+	}
+	if req.Amount != nil && *req.Amount <= 0 {
+		utils.RespondWithError(c, http.StatusBadRequest, "Amount must be positive")
+		return
+	}
+
+	ownerID, ok := loadViewableIdea(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	if ownerID == userID {
+		utils.RespondWithError(c, http.StatusBadRequest, "You cannot invest in your own round")
+		return
+	}
+	if v := currentViewer(c); v.Role != "Investor" {
+		utils.RespondWithError(c, http.StatusForbidden, "Only investors can register interest")
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(c)
+
+	var roundID int
+	var roundStatus, currency string
+	var minimumTicket *int64
+	var opensAt, closesAt *time.Time
+	err = tx.QueryRow(c, "SELECT id, status, currency, minimum_ticket, opens_at, closes_at FROM funding_rounds WHERE id = $1 AND idea_id = $2 FOR SHARE",
+		c.Param("roundId"), c.Param("id")).Scan(&roundID, &roundStatus, &currency, &minimumTicket, &opensAt, &closesAt)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Round not found")
+		return
+	}
+	if roundStatus != roundOpen {
+		utils.RespondWithError(c, http.StatusConflict, "This round is not open")
+		return
+	}
+	// Outside the round's window investors can only withdraw.
+	if req.Status != interestWithdrawn {
+		now := time.Now()
+		if opensAt != nil && now.Before(*opensAt) {
+			utils.RespondWithError(c, http.StatusConflict, "This round opens on "+opensAt.Format(time.RFC3339))
+			return
+		}
+		if closesAt != nil && now.After(*closesAt) {
+			utils.RespondWithError(c, http.StatusConflict, "This round closed on "+closesAt.Format(time.RFC3339))
+			return
+		}
+	}
+
+	from := ""
+	var current models.RoundInterest
+	err = tx.QueryRow(c, "SELECT status, amount FROM round_interests WHERE round_id = $1 AND investor_id = $2 FOR UPDATE", roundID, userID).Scan(&current.Status, &current.Amount)
+	if err == nil {
+		from = current.Status
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if req.Status != from && !transitionAllowed(interestTransitions, from, req.Status) {
+		utils.RespondWithError(c, http.StatusConflict, fmt.Sprintf("Cannot move interest from %s to %s", displayState(from), req.Status))
+		return
+	}
+	if req.Amount == nil {
+		req.Amount = current.Amount
+	}
+	if req.Status == interestCommitted {
+		if req.Amount == nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "A commitment needs an amount")
+			return
+		}
+		if minimumTicket != nil && *req.Amount < *minimumTicket {
+			utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("The minimum ticket is %d %s", *minimumTicket, currency))
+			return
+		}
+	}
+
+	interest := models.RoundInterest{RoundID: roundID, InvestorID: userID}
+	err = tx.QueryRow(c, `
+		INSERT INTO round_interests (round_id, investor_id, status, amount, note) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (round_id, investor_id) DO UPDATE SET
+			status = EXCLUDED.status, amount = EXCLUDED.amount, note = EXCLUDED.note, updated_at = CURRENT_TIMESTAMP
+		RETURNING id, status, amount, note, created_at, updated_at`,
+		roundID, userID, req.Status, req.Amount, strings.TrimSpace(req.Note)).Scan(
+		&interest.ID, &interest.Status, &interest.Amount, &interest.Note, &interest.CreatedAt, &interest.UpdatedAt)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to save interest")
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to save interest")
+		return
+	}
+
+	if from != interest.Status {
+		utils.LogActivity(c, userID, "ROUND_INTEREST", fmt.Sprintf("Round %d: %s -> %s", roundID, displayState(from), interest.Status))
+		ideaID, _ := strconv.Atoi(c.Param("id"))
+		var name string
+		_ = database.DB.QueryRow(c, "SELECT COALESCE(NULLIF(full_name, ''), username) FROM users WHERE id = $1", userID).Scan(&name)
+		notify(c, ownerID, userID, notificationInterest, &ideaID, interestMessage(name, interest))
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, interest)
+}
+
+func interestMessage(name string, interest models.RoundInterest) string {
+	switch interest.Status {
+	case interestCommitted:
+		return fmt.Sprintf("%s committed %d to your round", name, *interest.Amount)
+	case interestDiligence:
+		return name + " started diligence on your round"
+	case interestWithdrawn:
+		return name + " withdrew from your round"
+	}
+	return name + " is interested in your round"
+}
+
+func displayState(status string) string {
+	if status == "" {
+		return "none"
+	}
+	return status
+}
+
+func loadInterest(c *gin.Context, roundID int, investorID string) (*models.RoundInterest, error) {
+	ri := models.RoundInterest{RoundID: roundID, InvestorID: investorID}
+	err := database.DB.QueryRow(c,
+		"SELECT id, status, amount, note, created_at, updated_at FROM round_interests WHERE round_id = $1 AND investor_id = $2",
+		roundID, investorID).Scan(&ri.ID, &ri.Status, &ri.Amount, &ri.Note, &ri.CreatedAt, &ri.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ri, nil
+}
+
+const roundColumns = `funding_rounds.id, funding_rounds.idea_id, funding_rounds.instrument, funding_rounds.target_amount, funding_rounds.currency,
+	funding_rounds.valuation, funding_rounds.minimum_ticket, funding_rounds.opens_at, funding_rounds.closes_at, funding_rounds.status,
+	funding_rounds.created_at, funding_rounds.updated_at`
+
+func roundScanTargets(r *models.FundingRound) []interface{} {
+	return []interface{}{&r.ID, &r.IdeaID, &r.Instrument, &r.TargetAmount, &r.Currency, &r.Valuation, &r.MinimumTicket,
+		&r.OpensAt, &r.ClosesAt, &r.Status, &r.CreatedAt, &r.UpdatedAt}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/utils"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	notificationNewIdea       = "new_idea"
+	notificationIdeaPublished = "idea_published"
+
+	// scheduledBatchSize caps how many items one tick publishes; the rest wait
+	// for the next tick.
+	scheduledBatchSize = 100
+)
+
+// scheduleError validates a scheduled publish and returns a message if it is
+// invalid.
+func scheduleError(publishAt time.Time, visibility string) string {
+	switch {
+	case visibility == visibilityDraft:
+		return "Choose the visibility to publish with"
+	case !publishAt.After(time.Now()):
+		return "publish_at must be in the future"
+	case publishAt.After(time.Now().AddDate(1, 0, 0)):
+		return "publish_at must be within a year"
+	}
+	return ""
+}
+
+// CancelScheduledPublish turns a scheduled idea back into a plain draft.
+func CancelScheduledPublish(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID := c.Param("id")
+	result, err := database.DB.Exec(c, `
+		UPDATE ideas SET publish_at = NULL, scheduled_visibility = NULL
+		WHERE id = $1 AND user_id = $2 AND visibility = $3 AND publish_at IS NOT NULL AND deleted_at IS NULL`,
+		ideaID, userID, visibilityDraft)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to cancel schedule")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "No scheduled publish for this idea")
+		return
+	}
+
+	utils.LogActivity(c, userID, "UNSCHEDULE_IDEA", "Cancelled scheduled publish of idea "+ideaID)
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Schedule cancelled"})
+}
+
+// announceIdea tells the author's followers about a newly published idea.
+// Investors-only ideas are announced to verified investors only, and
+// unlisted or private ones not at all. Call it in the publishing transaction.
+func announceIdea(ctx context.Context, db execer, ideaID any) error {
+	_, err := db.Exec(ctx, `
+		INSERT INTO notifications (user_id, actor_id, type, idea_id, message)
+		SELECT f.follower_id, i.user_id, $2, i.id, COALESCE(u.username, 'Someone') || ' published a new idea: ' || i.title
+		FROM ideas i
+		JOIN user_follows f ON f.followed_id = i.user_id
+		JOIN users fu ON fu.id = f.follower_id
+		LEFT JOIN users u ON u.id = i.user_id
+		WHERE i.id = $1 AND i.hidden_at IS NULL AND i.held_at IS NULL AND i.deleted_at IS NULL
+			AND (i.visibility = $3 OR (i.visibility = $4 AND fu.role = 'Investor' AND COALESCE(fu.is_verified, FALSE)))`,
+		ideaID, notificationNewIdea, visibilityPublic, visibilityInvestors)
+	return err
+}
+
+// PublishScheduled publishes everything whose scheduled time has passed.
+func PublishScheduled(ctx context.Context) error {
+	if err := publishScheduledIdeas(ctx); err != nil {
+		return err
+	}
+	return publishScheduledUpdates(ctx)
+}
+
+// StartScheduler runs PublishScheduled on every tick until ctx is cancelled.
+// Schedules live in the database, so nothing is lost across restarts.
+func StartScheduler(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "scheduled publishing", interval, PublishScheduled)
+}
+
+// publishScheduledIdeas publishes due drafts and notifies their owners and
+// followers in the same transaction. SKIP LOCKED and the draft check let
+// several instances run the job without publishing anything twice.
+func publishScheduledIdeas(ctx context.Context) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE ideas SET visibility = scheduled_visibility, published_at = publish_at, publish_at = NULL, scheduled_visibility = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id IN (
+			SELECT id FROM ideas
+			WHERE visibility = $1 AND publish_at <= CURRENT_TIMESTAMP AND scheduled_visibility IS NOT NULL AND deleted_at IS NULL
+			ORDER BY publish_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		) AND visibility = $1
+		RETURNING id, user_id, title, category`, visibilityDraft, scheduledBatchSize)
+	if err != nil {
+		return err
+	}
+	type published struct {
+		id                      int
+		userID, title, category string
+	}
+	var ideas []published
+	for rows.Next() {
+		var p published
+		if err := rows.Scan(&p.id, &p.userID, &p.title, &p.category); err != nil {
+			rows.Close()
+			return err
+		}
+		ideas = append(ideas, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range ideas {
+		_, err := tx.Exec(ctx,
+			"INSERT INTO notifications (user_id, type, idea_id, message) VALUES ($1, $2, $3, $4)",
+			p.userID, notificationIdeaPublished, p.id, fmt.Sprintf("Your scheduled idea %q is now live", p.title))
+		if err != nil {
+			return err
+		}
+		if err := announceIdea(ctx, tx, p.id); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, p := range ideas {
+		invalidateIdeaListings(ctx, p.category, p.userID)
+	}
+	if len(ideas) > 0 {
+		log.Printf("Published %d scheduled ideas", len(ideas))
+	}
+	return nil
+}
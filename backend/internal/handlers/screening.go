@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/screening"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	screeningPending  = "pending"
+	screeningApproved = "approved"
+	screeningRejected = "rejected"
+
+	// Ideas and messages use the report target types.
+	screeningFeedback = "feedback"
+	screeningUpdate   = "update"
+)
+
+// screened is content that went through the screening pipeline.
+type screened struct {
+	content screening.Content
+	screening.Result
+}
+
+// screen runs the screening pipeline over text about to be created or edited.
+func screen(c *gin.Context, kind, userID, text string) screened {
+	return screenFrom(c, c.ClientIP(), kind, userID, text)
+}
+
+// screenFrom is screen for callers outside the request, given the client IP.
+func screenFrom(ctx context.Context, ip, kind, userID, text string) screened {
+	content := screening.Content{Kind: kind, UserID: userID, IP: ip, Text: text}
+	return screened{content: content, Result: screening.Default.Screen(ctx, content)}
+}
+
+func (s screened) held() bool     { return s.Verdict == screening.Hold }
+func (s screened) rejected() bool { return s.Verdict == screening.Reject }
+
+// save stores the verdict for moderators and the history-based screeners.
+// Pass the transaction that saves the content so both are written together;
+// contentID is nil for rejected content.
+func (s screened) save(ctx context.Context, db execer, contentID *int) error {
+	reasons, err := json.Marshal(s.Reasons)
+	if err != nil {
+		return err
+	}
+	var excerpt string
+	var reviewStatus *string
+	if s.Verdict != screening.Allow {
+		excerpt = s.content.Text
+	}
+	if s.held() {
+		status := screeningPending
+		reviewStatus = &status
+	}
+	_, err = db.Exec(ctx, `
+		INSERT INTO screening_results (content_type, content_id, author_id, author_key, content_hash, excerpt, verdict, reasons, review_status)
+		VALUES ($1, $2, $3, $4, $5, LEFT($6, 1000), $7, $8, $9)`,
+		s.content.Kind, contentID, nullableID(s.content.UserID), s.content.AuthorKey(), s.Hash, excerpt, string(s.Verdict), reasons, reviewStatus)
+	return err
+}
+
+// respondRejected records a rejected verdict and refuses the request with the
+// reasons that are safe to show to the author.
+func respondRejected(c *gin.Context, s screened) {
+	if err := s.save(c, database.DB, nil); err != nil {
+		fmt.Printf("Failed to save screening result: %v\n", err)
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Your post was rejected by our content filters", "reasons": s.Messages()})
+}
+
+// GetScreenings lists screening verdicts, oldest first. Without filters it is
+// the queue of held content; ?status=, ?verdict= and ?content_type= narrow it.
+func GetScreenings(c *gin.Context) {
+	limit := parseLimit(c.Query("limit"), 50, 200)
+	offset := parseOffset(c.Query("offset"))
+
+	status, verdict := c.Query("status"), c.Query("verdict")
+	if status == "" && verdict == "" {
+		status = screeningPending
+	}
+	query := `
+		SELECT id, content_type, content_id, author_id::text, excerpt, verdict, reasons, review_status, reviewed_by::text, reviewed_at, created_at
+		FROM screening_results WHERE TRUE`
+	args := []interface{}{}
+	argId := 1
+	filters := []struct{ column, value string }{
+		{"review_status", status},
+		{"verdict", verdict},
+		{"content_type", c.Query("content_type")},
+	}
+	for _, f := range filters {
+		if f.value == "" {
+			continue
+		}
+		query += fmt.Sprintf(" AND %s = $%d", f.column, argId)
+		args = append(args, f.value)
+		argId++
+	}
+	query += fmt.Sprintf(" ORDER BY created_at ASC, id ASC LIMIT $%d OFFSET $%d", argId, argId+1)
+	args = append(args, limit, offset)
+
+	rows, err := database.DB.Query(c, query, args...)
+	if err != nil {
+		fmt.Printf("Screenings query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch screening results")
+		return
+	}
+	defer rows.Close()
+
+	results := []models.ScreeningResult{}
+	for rows.Next() {
+		var r models.ScreeningResult
+		if err := rows.Scan(&r.ID, &r.ContentType, &r.ContentID, &r.AuthorID, &r.Excerpt, &r.Verdict, &r.Reasons,
+			&r.ReviewStatus, &r.ReviewedBy, &r.ReviewedAt, &r.CreatedAt); err != nil {
+			fmt.Printf("Screening scan error: %v\n", err)
+			continue
+		}
+		results = append(results, r)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": results, "limit": limit, "offset": offset})
+}
+
+// ApproveScreening releases held content.
+func ApproveScreening(c *gin.Context) {
+	reviewScreening(c, screeningApproved)
+}
+
+// RejectScreening keeps held content hidden for good.
+func RejectScreening(c *gin.Context) {
+	reviewScreening(c, screeningRejected)
+}
+
+func reviewScreening(c *gin.Context, status string) {
+	moderatorID := c.GetString("user_id")
+	var kind string
+	var contentID *int
+	var authorID *string
+	err := database.DB.QueryRow(c, `
+		UPDATE screening_results SET review_status = $1, reviewed_by = $2, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = $3 AND review_status = $4
+		RETURNING content_type, content_id, author_id::text`,
+		status, nullableID(moderatorID), c.Param("id"), screeningPending).Scan(&kind, &contentID, &authorID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		database.DB.QueryRow(c, "SELECT EXISTS(SELECT 1 FROM screening_results WHERE id = $1)", c.Param("id")).Scan(&exists)
+		if !exists {
+			utils.RespondWithError(c, http.StatusNotFound, "Screening result not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusConflict, "Nothing is waiting for review")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to review content")
+		return
+	}
+
+	if status == screeningApproved && contentID != nil {
+		if err := releaseHeldContent(c, kind, *contentID); err != nil {
+			fmt.Printf("Failed to release held %s %d: %v\n", kind, *contentID, err)
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to release content")
+			return
+		}
+	}
+
+	targetID := ""
+	if contentID != nil {
+		targetID = strconv.Itoa(*contentID)
+	}
+	recordModerationAction(c, nil, moderatorID, status+"_screening", kind, targetID, "")
+
+	if authorID != nil && kind != screeningFeedback {
+		var ideaID *int
+		if kind == reportIdea {
+			ideaID = contentID
+		}
+		message := fmt.Sprintf("Your %s was approved by the moderators and is now visible.", kind)
+		if status == screeningRejected {
+			message = fmt.Sprintf("Your %s was reviewed by the moderators and will stay hidden.", kind)
+		}
+		notify(c, *authorID, "", notificationModeration, ideaID, message)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Content " + status})
+}
+
+// releaseHeldContent lifts the screening hold on an idea, message or update
+// once none of its edits are still waiting for review. Moderator hides are
+// kept in hidden_at and stay in place. Content released for the first time
+// goes through the same steps as content published unheld: ideas held since
+// they were posted are announced, and messages reach the chat.
+func releaseHeldContent(ctx context.Context, kind string, id int) error {
+	var table, set string
+	switch kind {
+	case reportIdea:
+		table, set = "ideas", ", updated_at = CURRENT_TIMESTAMP"
+	case reportMessage:
+		table = "messages"
+	case screeningUpdate:
+		table = "idea_updates"
+	default:
+		return nil
+	}
+
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Only content that was still held is released, so a message is pushed
+	// to the chat once, and only if a moderator has not hidden it meanwhile.
+	// Content held at creation has held_at equal to created_at; held edits of
+	// published content do not.
+	var released, heldSinceCreated bool
+	err = tx.QueryRow(ctx, `
+		UPDATE `+table+` t SET held_at = NULL`+set+`
+		FROM `+table+` prev
+		WHERE t.id = $1 AND prev.id = t.id AND t.held_at IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM screening_results WHERE content_type = $2 AND content_id = $1 AND review_status = $3
+		)
+		RETURNING t.hidden_at IS NULL, prev.held_at = prev.created_at`, id, kind, screeningPending).Scan(&released, &heldSinceCreated)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	var m models.Message
+	switch {
+	case kind == reportIdea && released && heldSinceCreated:
+		// announceIdea skips drafts, private and unlisted ideas itself.
+		if err := announceIdea(ctx, tx, id); err != nil {
+			return err
+		}
+	case kind == reportMessage && released:
+		// The message reaches the chat now, as if it had just been sent.
+		err := tx.QueryRow(ctx, "SELECT id, sender_id, receiver_id, COALESCE(conversation_id, 0), content, created_at, delivered_at, read_at FROM messages WHERE id = $1", id).
+			Scan(&m.ID, &m.SenderID, &m.ReceiverID, &m.ConversationID, &m.Content, &m.CreatedAt, &m.DeliveredAt, &m.ReadAt)
+		if err != nil {
+			return err
+		}
+		if m.ConversationID != 0 {
+			if err := bumpConversation(ctx, tx, m); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	switch {
+	case kind == reportIdea:
+		invalidateIdea(ctx, strconv.Itoa(id))
+	case kind == reportMessage && released:
+		publishMessage(ctx, m)
+	}
+	return nil
+}
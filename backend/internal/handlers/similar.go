@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"invesa_backend/internal/cache"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Near-duplicate detection and related ideas, scored in Postgres with pg_trgm
+// so no external search service is needed.
+
+const (
+	duplicateModeOff   = "off"
+	duplicateModeWarn  = "warn"
+	duplicateModeBlock = "block"
+
+	defaultDuplicateThreshold = 0.6
+	maxSimilarIdeas           = 5
+)
+
+// duplicateMode is DUPLICATE_IDEAS: "warn" (default) posts the idea and lists
+// its near-duplicates, "block" refuses it, "off" skips the check.
+func duplicateMode() string {
+	switch mode := os.Getenv("DUPLICATE_IDEAS"); mode {
+	case duplicateModeOff, duplicateModeBlock:
+		return mode
+	}
+	return duplicateModeWarn
+}
+
+// duplicateThreshold is DUPLICATE_SIMILARITY, the score from which an idea
+// counts as a near-duplicate. Values below pg_trgm's similarity_threshold (0.3
+// by default) behave like 0.3, since candidates are found with the % operator.
+func duplicateThreshold() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("DUPLICATE_SIMILARITY"), 64); err == nil && v > 0 && v <= 1 {
+		return v
+	}
+	return defaultDuplicateThreshold
+}
+
+// similarityScore weighs the title over the description, so a reworded title
+// on an unchanged pitch still scores high. $1 is the title, $2 the description.
+const similarityScore = "(0.6 * similarity(ideas.title, $1) + 0.4 * similarity(ideas.description, $2))"
+
+// findDuplicateIdeas returns existing ideas scoring at least threshold against
+// a new title and description. The author's own ideas are compared whatever
+// their visibility; anyone else's only if the author could already see them
+// listed, so the check never reveals private, hidden or held ideas.
+func findDuplicateIdeas(ctx context.Context, author viewer, title, description string, threshold float64) ([]models.SimilarIdea, error) {
+	rows, err := database.DB.Query(ctx, `
+		SELECT ideas.id, ideas.user_id, ideas.title, `+similarityScore+` AS score
+		FROM ideas
+		WHERE (ideas.title % $1 OR ideas.description % $2) AND ideas.deleted_at IS NULL
+			AND (ideas.user_id = $3 OR (ideas.visibility = ANY($4) AND ideas.hidden_at IS NULL AND ideas.held_at IS NULL))
+			AND `+similarityScore+` >= $5
+		ORDER BY score DESC
+		LIMIT $6`,
+		title, description, nullableID(author.ID), author.listedVisibilities(), threshold, maxSimilarIdeas)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var similar []models.SimilarIdea
+	for rows.Next() {
+		var s models.SimilarIdea
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Similarity); err != nil {
+			return nil, err
+		}
+		s.IsOwn = s.UserID == author.ID
+		similar = append(similar, s)
+	}
+	return similar, rows.Err()
+}
+
+// GetRelatedIdeas returns the listed ideas most similar to an idea, scored
+// from title and description trigrams, shared tags and category.
+func GetRelatedIdeas(c *gin.Context) {
+	if _, ok := loadViewableIdea(c, c.Param("id")); !ok {
+		return
+	}
+	ideaID, _ := strconv.Atoi(c.Param("id"))
+	limit := parseLimit(c.Query("limit"), maxSimilarIdeas, 20)
+	v := currentViewer(c)
+
+	// Any idea write can change what is related, and every write bumps the
+	// unfiltered tag, so that is all these entries depend on.
+	var related []models.RelatedIdea
+	key := fmt.Sprintf("related|%s|%d|%d", v.audience(), ideaID, limit)
+	err := cache.Default.Fetch(c, key, []string{ideasTagAll, ideasTagUnfiltered}, &related, func(ctx context.Context) (any, error) {
+		return loadRelatedIdeas(ctx, ideaID, v.listedVisibilities(), limit)
+	})
+	if err != nil {
+		fmt.Printf("Related ideas query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch related ideas")
+		return
+	}
+
+	ptrs := make([]*models.Idea, len(related))
+	for i := range related {
+		ptrs[i] = &related[i].Idea
+	}
+	applyViewerFlags(c, v.ID, ptrs)
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": related})
+}
+
+func loadRelatedIdeas(ctx context.Context, ideaID int, visibilities []string, limit int) ([]models.RelatedIdea, error) {
+	var title, description, category string
+	var tagIDs []int32
+	err := database.DB.QueryRow(ctx, `
+		SELECT title, description, category, COALESCE((SELECT array_agg(tag_id) FROM idea_tags WHERE idea_id = ideas.id), '{}')
+		FROM ideas WHERE id = $1 AND deleted_at IS NULL`, ideaID).Scan(&title, &description, &category, &tagIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Candidates come from the trigram indexes or shared tags; the score adds
+	// the fraction of the idea's tags they share and a small category bonus.
+	rows, err := database.DB.Query(ctx, `
+		SELECT `+ideaColumns+`,
+			0.5 * similarity(ideas.title, $1) + 0.3 * similarity(ideas.description, $2)
+			+ 0.15 * (SELECT COUNT(*) FROM idea_tags it WHERE it.idea_id = ideas.id AND it.tag_id = ANY($3)) / GREATEST(cardinality($3::int[]), 1)
+			+ CASE WHEN ideas.category = $4 THEN 0.05 ELSE 0 END AS score
+		FROM ideas `+ideaJoins+`
+		WHERE ideas.id <> $5 AND ideas.visibility = ANY($6) AND ideas.hidden_at IS NULL AND ideas.held_at IS NULL AND ideas.deleted_at IS NULL
+			AND (ideas.title % $1 OR ideas.description % $2
+				OR ideas.id IN (SELECT idea_id FROM idea_tags WHERE tag_id = ANY($3)))
+		ORDER BY score DESC, ideas.id DESC
+		LIMIT $7`,
+		title, description, tagIDs, category, ideaID, visibilities, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	related := []models.RelatedIdea{}
+	for rows.Next() {
+		var r models.RelatedIdea
+		if err := rows.Scan(append(ideaScanTargets(&r.Idea), &r.Similarity)...); err != nil {
+			return nil, err
+		}
+		related = append(related, r)
+	}
+	return related, rows.Err()
+}
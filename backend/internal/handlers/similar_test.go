@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"invesa_backend/internal/database"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFindDuplicateIdeasVisibility(t *testing.T) {
+	requireTestDB(t)
+	ctx := context.Background()
+	author := createTestUser(t)
+	other := createTestUser(t)
+
+	// A title no other test data shares, so only these ideas can match.
+	title := "Duplicate check " + uuid.New().String()
+	description := "A marketplace for " + title
+	ideaIDs := map[string]int{}
+	for _, idea := range []struct {
+		name, userID, visibility string
+		deleted                  bool
+	}{
+		{"own private", author, visibilityPrivate, false},
+		{"own deleted", author, visibilityPublic, true},
+		{"other private", other, visibilityPrivate, false},
+		{"other public", other, visibilityPublic, false},
+		{"other deleted", other, visibilityPublic, true},
+	} {
+		var id int
+		err := database.DB.QueryRow(ctx, `INSERT INTO ideas (user_id, title, description, visibility, deleted_at)
+			VALUES ($1, $2, $3, $4, CASE WHEN $5 THEN CURRENT_TIMESTAMP END) RETURNING id`,
+			idea.userID, title, description, idea.visibility, idea.deleted).Scan(&id)
+		if err != nil {
+			t.Fatalf("insert %s idea: %v", idea.name, err)
+		}
+		ideaIDs[idea.name] = id
+	}
+
+	similar, err := findDuplicateIdeas(ctx, viewer{ID: author}, title, description, 0.5)
+	if err != nil {
+		t.Fatalf("findDuplicateIdeas: %v", err)
+	}
+	found := map[int]bool{}
+	for _, s := range similar {
+		found[s.ID] = true
+		if want := s.ID == ideaIDs["own private"]; s.IsOwn != want {
+			t.Errorf("idea %d: IsOwn = %v, want %v", s.ID, s.IsOwn, want)
+		}
+	}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"own private", true},
+		{"own deleted", false},
+		{"other private", false},
+		{"other public", true},
+		{"other deleted", false},
+	}
+	for _, tt := range tests {
+		if got := found[ideaIDs[tt.name]]; got != tt.want {
+			t.Errorf("%s idea found = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"invesa_backend/internal/database"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// idea_stats counter columns. Only these names are ever interpolated into SQL.
+const statViews = "views_count"
+
+var reactionStatColumns = map[string]string{
+	reactionLike:        "likes_count",
+	reactionInsightful:  "insightful_count",
+	reactionWouldInvest: "would_invest_count",
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// bumpIdeaStat adds delta to one of an idea's counters. Call it in the same
+// transaction as the write it counts so the two cannot diverge.
+func bumpIdeaStat(ctx context.Context, db execer, ideaID int, column string, delta int) error {
+	_, err := db.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO idea_stats (idea_id, %[1]s) VALUES ($1, GREATEST($2, 0))
+		ON CONFLICT (idea_id) DO UPDATE SET %[1]s = GREATEST(idea_stats.%[1]s + $2, 0), updated_at = CURRENT_TIMESTAMP`, column),
+		ideaID, delta)
+	return err
+}
+
+// ReconcileIdeaStats recomputes every counter from the source tables and
+// rewrites the rows that drifted.
+func ReconcileIdeaStats(ctx context.Context) error {
+	result, err := database.DB.Exec(ctx, `
+		INSERT INTO idea_stats (idea_id, likes_count, insightful_count, would_invest_count, comments_count, views_count, updated_at)
+		SELECT i.id, COALESCE(r.likes, 0), COALESCE(r.insightful, 0), COALESCE(r.would_invest, 0), COALESCE(cm.cnt, 0), COALESCE(v.cnt, 0), CURRENT_TIMESTAMP
+		FROM ideas i
+		LEFT JOIN (
+			SELECT idea_id,
+				COUNT(*) FILTER (WHERE kind = 'like') AS likes,
+				COUNT(*) FILTER (WHERE kind = 'insightful') AS insightful,
+				COUNT(*) FILTER (WHERE kind = 'would_invest') AS would_invest
+			FROM idea_reactions GROUP BY idea_id
+		) r ON r.idea_id = i.id
+		LEFT JOIN (SELECT idea_id, COUNT(*) AS cnt FROM comments GROUP BY idea_id) cm ON cm.idea_id = i.id
+		LEFT JOIN (SELECT idea_id, COUNT(*) AS cnt FROM idea_view_events GROUP BY idea_id) v ON v.idea_id = i.id
+		ON CONFLICT (idea_id) DO UPDATE SET
+			likes_count = EXCLUDED.likes_count,
+			insightful_count = EXCLUDED.insightful_count,
+			would_invest_count = EXCLUDED.would_invest_count,
+			comments_count = EXCLUDED.comments_count,
+			views_count = EXCLUDED.views_count,
+			updated_at = EXCLUDED.updated_at
+		WHERE (idea_stats.likes_count, idea_stats.insightful_count, idea_stats.would_invest_count, idea_stats.comments_count, idea_stats.views_count)
+			IS DISTINCT FROM (EXCLUDED.likes_count, EXCLUDED.insightful_count, EXCLUDED.would_invest_count, EXCLUDED.comments_count, EXCLUDED.views_count)`)
+	if err != nil {
+		return err
+	}
+	if n := result.RowsAffected(); n > 0 {
+		log.Printf("Repaired counters on %d ideas", n)
+		invalidateAllIdeas(ctx)
+	}
+	return nil
+}
+
+// StartStatsReconciler repairs counter drift on every tick until ctx is cancelled.
+func StartStatsReconciler(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "idea stats reconciliation", interval, ReconcileIdeaStats)
+}
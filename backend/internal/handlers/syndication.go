@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/middleware"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Syndication feeds of the latest published ideas, in Atom, RSS 2.0 and JSON
+// Feed 1.1. They accept the same category, tags/tag_mode and author filters as
+// GetIdeas and share its cached query. Feed readers cannot send an
+// Authorization header, so investors subscribe with a private ?token= URL
+// that unlocks investors-only ideas.
+
+const (
+	feedFormatAtom = "atom"
+	feedFormatRSS  = "rss"
+	feedFormatJSON = "json"
+)
+
+var feedContentTypes = map[string]string{
+	feedFormatAtom: "application/atom+xml; charset=utf-8",
+	feedFormatRSS:  "application/rss+xml; charset=utf-8",
+	feedFormatJSON: "application/feed+json; charset=utf-8",
+}
+
+func GetIdeasAtomFeed(c *gin.Context) { serveIdeasFeed(c, feedFormatAtom) }
+func GetIdeasRSSFeed(c *gin.Context)  { serveIdeasFeed(c, feedFormatRSS) }
+func GetIdeasJSONFeed(c *gin.Context) { serveIdeasFeed(c, feedFormatJSON) }
+
+// ideasFeed is the format-independent content of a feed.
+type ideasFeed struct {
+	ID      string
+	Title   string
+	SelfURL string
+	HomeURL string
+	Updated time.Time
+	Ideas   []models.Idea
+	Authors map[string]string // user id -> display name
+}
+
+func serveIdeasFeed(c *gin.Context, format string) {
+	v := viewer{}
+	if token := c.Query("token"); token != "" {
+		var verified bool
+		err := database.DB.QueryRow(c,
+			"SELECT id, role, COALESCE(is_verified, FALSE) FROM users WHERE feed_token = $1", token).Scan(&v.ID, &v.Role, &verified)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusNotFound, "Feed not found")
+			return
+		}
+		v.IsVerifiedInvestor = v.Role == "Investor" && verified
+		// Tokenized URLs are personal and must never land in a shared cache.
+		c.Header("Cache-Control", "private, no-cache")
+	}
+
+	// Drafts and hidden ideas never appear in a feed, even the owner's own.
+	q := ideasQuery{
+		Category:     c.Query("category"),
+		UserID:       c.Query("author"),
+		Tags:         parseTagsQuery(c.Query("tags")),
+		MatchAllTags: c.Query("tag_mode") == "all",
+		Sort:         sortNew,
+		Limit:        parseLimit(c.Query("limit"), 50, 100),
+		Visibilities: v.listedVisibilities(),
+	}
+	if q.Category != "" {
+		q.Category = slugify(q.Category)
+	}
+
+	response, err := q.fetch(c, v)
+	if err != nil {
+		fmt.Printf("Feed query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to build feed")
+		return
+	}
+
+	feed := ideasFeed{
+		ID:      feedID(q),
+		Title:   "Invesa: latest ideas",
+		SelfURL: requestURL(c),
+		HomeURL: utils.FrontendURL(),
+		Ideas:   response.Items,
+		Authors: map[string]string{},
+	}
+	if q.Category != "" && len(feed.Ideas) > 0 {
+		feed.Title += " in " + feed.Ideas[0].CategoryName
+	}
+	for _, idea := range feed.Ideas {
+		if updated := ideaUpdated(idea); updated.After(feed.Updated) {
+			feed.Updated = updated
+		}
+	}
+	if feed.Updated.IsZero() {
+		feed.Updated = time.Unix(0, 0)
+	}
+
+	if middleware.CheckNotModified(c, feedETag(feed), feed.Updated) {
+		return
+	}
+	if err := loadFeedAuthors(c, feed); err != nil {
+		fmt.Printf("Feed authors query error: %v\n", err)
+	}
+
+	var body []byte
+	switch format {
+	case feedFormatAtom:
+		body, err = renderAtom(feed)
+	case feedFormatRSS:
+		body, err = renderRSS(feed)
+	default:
+		body, err = renderJSONFeed(feed)
+	}
+	if err != nil {
+		fmt.Printf("Feed render error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to build feed")
+		return
+	}
+	c.Data(http.StatusOK, feedContentTypes[format], body)
+}
+
+// feedID identifies a filtered feed independently of the host it is served
+// from and of the subscriber's token.
+func feedID(q ideasQuery) string {
+	filters := url.Values{}
+	if q.Category != "" {
+		filters.Set("category", q.Category)
+	}
+	if q.UserID != "" {
+		filters.Set("author", q.UserID)
+	}
+	for _, tag := range q.Tags {
+		filters.Add("tags", tag)
+	}
+	if q.MatchAllTags && len(q.Tags) > 0 {
+		filters.Set("tag_mode", "all")
+	}
+	id := "urn:invesa:feed:ideas"
+	if len(filters) > 0 {
+		id += "?" + filters.Encode()
+	}
+	return id
+}
+
+// ideaGUID never changes for an idea, whatever URL the feed is fetched from.
+func ideaGUID(idea models.Idea) string {
+	return fmt.Sprintf("urn:invesa:idea:%d", idea.ID)
+}
+
+func ideaURL(idea models.Idea) string {
+	return fmt.Sprintf("%s/ideas/%d", utils.FrontendURL(), idea.ID)
+}
+
+// ideaPublished is when an idea became visible. Ideas created before
+// published_at existed fall back to their creation time.
+func ideaPublished(idea models.Idea) time.Time {
+	if idea.PublishedAt != nil {
+		return idea.PublishedAt.UTC()
+	}
+	return idea.CreatedAt.UTC()
+}
+
+// ideaUpdated is when an idea last changed in a way its feed entry shows.
+func ideaUpdated(idea models.Idea) time.Time {
+	published := ideaPublished(idea)
+	if idea.UpdatedAt.UTC().After(published) {
+		return idea.UpdatedAt.UTC()
+	}
+	return published
+}
+
+// feedETag versions a feed by its ideas' ids and update times. Unlike the
+// newest update time alone, it also changes when an idea leaves the feed.
+func feedETag(f ideasFeed) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", f.Title)
+	for _, idea := range f.Ideas {
+		fmt.Fprintf(h, "%d:%d\n", idea.ID, ideaUpdated(idea).UnixNano())
+	}
+	return `W/"` + base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+func requestURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + c.Request.URL.RequestURI()
+}
+
+func loadFeedAuthors(c *gin.Context, feed ideasFeed) error {
+	if len(feed.Ideas) == 0 {
+		return nil
+	}
+	ids := make([]string, len(feed.Ideas))
+	for i, idea := range feed.Ideas {
+		ids[i] = idea.UserID
+	}
+	rows, err := database.DB.Query(c,
+		"SELECT id, COALESCE(NULLIF(full_name, ''), username, 'Invesa member') FROM users WHERE id = ANY($1)", ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		feed.Authors[id] = name
+	}
+	return rows.Err()
+}
+
+func (f ideasFeed) author(idea models.Idea) string {
+	if name, ok := f.Authors[idea.UserID]; ok {
+		return name
+	}
+	return "Invesa member"
+}
+
+// Atom (RFC 4287)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+}
+
+func renderAtom(f ideasFeed) ([]byte, error) {
+	feed := atomFeed{
+		ID:      f.ID,
+		Title:   f.Title,
+		Updated: f.Updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.SelfURL},
+			{Rel: "alternate", Type: "text/html", Href: f.HomeURL},
+		},
+		Author: atomAuthor{Name: "Invesa"},
+	}
+	for _, idea := range f.Ideas {
+		entry := atomEntry{
+			ID:         ideaGUID(idea),
+			Title:      idea.Title,
+			Link:       atomLink{Rel: "alternate", Type: "text/html", Href: ideaURL(idea)},
+			Published:  ideaPublished(idea).Format(time.RFC3339),
+			Updated:    ideaUpdated(idea).Format(time.RFC3339),
+			Author:     atomAuthor{Name: f.author(idea)},
+			Categories: []atomCategory{{Term: idea.Category, Label: idea.CategoryName}},
+			Summary:    idea.Description,
+		}
+		for _, tag := range idea.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	return marshalXML(feed)
+}
+
+// RSS 2.0
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+func renderRSS(f ideasFeed) ([]byte, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.HomeURL,
+			Description:   "The latest ideas published on Invesa.",
+			LastBuildDate: f.Updated.Format(time.RFC1123Z),
+			SelfLink:      atomLink{Rel: "self", Type: "application/rss+xml", Href: f.SelfURL},
+		},
+	}
+	for _, idea := range f.Ideas {
+		item := rssItem{
+			Title:       idea.Title,
+			Link:        ideaURL(idea),
+			GUID:        rssGUID{Value: ideaGUID(idea)},
+			PubDate:     ideaPublished(idea).Format(time.RFC1123Z),
+			Creator:     f.author(idea),
+			Categories:  append([]string{idea.CategoryName}, idea.Tags...),
+			Description: idea.Description,
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	return marshalXML(feed)
+}
+
+func marshalXML(v any) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// JSON Feed 1.1 (https://jsonfeed.org/version/1.1)
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentText   string           `json:"content_text"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+	Tags          []string         `json:"tags"`
+}
+
+func renderJSONFeed(f ideasFeed) ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     f.SelfURL,
+		Items:       []jsonFeedItem{},
+	}
+	for _, idea := range f.Ideas {
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            ideaGUID(idea),
+			URL:           ideaURL(idea),
+			Title:         idea.Title,
+			ContentText:   idea.Description,
+			DatePublished: ideaPublished(idea).Format(time.RFC3339),
+			DateModified:  ideaUpdated(idea).Format(time.RFC3339),
+			Authors:       []jsonFeedAuthor{{Name: f.author(idea)}},
+			Tags:          append([]string{idea.CategoryName}, idea.Tags...),
+		})
+	}
+	return json.MarshalIndent(feed, "", "  ")
+}
+
+// GetFeedToken returns the caller's private feed token, creating it on first
+// use. Anyone holding the token sees the feed as the caller would.
+func GetFeedToken(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var token *string
+	if err := database.DB.QueryRow(c, "SELECT feed_token FROM users WHERE id = $1", userID).Scan(&token); err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+	if token == nil {
+		issueFeedToken(c, userID)
+		return
+	}
+	respondWithFeedToken(c, *token)
+}
+
+// RotateFeedToken replaces the caller's feed token, revoking every URL built
+// from the old one.
+func RotateFeedToken(c *gin.Context) {
+	userID := c.GetString("user_id")
+	utils.LogActivity(c, userID, "ROTATE_FEED_TOKEN", "")
+	issueFeedToken(c, userID)
+}
+
+func issueFeedToken(c *gin.Context, userID string) {
+	token, err := newShareToken()
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create feed token")
+		return
+	}
+	if _, err := database.DB.Exec(c, "UPDATE users SET feed_token = $1 WHERE id = $2", token, userID); err != nil {
+		fmt.Printf("Feed token update error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create feed token")
+		return
+	}
+	respondWithFeedToken(c, token)
+}
+
+func respondWithFeedToken(c *gin.Context, token string) {
+	query := "?token=" + url.QueryEscape(token)
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{
+		"token": token,
+		"feeds": gin.H{
+			feedFormatAtom: "/api/feeds/ideas.atom" + query,
+			feedFormatRSS:  "/api/feeds/ideas.rss" + query,
+			feedFormatJSON: "/api/feeds/ideas.json" + query,
+		},
+	})
+}
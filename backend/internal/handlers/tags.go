@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxTagsPerIdea = 10
+
+// GetTags powers tag autocomplete: ?prefix=fin returns the most used tags starting with "fin".
+func GetTags(c *gin.Context) {
+	prefix := slugify(c.Query("prefix"))
+	limit := parseLimit(c.Query("limit"), 10, 50)
+
+	rows, err := database.DB.Query(c, `
+		SELECT t.name, COUNT(it.idea_id) AS usage_count
+		FROM tags t
+		LEFT JOIN idea_tags it ON it.tag_id = t.id AND EXISTS(SELECT 1 FROM ideas WHERE ideas.id = it.idea_id AND ideas.deleted_at IS NULL)
+		WHERE t.name LIKE $1
+		GROUP BY t.id, t.name
+		ORDER BY usage_count DESC, t.name ASC
+		LIMIT $2`, prefix+"%", limit)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var t models.Tag
+		if err := rows.Scan(&t.Name, &t.UsageCount); err != nil {
+			continue
+		}
+		tags = append(tags, t)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": tags})
+}
+
+// errTooManyTags rejects an idea with more than maxTagsPerIdea distinct tags.
+var errTooManyTags = fmt.Errorf("Ideas can have at most %d tags", maxTagsPerIdea)
+
+// normalizeTags slugifies, dedupes and sorts tags. It fails with
+// errTooManyTags rather than drop any when more than maxTagsPerIdea remain.
+func normalizeTags(raw []string) ([]string, error) {
+	tags := slugifyTags(raw)
+	if len(tags) > maxTagsPerIdea {
+		return nil, errTooManyTags
+	}
+	return tags, nil
+}
+
+// slugifyTags slugifies, dedupes and sorts tags.
+func slugifyTags(raw []string) []string {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, t := range raw {
+		name := slugify(t)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// parseTagsQuery reads a comma-separated ?tags= filter. Filters are not
+// idea tags, so maxTagsPerIdea does not apply.
+func parseTagsQuery(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return slugifyTags(strings.Split(value, ","))
+}
+
+// setIdeaTags replaces the tags on an idea, creating any tags that do not exist yet.
+func setIdeaTags(c *gin.Context, tx pgx.Tx, ideaID int, tags []string) error {
+	if _, err := tx.Exec(c, "DELETE FROM idea_tags WHERE idea_id = $1", ideaID); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	if _, err := tx.Exec(c, "INSERT INTO tags (name) SELECT UNNEST($1::text[]) ON CONFLICT (name) DO NOTHING", tags); err != nil {
+		return err
+	}
+	_, err := tx.Exec(c,
+		"INSERT INTO idea_tags (idea_id, tag_id) SELECT $1, id FROM tags WHERE name = ANY($2) ON CONFLICT DO NOTHING",
+		ideaID, tags)
+	return err
+}
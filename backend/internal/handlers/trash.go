@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const defaultTrashRetentionDays = 30
+
+// GetTrash lists the caller's deleted ideas, most recently deleted first.
+func GetTrash(c *gin.Context) {
+	userID := c.GetString("user_id")
+	limit := parseLimit(c.Query("limit"), 20, 100)
+	offset := parseOffset(c.Query("offset"))
+
+	rows, err := database.DB.Query(c, `
+		SELECT `+ideaColumns+`, ideas.deleted_at
+		FROM ideas `+ideaJoins+`
+		WHERE ideas.user_id = $1 AND ideas.deleted_at IS NOT NULL
+		ORDER BY ideas.deleted_at DESC, ideas.id DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		fmt.Printf("Trash query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch trash")
+		return
+	}
+	defer rows.Close()
+
+	items := []models.TrashedIdea{}
+	for rows.Next() {
+		var t models.TrashedIdea
+		if err := rows.Scan(append(ideaScanTargets(&t.Idea), &t.DeletedAt)...); err != nil {
+			fmt.Printf("Trash scan error: %v\n", err)
+			continue
+		}
+		t.PurgeAt = purgeTime(t.DeletedAt)
+		items = append(items, t)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": items, "limit": limit, "offset": offset})
+}
+
+// RestoreIdea takes one of the caller's ideas out of the trash.
+func RestoreIdea(c *gin.Context) {
+	restoreIdea(c, c.GetString("user_id"))
+}
+
+// AdminRestoreIdea restores any trashed idea that has not been purged yet.
+func AdminRestoreIdea(c *gin.Context) {
+	restoreIdea(c, "")
+}
+
+func restoreIdea(c *gin.Context, ownerID string) {
+	ideaID := c.Param("id")
+	query := "UPDATE ideas SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NOT NULL"
+	args := []interface{}{ideaID}
+	if ownerID != "" {
+		query += " AND user_id = $2 AND deleted_at > CURRENT_TIMESTAMP - make_interval(days => $3)"
+		args = append(args, ownerID, trashRetentionDays())
+	}
+
+	var category, authorID string
+	err := database.DB.QueryRow(c, query+" RETURNING category, user_id", args...).Scan(&category, &authorID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found in trash")
+		return
+	}
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to restore idea")
+		return
+	}
+
+	if ownerID != "" {
+		utils.LogActivity(c, ownerID, "RESTORE_IDEA", "Restored idea "+ideaID+" from the trash")
+	}
+	invalidateIdeaListings(c, category, authorID)
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Idea restored"})
+}
+
+// AdminPurgeIdea permanently deletes an idea, trashed or not.
+func AdminPurgeIdea(c *gin.Context) {
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+	n, err := purgeIdeas(c, "id = $1", ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to purge idea")
+		return
+	}
+	if n == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return
+	}
+
+	invalidateAllIdeas(c)
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Idea purged"})
+}
+
+// PurgeTrash permanently deletes ideas that have been in the trash longer
+// than the retention period.
+func PurgeTrash(ctx context.Context) error {
+	n, err := purgeIdeas(ctx, "deleted_at < CURRENT_TIMESTAMP - make_interval(days => $1)", trashRetentionDays())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Purged %d ideas from the trash", n)
+	}
+	return nil
+}
+
+// StartTrashPurger empties expired trash on every tick until ctx is cancelled.
+func StartTrashPurger(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "trash purge", interval, PurgeTrash)
+}
+
+// purgeIdeas deletes the ideas matching where. Related rows cascade with them;
+// attachment blobs are collected in the same statement and removed afterwards.
+func purgeIdeas(ctx context.Context, where string, args ...interface{}) (int, error) {
+	var n int
+	var blobKeys []string
+	err := database.DB.QueryRow(ctx, `
+		WITH purged AS (DELETE FROM ideas WHERE `+where+` RETURNING id)
+		SELECT (SELECT COUNT(*) FROM purged),
+			COALESCE((SELECT array_agg(a.storage_key) FROM idea_attachments a WHERE a.idea_id IN (SELECT id FROM purged)), '{}')`,
+		args...).Scan(&n, &blobKeys)
+	if err != nil {
+		return 0, err
+	}
+	deleteBlobs(blobKeys)
+	return n, nil
+}
+
+// purgeTime is when an idea trashed at deletedAt will be purged.
+func purgeTime(deletedAt time.Time) time.Time {
+	return deletedAt.AddDate(0, 0, trashRetentionDays())
+}
+
+// trashRetentionDays is how long trashed ideas can be restored (TRASH_RETENTION_DAYS).
+func trashRetentionDays() int {
+	if v, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && v > 0 {
+		return v
+	}
+	return defaultTrashRetentionDays
+}
@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Update visibilities. Supporter updates are for the founder's followers and
+// verified investors.
+const (
+	updateVisibilityPublic     = "public"
+	updateVisibilitySupporters = "supporters"
+
+	notificationIdeaUpdate = "idea_update"
+
+	defaultUpdateDigestMinutes = 30
+	// updateDigestMaxWaitHours delivers a steady stream of updates at least daily,
+	// even if the idea never goes quiet for the digest period.
+	updateDigestMaxWaitHours = 24
+	updateDeliveryBatchSize  = 200
+)
+
+type IdeaUpdateRequest struct {
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	Visibility string     `json:"visibility"` // public (default) or supporters
+	PublishAt  *time.Time `json:"publish_at"` // Create only: publish later instead of now
+}
+
+// validate normalizes the request and returns a message if it is invalid.
+func (r *IdeaUpdateRequest) validate() string {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Body = strings.TrimSpace(r.Body)
+	if r.Visibility == "" {
+		r.Visibility = updateVisibilityPublic
+	}
+	switch {
+	case r.Title == "" || r.Body == "":
+		return "Title and body are required"
+	case len(r.Title) > 255:
+		return "Title is too long"
+	case r.Visibility != updateVisibilityPublic && r.Visibility != updateVisibilitySupporters:
+		return "Visibility must be public or supporters"
+	case r.PublishAt != nil:
+		return scheduleError(*r.PublishAt, r.Visibility)
+	}
+	return ""
+}
+
+// CreateIdeaUpdate posts a progress update on an idea. The owner and editors
+// can post; followers and supporters get it in the next delivery batch.
+func CreateIdeaUpdate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid idea id")
+		return
+	}
+	var req IdeaUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if msg := req.validate(); msg != "" {
+		utils.RespondWithError(c, http.StatusBadRequest, msg)
+		return
+	}
+	if !requireIdeaRole(c, database.DB, ideaID, roleEditor, "Only the owner and editors can post updates") {
+		return
+	}
+
+	result := screen(c, screeningUpdate, userID, req.Title+"\n\n"+req.Body)
+	if result.rejected() {
+		respondRejected(c, result)
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to post update")
+		return
+	}
+	defer tx.Rollback(c)
+
+	// Held updates are saved hidden until a moderator approves them.
+	var update models.IdeaUpdate
+	err = tx.QueryRow(c, `
+		INSERT INTO idea_updates (idea_id, author_id, title, body, visibility, publish_at, published_at, held_at)
+		VALUES ($1, $2, $3, $4, $5, $6::timestamptz, CASE WHEN $6::timestamptz IS NULL THEN CURRENT_TIMESTAMP END, CASE WHEN $7 THEN CURRENT_TIMESTAMP END)
+		RETURNING id, publish_at, published_at, created_at, updated_at`,
+		ideaID, userID, req.Title, req.Body, req.Visibility, req.PublishAt, result.held()).
+		Scan(&update.ID, &update.PublishAt, &update.PublishedAt, &update.CreatedAt, &update.UpdatedAt)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to post update")
+		return
+	}
+	if err := result.save(c, tx, &update.ID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to post update")
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to post update")
+		return
+	}
+
+	update.IdeaID, update.AuthorID, update.Title, update.Body, update.Visibility = ideaID, &userID, req.Title, req.Body, req.Visibility
+	update.IsHidden = result.held()
+	utils.LogActivity(c, userID, "POST_IDEA_UPDATE", fmt.Sprintf("Posted update %d on idea %d", update.ID, ideaID))
+
+	resp := gin.H{"message": "Update posted", "update": update}
+	if req.PublishAt != nil {
+		resp["message"] = "Update scheduled"
+	}
+	if result.held() {
+		resp["message"] = "Update submitted and held for review"
+		resp["held_for_review"] = true
+	}
+	utils.RespondWithJSON(c, http.StatusCreated, resp)
+}
+
+// GetIdeaUpdates is an idea's updates timeline, newest first. Supporter
+// updates are only listed for followers of the founder, verified investors
+// and the team; the team also sees scheduled and held updates.
+func GetIdeaUpdates(c *gin.Context) {
+	ideaID := c.Param("id")
+	ownerID, ok := loadViewableIdea(c, ideaID)
+	if !ok {
+		return
+	}
+	limit := parseLimit(c.Query("limit"), 20, 100)
+	offset := parseOffset(c.Query("offset"))
+
+	v := currentViewer(c)
+	role, err := ideaRole(c, database.DB, ideaID, v.ID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch updates")
+		return
+	}
+	onTeam := role != ""
+	supporter := onTeam || v.IsVerifiedInvestor
+	if !supporter && v.ID != "" {
+		if err := database.DB.QueryRow(c,
+			"SELECT EXISTS(SELECT 1 FROM user_follows WHERE follower_id = $1 AND followed_id = $2)",
+			v.ID, ownerID).Scan(&supporter); err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch updates")
+			return
+		}
+	}
+
+	rows, err := database.DB.Query(c, `
+		SELECT u.id, u.idea_id, u.author_id::text, COALESCE(NULLIF(a.full_name, ''), a.username, ''), u.title, u.body, u.visibility,
+			u.publish_at, u.published_at, (u.hidden_at IS NOT NULL OR u.held_at IS NOT NULL), u.created_at, u.updated_at
+		FROM idea_updates u
+		LEFT JOIN users a ON a.id = u.author_id
+		WHERE u.idea_id = $1
+			AND ($2 OR (u.published_at IS NOT NULL AND u.hidden_at IS NULL AND u.held_at IS NULL))
+			AND (u.visibility = $3 OR $4)
+		ORDER BY COALESCE(u.published_at, u.publish_at, u.created_at) DESC, u.id DESC
+		LIMIT $5 OFFSET $6`, ideaID, onTeam, updateVisibilityPublic, supporter, limit, offset)
+	if err != nil {
+		fmt.Printf("Updates query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch updates")
+		return
+	}
+	defer rows.Close()
+
+	items := []models.IdeaUpdate{}
+	for rows.Next() {
+		var u models.IdeaUpdate
+		if err := rows.Scan(&u.ID, &u.IdeaID, &u.AuthorID, &u.AuthorName, &u.Title, &u.Body, &u.Visibility,
+			&u.PublishAt, &u.PublishedAt, &u.IsHidden, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			fmt.Printf("Scan error: %v\n", err)
+			continue
+		}
+		items = append(items, u)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": items, "limit": limit, "offset": offset})
+}
+
+// EditIdeaUpdate changes an update's title, body or visibility. Edits are
+// screened again; a delivered update is not sent out a second time.
+func EditIdeaUpdate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID := c.Param("id")
+	updateID, err := strconv.Atoi(c.Param("updateId"))
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid update id")
+		return
+	}
+	var req IdeaUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.PublishAt = nil
+	if msg := req.validate(); msg != "" {
+		utils.RespondWithError(c, http.StatusBadRequest, msg)
+		return
+	}
+	if !requireIdeaRole(c, database.DB, ideaID, roleEditor, "Only the owner and editors can edit updates") {
+		return
+	}
+
+	result := screen(c, screeningUpdate, userID, req.Title+"\n\n"+req.Body)
+	if result.rejected() {
+		respondRejected(c, result)
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to edit update")
+		return
+	}
+	defer tx.Rollback(c)
+
+	tag, err := tx.Exec(c, `
+		UPDATE idea_updates SET title = $1, body = $2, visibility = $3, updated_at = CURRENT_TIMESTAMP,
+			held_at = CASE WHEN $4 THEN CURRENT_TIMESTAMP ELSE held_at END
+		WHERE id = $5 AND idea_id = $6`,
+		req.Title, req.Body, req.Visibility, result.held(), updateID, ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to edit update")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "Update not found")
+		return
+	}
+	if err := result.save(c, tx, &updateID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to edit update")
+		return
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to edit update")
+		return
+	}
+
+	resp := gin.H{"message": "Update edited"}
+	if result.held() {
+		resp["message"] = "Update edited and held for review"
+		resp["held_for_review"] = true
+	}
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+// DeleteIdeaUpdate removes an update, or cancels a scheduled one.
+func DeleteIdeaUpdate(c *gin.Context) {
+	ideaID := c.Param("id")
+	if !requireIdeaRole(c, database.DB, ideaID, roleEditor, "Only the owner and editors can delete updates") {
+		return
+	}
+
+	tag, err := database.DB.Exec(c, "DELETE FROM idea_updates WHERE id = $1 AND idea_id = $2", c.Param("updateId"), ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to delete update")
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "Update not found")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Update deleted"})
+}
+
+// publishScheduledUpdates publishes updates whose scheduled time has passed.
+// Delivery picks them up from there like any other update.
+func publishScheduledUpdates(ctx context.Context) error {
+	tag, err := database.DB.Exec(ctx, `
+		UPDATE idea_updates SET published_at = publish_at, publish_at = NULL
+		WHERE id IN (
+			SELECT id FROM idea_updates
+			WHERE publish_at <= CURRENT_TIMESTAMP
+			ORDER BY publish_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) AND publish_at IS NOT NULL`, scheduledBatchSize)
+	if err != nil {
+		return err
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		log.Printf("Published %d scheduled updates", n)
+	}
+	return nil
+}
+
+// DeliverIdeaUpdates sends published updates to followers of the founder and
+// to users who liked or saved the idea. Updates are batched per idea: an
+// idea's pending updates go out once it has been quiet for the digest period,
+// and each recipient gets one notification per idea and one email per run.
+func DeliverIdeaUpdates(ctx context.Context) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Held updates and updates on hidden ideas wait; SKIP LOCKED keeps
+	// concurrent instances from delivering the same update twice.
+	rows, err := tx.Query(ctx, `
+		SELECT u.id FROM idea_updates u
+		JOIN ideas i ON i.id = u.idea_id
+		WHERE u.delivered_at IS NULL AND u.published_at IS NOT NULL AND u.hidden_at IS NULL AND u.held_at IS NULL
+			AND i.hidden_at IS NULL AND i.held_at IS NULL AND i.deleted_at IS NULL
+			AND u.idea_id IN (
+				SELECT idea_id FROM idea_updates
+				WHERE delivered_at IS NULL AND published_at IS NOT NULL AND hidden_at IS NULL AND held_at IS NULL
+				GROUP BY idea_id
+				HAVING MAX(published_at) <= CURRENT_TIMESTAMP - make_interval(mins => $1)
+					OR MIN(published_at) <= CURRENT_TIMESTAMP - make_interval(hours => $2)
+			)
+		ORDER BY u.published_at
+		LIMIT $3
+		FOR UPDATE OF u SKIP LOCKED`, updateDigestMinutes(), updateDigestMaxWaitHours, updateDeliveryBatchSize)
+	if err != nil {
+		return err
+	}
+	updateIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+	if len(updateIDs) == 0 {
+		return nil
+	}
+
+	digests, err := updateRecipients(ctx, tx, updateIDs)
+	if err != nil {
+		return err
+	}
+
+	var userIDs, actorIDs, messages []string
+	var ideaIDs []int
+	for _, d := range digests {
+		for _, n := range d.perIdea() {
+			userIDs = append(userIDs, d.userID)
+			actorIDs = append(actorIDs, n.ownerID)
+			ideaIDs = append(ideaIDs, n.ideaID)
+			messages = append(messages, n.message())
+		}
+	}
+	if len(userIDs) > 0 {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO notifications (user_id, actor_id, type, idea_id, message)
+			SELECT t.user_id, t.actor_id, $5, t.idea_id, t.message
+			FROM unnest($1::uuid[], $2::uuid[], $3::int[], $4::text[]) AS t(user_id, actor_id, idea_id, message)`,
+			userIDs, actorIDs, ideaIDs, messages, notificationIdeaUpdate)
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, "UPDATE idea_updates SET delivered_at = CURRENT_TIMESTAMP WHERE id = ANY($1)", updateIDs); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// Emails go out after the commit; a failed send is not retried.
+	for _, d := range digests {
+		if err := utils.SendUpdatesDigestEmail(d.email, d.updates); err != nil {
+			log.Printf("Failed to email updates digest to %s: %v", d.userID, err)
+		}
+	}
+	log.Printf("Delivered %d idea updates to %d users", len(updateIDs), len(digests))
+	return nil
+}
+
+// StartUpdateDelivery runs DeliverIdeaUpdates on every tick until ctx is
+// cancelled.
+func StartUpdateDelivery(ctx context.Context, interval time.Duration) {
+	runPeriodically(ctx, "idea update delivery", interval, DeliverIdeaUpdates)
+}
+
+// updateDigest is everything one recipient gets from a delivery run.
+type updateDigest struct {
+	userID, email string
+	updates       []utils.DigestUpdate
+	owners        map[int]string // Idea id to its owner, the notification actor
+}
+
+type ideaUpdateNotice struct {
+	ideaID           int
+	ownerID, title   string
+	count            int
+	firstUpdateTitle string
+}
+
+func (n ideaUpdateNotice) message() string {
+	if n.count == 1 {
+		return fmt.Sprintf("New update on %q: %s", n.title, n.firstUpdateTitle)
+	}
+	return fmt.Sprintf("%d new updates on %q", n.count, n.title)
+}
+
+// perIdea groups the digest into one notification per idea, in order.
+func (d updateDigest) perIdea() []ideaUpdateNotice {
+	var notices []ideaUpdateNotice
+	index := map[int]int{}
+	for _, u := range d.updates {
+		i, ok := index[u.IdeaID]
+		if !ok {
+			i = len(notices)
+			index[u.IdeaID] = i
+			notices = append(notices, ideaUpdateNotice{ideaID: u.IdeaID, ownerID: d.owners[u.IdeaID], title: u.IdeaTitle, firstUpdateTitle: u.Title})
+		}
+		notices[i].count++
+	}
+	return notices
+}
+
+// updateRecipients works out who receives each update. The audience of an
+// idea is the founder's followers plus users who liked or saved it, limited
+// to those who can see the idea; supporter updates additionally need a
+// follower or a verified investor. The idea's team is never notified.
+func updateRecipients(ctx context.Context, tx pgx.Tx, updateIDs []int) ([]*updateDigest, error) {
+	rows, err := tx.Query(ctx, `
+		WITH batch AS (
+			SELECT u.id, u.idea_id, u.title, u.visibility FROM idea_updates u WHERE u.id = ANY($1)
+		),
+		audience AS (
+			SELECT idea_id, user_id, bool_or(follower) AS follower FROM (
+				SELECT i.id AS idea_id, f.follower_id AS user_id, TRUE AS follower
+				FROM ideas i JOIN user_follows f ON f.followed_id = i.user_id
+				WHERE i.id IN (SELECT idea_id FROM batch)
+				UNION ALL
+				SELECT r.idea_id, r.user_id, FALSE FROM idea_reactions r
+				WHERE r.kind = 'like' AND r.idea_id IN (SELECT idea_id FROM batch)
+				UNION ALL
+				SELECT wi.idea_id, w.user_id, FALSE FROM watchlist_items wi JOIN watchlists w ON w.id = wi.watchlist_id
+				WHERE wi.idea_id IN (SELECT idea_id FROM batch)
+			) a
+			GROUP BY idea_id, user_id
+		)
+		SELECT ru.id, ru.email, i.id, i.user_id, i.title, b.title
+		FROM batch b
+		JOIN ideas i ON i.id = b.idea_id
+		JOIN audience a ON a.idea_id = b.idea_id
+		JOIN users ru ON ru.id = a.user_id
+		WHERE a.user_id <> i.user_id
+			AND NOT EXISTS (SELECT 1 FROM idea_members m WHERE m.idea_id = i.id AND m.user_id = a.user_id)
+			AND (i.visibility = $2 OR (i.visibility = $3 AND ru.role = 'Investor' AND COALESCE(ru.is_verified, FALSE)))
+			AND (b.visibility = $4 OR a.follower OR (ru.role = 'Investor' AND COALESCE(ru.is_verified, FALSE)))
+		ORDER BY ru.id, i.id, b.id`,
+		updateIDs, visibilityPublic, visibilityInvestors, updateVisibilityPublic)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var digests []*updateDigest
+	for rows.Next() {
+		var userID, email, ownerID, ideaTitle, updateTitle string
+		var ideaID int
+		if err := rows.Scan(&userID, &email, &ideaID, &ownerID, &ideaTitle, &updateTitle); err != nil {
+			return nil, err
+		}
+		if len(digests) == 0 || digests[len(digests)-1].userID != userID {
+			digests = append(digests, &updateDigest{userID: userID, email: email, owners: map[int]string{}})
+		}
+		d := digests[len(digests)-1]
+		d.owners[ideaID] = ownerID
+		d.updates = append(d.updates, utils.DigestUpdate{IdeaID: ideaID, IdeaTitle: ideaTitle, Title: updateTitle})
+	}
+	return digests, rows.Err()
+}
+
+// updateDigestMinutes is how long an idea must go without a new update before
+// its pending updates are delivered (UPDATE_DIGEST_MINUTES).
+func updateDigestMinutes() int {
+	if v, err := strconv.Atoi(os.Getenv("UPDATE_DIGEST_MINUTES")); err == nil && v >= 0 {
+		return v
+	}
+	return defaultUpdateDigestMinutes
+}
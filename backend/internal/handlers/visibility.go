@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	visibilityDraft     = "draft"
+	visibilityPublic    = "public"
+	visibilityUnlisted  = "unlisted"
+	visibilityInvestors = "investors_only"
+	visibilityPrivate   = "private"
+)
+
+// visibilityTransitions is the idea visibility state machine. Drafts leave
+// through PublishIdea; once published an idea can move between the published
+// states but never back to draft.
+var visibilityTransitions = map[string][]string{
+	visibilityDraft:     {visibilityPublic, visibilityUnlisted, visibilityInvestors, visibilityPrivate},
+	visibilityPublic:    {visibilityUnlisted, visibilityInvestors, visibilityPrivate},
+	visibilityUnlisted:  {visibilityPublic, visibilityInvestors, visibilityPrivate},
+	visibilityInvestors: {visibilityPublic, visibilityUnlisted, visibilityPrivate},
+	visibilityPrivate:   {visibilityPublic, visibilityUnlisted, visibilityInvestors},
+}
+
+func canTransition(from, to string) bool {
+	for _, next := range visibilityTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// viewer is the caller as far as visibility rules are concerned.
+type viewer struct {
+	ID                 string
+	Role               string
+	IsVerifiedInvestor bool
+}
+
+// currentViewer loads the authenticated caller, if any. Anonymous callers get a zero viewer.
+func currentViewer(c *gin.Context) viewer {
+	return loadViewer(c, c.GetString("user_id"))
+}
+
+// loadViewer loads the user with the given id as a viewer. An empty id is anonymous.
+func loadViewer(ctx context.Context, userID string) viewer {
+	v := viewer{ID: userID}
+	if v.ID == "" {
+		return v
+	}
+	var verified bool
+	err := database.DB.QueryRow(ctx,
+		"SELECT role, COALESCE(is_verified, FALSE) FROM users WHERE id = $1", v.ID).Scan(&v.Role, &verified)
+	if err != nil {
+		return viewer{ID: v.ID}
+	}
+	v.IsVerifiedInvestor = v.Role == "Investor" && verified
+	return v
+}
+
+// audience groups viewers who are allowed to see exactly the same listings,
+// so it is safe to share cached listings within an audience.
+func (v viewer) audience() string {
+	if v.IsVerifiedInvestor {
+		return "investor"
+	}
+	return "public"
+}
+
+// listedVisibilities are the visibilities that appear in shared listings for v.
+// Owners see their own non-public ideas only when filtering by their own user id.
+func (v viewer) listedVisibilities() []string {
+	if v.IsVerifiedInvestor {
+		return []string{visibilityPublic, visibilityInvestors}
+	}
+	return []string{visibilityPublic}
+}
+
+// canView reports whether v may open a single idea. Unlisted ideas need the
+// share token; ideas hidden by moderation are left to the idea's team.
+func (v viewer) canView(onTeam bool, visibility, shareToken, providedToken string, hidden bool) bool {
+	if onTeam {
+		return true
+	}
+	if hidden {
+		return false
+	}
+	switch visibility {
+	case visibilityPublic:
+		return true
+	case visibilityInvestors:
+		return v.IsVerifiedInvestor
+	case visibilityUnlisted:
+		return shareToken != "" && providedToken == shareToken
+	}
+	return false
+}
+
+// GetIdea returns a single idea the caller is allowed to see.
+// Unlisted ideas are opened with ?share=<token>.
+func GetIdea(c *gin.Context) {
+	ideaID := c.Param("id")
+	v := currentViewer(c)
+
+	var idea models.Idea
+	var shareToken *string
+	var details, memberRole string
+	targets := append(ideaScanTargets(&idea), &shareToken, &details, &memberRole)
+	err := database.DB.QueryRow(c, "SELECT "+ideaColumns+", ideas.share_token, ideas.confidential_details, "+memberRoleExpr+" FROM ideas "+ideaJoins+" WHERE ideas.id = $1 AND ideas.deleted_at IS NULL", ideaID, v.ID).Scan(targets...)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return
+	}
+
+	token := ""
+	if shareToken != nil {
+		token = *shareToken
+	}
+	role := memberRole
+	if v.ID != "" && v.ID == idea.UserID {
+		role = roleOwner
+	}
+	if !v.canView(role != "", idea.Visibility, token, c.Query("share"), idea.IsHidden) {
+		// Do not reveal that a hidden idea exists.
+		utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+		return
+	}
+	recordIdeaView(c, idea.ID, idea.UserID, v)
+	applyViewerFlags(c, v.ID, []*models.Idea{&idea})
+
+	canEdit := roleRank[role] >= roleRank[roleEditor]
+	if canEdit {
+		idea.ShareToken = token
+	}
+	// Confidential details are released to the owner and editors, and to
+	// signers of the current NDA. Without an NDA they stay with the team.
+	if canEdit || (idea.NDARequired && v.ID != "" && hasNDAAccess(c, idea.ID, v.ID)) {
+		if details != "" {
+			idea.ConfidentialDetails = &details
+		}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, idea)
+}
+
+type VisibilityRequest struct {
+	Visibility string     `json:"visibility"`
+	PublishAt  *time.Time `json:"publish_at"` // PublishIdea only: publish later instead of now
+}
+
+// PublishIdea moves a draft to a published visibility (public by default).
+// With publish_at the draft is scheduled instead; scheduling again replaces
+// the previous time.
+func PublishIdea(c *gin.Context) {
+	var req VisibilityRequest
+	// The body is optional.
+	_ = c.ShouldBindJSON(&req)
+	if req.Visibility == "" {
+		req.Visibility = visibilityPublic
+	}
+	if req.PublishAt != nil {
+		if msg := scheduleError(*req.PublishAt, req.Visibility); msg != "" {
+			utils.RespondWithError(c, http.StatusBadRequest, msg)
+			return
+		}
+	}
+	setVisibility(c, req.Visibility, true, req.PublishAt)
+}
+
+// UpdateIdeaVisibility moves a published idea between published visibilities.
+func UpdateIdeaVisibility(c *gin.Context) {
+	var req VisibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Visibility == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "Visibility is required")
+		return
+	}
+	setVisibility(c, req.Visibility, false, nil)
+}
+
+func setVisibility(c *gin.Context, to string, publishing bool, publishAt *time.Time) {
+	ideaID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	if _, ok := visibilityTransitions[to]; !ok {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid visibility")
+		return
+	}
+
+	tx, err := database.DB.Begin(c)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	defer tx.Rollback(c)
+
+	var ownerID, from string
+	var shareToken *string
+	err = tx.QueryRow(c, "SELECT user_id, visibility, share_token FROM ideas WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", ideaID).Scan(&ownerID, &from, &shareToken)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			utils.RespondWithError(c, http.StatusNotFound, "Idea not found")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if ownerID != userID {
+		utils.RespondWithError(c, http.StatusForbidden, "You can only change your own ideas")
+		return
+	}
+	if publishing != (from == visibilityDraft) {
+		if publishing {
+			utils.RespondWithError(c, http.StatusConflict, "Idea is already published")
+		} else {
+			utils.RespondWithError(c, http.StatusConflict, "Draft ideas must be published first")
+		}
+		return
+	}
+	if !canTransition(from, to) {
+		utils.RespondWithError(c, http.StatusConflict, fmt.Sprintf("Cannot change visibility from %s to %s", from, to))
+		return
+	}
+
+	if to == visibilityUnlisted && shareToken == nil {
+		token, err := newShareToken()
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create share link")
+			return
+		}
+		shareToken = &token
+	}
+
+	if publishAt != nil {
+		// The idea stays a draft until the scheduler publishes it.
+		_, err = tx.Exec(c,
+			"UPDATE ideas SET share_token = $1, publish_at = $2::timestamptz, scheduled_visibility = $3 WHERE id = $4",
+			shareToken, *publishAt, to, ideaID)
+		if err == nil {
+			err = tx.Commit(c)
+		}
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to schedule idea")
+			return
+		}
+		utils.LogActivity(c, userID, "SCHEDULE_IDEA", fmt.Sprintf("Idea %s: %s at %s", ideaID, to, publishAt.UTC().Format(time.RFC3339)))
+
+		resp := gin.H{"message": "Idea scheduled", "visibility": from, "scheduled_visibility": to, "publish_at": publishAt}
+		if shareToken != nil {
+			resp["share_token"] = *shareToken
+		}
+		utils.RespondWithJSON(c, http.StatusOK, resp)
+		return
+	}
+
+	_, err = tx.Exec(c,
+		`UPDATE ideas SET visibility = $1, share_token = $2, published_at = COALESCE(published_at, CURRENT_TIMESTAMP),
+			publish_at = NULL, scheduled_visibility = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3`,
+		to, shareToken, ideaID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update visibility")
+		return
+	}
+	if publishing {
+		if err := announceIdea(c, tx, ideaID); err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update visibility")
+			return
+		}
+	}
+	if err := tx.Commit(c); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update visibility")
+		return
+	}
+
+	action := "CHANGE_VISIBILITY"
+	if publishing {
+		action = "PUBLISH_IDEA"
+	}
+	utils.LogActivity(c, userID, action, fmt.Sprintf("Idea %s: %s -> %s", ideaID, from, to))
+
+	invalidateIdea(c, ideaID)
+
+	resp := gin.H{"message": "Visibility updated", "visibility": to}
+	if shareToken != nil {
+		resp["share_token"] = *shareToken
+	}
+	utils.RespondWithJSON(c, http.StatusOK, resp)
+}
+
+func newShareToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
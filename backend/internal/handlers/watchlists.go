@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/models"
+	"invesa_backend/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Bookmarks without an explicit watchlist go to the caller's default list,
+// which is created on first use.
+const (
+	defaultWatchlistName = "Saved"
+	maxWatchlistsPerUser = 50
+	maxBookmarkNoteLen   = 2000
+)
+
+type WatchlistRequest struct {
+	Name string `json:"name"`
+}
+
+type BookmarkRequest struct {
+	WatchlistID int    `json:"watchlist_id"`
+	Note        string `json:"note"`
+}
+
+// GetWatchlists returns the caller's watchlists with their saved ideas embedded.
+// Ideas the caller can no longer see are left out.
+func GetWatchlists(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if _, err := ensureDefaultWatchlist(c, userID); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch watchlists")
+		return
+	}
+
+	rows, err := database.DB.Query(c, "SELECT id, name, created_at FROM watchlists WHERE user_id = $1 ORDER BY created_at ASC, id ASC", userID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch watchlists")
+		return
+	}
+	watchlists := []models.Watchlist{}
+	index := map[int]int{}
+	for rows.Next() {
+		w := models.Watchlist{Items: []models.WatchlistItem{}}
+		if err := rows.Scan(&w.ID, &w.Name, &w.CreatedAt); err != nil {
+			continue
+		}
+		index[w.ID] = len(watchlists)
+		watchlists = append(watchlists, w)
+	}
+	rows.Close()
+
+	v := currentViewer(c)
+	rows, err = database.DB.Query(c, `
+		SELECT wi.watchlist_id, wi.note, wi.created_at, `+ideaColumns+`
+		FROM watchlist_items wi
+		JOIN watchlists w ON w.id = wi.watchlist_id
+		JOIN ideas ON ideas.id = wi.idea_id
+		`+ideaJoins+`
+		WHERE w.user_id = $1 AND ideas.deleted_at IS NULL AND (ideas.user_id = $1 OR (ideas.hidden_at IS NULL AND ideas.held_at IS NULL AND (ideas.visibility = ANY($2) OR ideas.visibility = $3)))
+		ORDER BY wi.created_at DESC`, userID, v.listedVisibilities(), visibilityUnlisted)
+	if err != nil {
+		fmt.Printf("Watchlist items query error: %v\n", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch watchlists")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var watchlistID int
+		var item models.WatchlistItem
+		targets := append([]interface{}{&watchlistID, &item.Note, &item.SavedAt}, ideaScanTargets(&item.Idea)...)
+		if err := rows.Scan(targets...); err != nil {
+			fmt.Printf("Scan error: %v\n", err)
+			continue
+		}
+		if i, ok := index[watchlistID]; ok {
+			watchlists[i].Items = append(watchlists[i].Items, item)
+		}
+	}
+
+	var ideas []*models.Idea
+	for i := range watchlists {
+		for j := range watchlists[i].Items {
+			ideas = append(ideas, &watchlists[i].Items[j].Idea)
+		}
+	}
+	applyViewerFlags(c, userID, ideas)
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"items": watchlists})
+}
+
+// CreateWatchlist adds a named watchlist for the caller.
+func CreateWatchlist(c *gin.Context) {
+	userID := c.GetString("user_id")
+	name, ok := bindWatchlistName(c)
+	if !ok {
+		return
+	}
+
+	var count int
+	if err := database.DB.QueryRow(c, "SELECT COUNT(*) FROM watchlists WHERE user_id = $1", userID).Scan(&count); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if count >= maxWatchlistsPerUser {
+		utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("You can have at most %d watchlists", maxWatchlistsPerUser))
+		return
+	}
+
+	w := models.Watchlist{Name: name, Items: []models.WatchlistItem{}}
+	err := database.DB.QueryRow(c,
+		"INSERT INTO watchlists (user_id, name) VALUES ($1, $2) RETURNING id, created_at", userID, name).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(c, http.StatusConflict, "You already have a watchlist with that name")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to create watchlist")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusCreated, w)
+}
+
+// RenameWatchlist renames one of the caller's watchlists.
+func RenameWatchlist(c *gin.Context) {
+	userID := c.GetString("user_id")
+	name, ok := bindWatchlistName(c)
+	if !ok {
+		return
+	}
+
+	result, err := database.DB.Exec(c, "UPDATE watchlists SET name = $1 WHERE id = $2 AND user_id = $3", name, c.Param("id"), userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(c, http.StatusConflict, "You already have a watchlist with that name")
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to rename watchlist")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "Watchlist not found")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Watchlist renamed", "name": name})
+}
+
+// DeleteWatchlist removes one of the caller's watchlists and its bookmarks.
+func DeleteWatchlist(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	result, err := database.DB.Exec(c, "DELETE FROM watchlists WHERE id = $1 AND user_id = $2", c.Param("id"), userID)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to delete watchlist")
+		return
+	}
+	if result.RowsAffected() == 0 {
+		utils.RespondWithError(c, http.StatusNotFound, "Watchlist not found")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Watchlist deleted"})
+}
+
+// BookmarkIdea saves an idea to one of the caller's watchlists, or updates the
+// note if it is already saved there. Bookmarks are private to the caller.
+func BookmarkIdea(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID := c.Param("id")
+
+	var req BookmarkRequest
+	// The body is optional.
+	_ = c.ShouldBindJSON(&req)
+	req.Note = strings.TrimSpace(req.Note)
+	if len(req.Note) > maxBookmarkNoteLen {
+		utils.RespondWithError(c, http.StatusBadRequest, fmt.Sprintf("Note must be at most %d characters", maxBookmarkNoteLen))
+		return
+	}
+
+	if _, ok := loadViewableIdea(c, ideaID); !ok {
+		return
+	}
+
+	watchlistID := req.WatchlistID
+	if watchlistID == 0 {
+		id, err := ensureDefaultWatchlist(c, userID)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to save idea")
+			return
+		}
+		watchlistID = id
+	} else {
+		var exists bool
+		err := database.DB.QueryRow(c, "SELECT EXISTS(SELECT 1 FROM watchlists WHERE id = $1 AND user_id = $2)", watchlistID, userID).Scan(&exists)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if !exists {
+			utils.RespondWithError(c, http.StatusNotFound, "Watchlist not found")
+			return
+		}
+	}
+
+	_, err := database.DB.Exec(c, `
+		INSERT INTO watchlist_items (watchlist_id, idea_id, note) VALUES ($1, $2, $3)
+		ON CONFLICT (watchlist_id, idea_id) DO UPDATE SET note = EXCLUDED.note`, watchlistID, ideaID, req.Note)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to save idea")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Idea saved", "watchlist_id": watchlistID, "is_bookmarked": true})
+}
+
+// RemoveBookmark removes an idea from one watchlist (?watchlist_id=) or, without
+// it, from all of the caller's watchlists.
+func RemoveBookmark(c *gin.Context) {
+	userID := c.GetString("user_id")
+	ideaID := c.Param("id")
+
+	query := `DELETE FROM watchlist_items wi USING watchlists w
+		WHERE w.id = wi.watchlist_id AND w.user_id = $1 AND wi.idea_id = $2`
+	args := []interface{}{userID, ideaID}
+	if raw := c.Query("watchlist_id"); raw != "" {
+		watchlistID, err := strconv.Atoi(raw)
+		if err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid watchlist id")
+			return
+		}
+		query += " AND wi.watchlist_id = $3"
+		args = append(args, watchlistID)
+	}
+
+	if _, err := database.DB.Exec(c, query, args...); err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to remove bookmark")
+		return
+	}
+
+	var stillSaved bool
+	err := database.DB.QueryRow(c, `
+		SELECT EXISTS(SELECT 1 FROM watchlist_items wi JOIN watchlists w ON w.id = wi.watchlist_id
+		WHERE w.user_id = $1 AND wi.idea_id = $2)`, userID, ideaID).Scan(&stillSaved)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Bookmark removed", "is_bookmarked": stillSaved})
+}
+
+func ensureDefaultWatchlist(c *gin.Context, userID string) (int, error) {
+	var id int
+	err := database.DB.QueryRow(c, "SELECT id FROM watchlists WHERE user_id = $1 AND name = $2", userID, defaultWatchlistName).Scan(&id)
+	if err == nil || !errors.Is(err, pgx.ErrNoRows) {
+		return id, err
+	}
+	err = database.DB.QueryRow(c, `
+		INSERT INTO watchlists (user_id, name) VALUES ($1, $2)
+		ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id`, userID, defaultWatchlistName).Scan(&id)
+	return id, err
+}
+
+func bindWatchlistName(c *gin.Context) (string, bool) {
+	var req WatchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request body")
+		return "", false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		utils.RespondWithError(c, http.StatusBadRequest, "Name must be between 1 and 100 characters")
+		return "", false
+	}
+	return name, true
+}
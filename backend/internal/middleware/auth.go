@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
+	"time"
 
+	"invesa_backend/internal/database"
 	"invesa_backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +20,11 @@ func RequireAuth() gin.HandlerFunc {
 			tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 			userID, err := utils.ValidateToken(tokenStr)
 			if err == nil && userID != "" {
+				if until, ok := SuspendedUntil(c, userID); ok {
+					c.JSON(http.StatusForbidden, gin.H{"error": "Account suspended", "suspended_until": until})
+					c.Abort()
+					return
+				}
 				c.Set("user_id", userID)
 				c.Next()
 				return
@@ -27,3 +35,49 @@ func RequireAuth() gin.HandlerFunc {
 		c.Abort()
 	}
 }
+
+// SuspendedUntil reports whether a moderator has suspended userID, and until
+// when. Long-lived connections check it again before each write.
+func SuspendedUntil(ctx context.Context, userID string) (time.Time, bool) {
+	var until *time.Time
+	err := database.DB.QueryRow(ctx,
+		"SELECT suspended_until FROM users WHERE id = $1 AND suspended_until > CURRENT_TIMESTAMP", userID).Scan(&until)
+	if err != nil || until == nil {
+		return time.Time{}, false
+	}
+	return *until, true
+}
+
+// OptionalAuth sets user_id in context when a valid JWT is present but lets
+// anonymous requests through.
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			userID, err := utils.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
+			if err == nil && userID != "" {
+				c.Set("user_id", userID)
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireSocketAuth is RequireAuth for WebSocket upgrades. Browsers cannot set
+// headers on a WebSocket, so the JWT may also come as the subprotocol pair
+// "bearer, <token>"; query strings are avoided because they end up in logs.
+func RequireSocketAuth() gin.HandlerFunc {
+	requireAuth := RequireAuth()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			protocols := strings.Split(c.GetHeader("Sec-WebSocket-Protocol"), ",")
+			for i := 0; i+1 < len(protocols); i++ {
+				if strings.TrimSpace(protocols[i]) == "bearer" {
+					c.Request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(protocols[i+1]))
+					break
+				}
+			}
+		}
+		requireAuth(c)
+	}
+}
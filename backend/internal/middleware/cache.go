@@ -1,19 +1,229 @@
 package middleware
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
+// Responses larger than this are streamed without an ETag.
+const maxETagBodyBytes = 1 << 20
+
+// CacheControl sets the default policy: GET responses may be stored by the
+// browser but must be revalidated (cheap thanks to ETags), mutations are never
+// stored. Routes override it with PublicCache or NoStore.
 func CacheControl() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Only cache GET requests
-		if c.Request.Method == "GET" {
-			// Cache public data for 1 minute, allow validation
-			c.Header("Cache-Control", "public, max-age=60, stale-while-revalidate=30")
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Header("Cache-Control", "private, no-cache")
 		} else {
-			// Do not cache mutations
 			c.Header("Cache-Control", "no-store")
 		}
 		c.Next()
 	}
 }
+
+// PublicCache lets shared caches such as CDNs store anonymous responses.
+// Authenticated requests may be personalized, so they stay private.
+func PublicCache(maxAge, staleWhileRevalidate time.Duration) gin.HandlerFunc {
+	public := fmt.Sprintf("public, max-age=%d, stale-while-revalidate=%d", int(maxAge.Seconds()), int(staleWhileRevalidate.Seconds()))
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Authorization")
+		if c.GetHeader("Authorization") == "" {
+			c.Header("Cache-Control", public)
+		} else {
+			c.Header("Cache-Control", "private, no-cache")
+		}
+		c.Next()
+	}
+}
+
+// NoStore marks responses that must never be cached, such as messages.
+func NoStore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Next()
+	}
+}
+
+// ConditionalGET adds a weak ETag computed from the body to successful GET
+// responses and answers If-None-Match / If-Modified-Since with 304. Handlers
+// may set their own ETag or Last-Modified from row versions instead.
+func ConditionalGET() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		w := &bufferedWriter{ResponseWriter: original, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = original
+
+		if w.passthrough {
+			return
+		}
+		header := original.Header()
+		if w.status == http.StatusOK && !strings.Contains(header.Get("Cache-Control"), "no-store") {
+			if header.Get("ETag") == "" {
+				sum := sha256.Sum256(w.buf.Bytes())
+				header.Set("ETag", `W/"`+base64.RawURLEncoding.EncodeToString(sum[:16])+`"`)
+			}
+			if notModified(c.Request, header.Get("ETag"), header.Get("Last-Modified")) {
+				header.Del("Content-Type")
+				header.Del("Content-Length")
+				// gin flushes the header once the chain returns.
+				original.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		original.WriteHeader(w.status)
+		if w.buf.Len() > 0 {
+			original.Write(w.buf.Bytes())
+		}
+	}
+}
+
+// CheckNotModified sets validators for a handler that knows its row version
+// and writes a 304 if the client's copy is current. It reports whether it did.
+func CheckNotModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(c.Request, etag, c.Writer.Header().Get("Last-Modified")) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// notModified evaluates the request's conditional headers. If-None-Match takes
+// precedence over If-Modified-Since (RFC 9110, section 13.2.2).
+func notModified(r *http.Request, etag, lastModified string) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !modified.After(since)
+}
+
+// bufferedWriter holds a response back so an ETag can be computed over it.
+// Large, no-store, flushed or hijacked responses pass straight through.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status      int
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	if !w.passthrough && (w.buf.Len()+len(data) > maxETagBodyBytes || w.uncacheable()) {
+		w.startPassthrough()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.buf.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	if w.buf.Len() == 0 {
+		return -1
+	}
+	return w.buf.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.passthrough && w.ResponseWriter.Written()
+}
+
+func (w *bufferedWriter) Flush() {
+	w.startPassthrough()
+	w.ResponseWriter.Flush()
+}
+
+func (w *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.passthrough = true
+	return w.ResponseWriter.Hijack()
+}
+
+// uncacheable responses never get an ETag, so streaming them is safe.
+func (w *bufferedWriter) uncacheable() bool {
+	return strings.Contains(w.Header().Get("Cache-Control"), "no-store")
+}
+
+func (w *bufferedWriter) startPassthrough() {
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	} else {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"invesa_backend/internal/database"
+	"invesa_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireModerator admits moderators (users with is_moderator set) and
+// holders of the admin key. Moderators get user_id in context; the admin key
+// does not, so handlers record its actions without a moderator.
+func RequireModerator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminKey := os.Getenv("ADMIN_KEY"); adminKey != "" && c.GetHeader("X-Admin-Key") == adminKey {
+			c.Next()
+			return
+		}
+
+		authHeader := c.GetHeader("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			userID, err := utils.ValidateToken(strings.TrimPrefix(authHeader, "Bearer "))
+			if err == nil && userID != "" {
+				var isModerator bool
+				err := database.DB.QueryRow(c,
+					"SELECT is_moderator AND (suspended_until IS NULL OR suspended_until <= CURRENT_TIMESTAMP) FROM users WHERE id = $1",
+					userID).Scan(&isModerator)
+				if err == nil && isModerator {
+					c.Set("user_id", userID)
+					c.Next()
+					return
+				}
+				c.JSON(http.StatusForbidden, gin.H{"error": "Moderator access required"})
+				c.Abort()
+				return
+			}
+		}
+
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.Abort()
+	}
+}
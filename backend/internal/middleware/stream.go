@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const connWriterKey = "conn_writer"
+
+// StreamDeadlines keeps the connection's own writer so streaming responses can
+// outlive the server's WriteTimeout with ExtendWriteDeadline. Writers added by
+// later middleware, such as gzip, hide the connection from
+// http.ResponseController, so it must be registered before them.
+func StreamDeadlines() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(connWriterKey, http.ResponseWriter(c.Writer))
+		c.Next()
+	}
+}
+
+// ExtendWriteDeadline gives the response another d to finish writing. Call it
+// before each flush of a long stream.
+func ExtendWriteDeadline(c *gin.Context, d time.Duration) error {
+	w, ok := c.Value(connWriterKey).(http.ResponseWriter)
+	if !ok {
+		w = c.Writer
+	}
+	return http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d))
+}
@@ -1,28 +1,128 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
-	ID        string    `json:"id"` // UUID
-	Username  string    `json:"username"`
-	Password  string    `json:"password"`
-	FullName  string    `json:"full_name"`
-	Email     string    `json:"email"`
-	Bio       string    `json:"bio"`
-	Role      string    `json:"role"`
-	AvatarURL string    `json:"avatar_url"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              string    `json:"id"` // UUID
+	Username        string    `json:"username"`
+	Password        string    `json:"password"`
+	FullName        string    `json:"full_name"`
+	Email           string    `json:"email"`
+	Bio             string    `json:"bio"`
+	Role            string    `json:"role"`
+	AvatarURL       string    `json:"avatar_url"`
+	InterestSectors []string  `json:"interest_sectors"` // Category slugs
+	InterestStages  []string  `json:"interest_stages"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 type Idea struct {
-	ID          int       `json:"id"`
-	UserID      string    `json:"user_id"` // UUID
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Category    string    `json:"category"`
-	LikesCount  int       `json:"likes_count"`
-	CreatedAt   time.Time `json:"created_at"`
-	IsLiked     bool      `json:"is_liked"`
+	ID                  int            `json:"id"`
+	UserID              string         `json:"user_id"` // UUID
+	Title               string         `json:"title"`
+	Description         string         `json:"description"`
+	Category            string         `json:"category"` // Category slug
+	CategoryName        string         `json:"category_name"`
+	Stage               string         `json:"stage"`
+	Visibility          string         `json:"visibility"` // draft, public, unlisted, investors_only, private
+	PublishedAt         *time.Time     `json:"published_at"`
+	PublishAt           *time.Time     `json:"publish_at,omitempty"`           // Scheduled publish time of a draft
+	ScheduledVisibility string         `json:"scheduled_visibility,omitempty"` // Visibility the draft is published with
+	ShareToken          string         `json:"share_token,omitempty"`          // Only returned to the owner
+	ConfidentialDetails *string        `json:"confidential_details,omitempty"` // NDA-gated; Description is the public teaser
+	NDARequired         bool           `json:"nda_required"`
+	IsHidden            bool           `json:"is_hidden,omitempty"` // Hidden by moderation or held by screening; only the owner still sees it
+	Tags                []string       `json:"tags"`
+	Reactions           map[string]int `json:"reactions"` // Count per reaction kind
+	MyReactions         []string       `json:"my_reactions"`
+	LikesCount          int            `json:"likes_count"`
+	CommentsCount       int            `json:"comments_count"`
+	ViewsCount          int            `json:"views_count"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	IsLiked             bool           `json:"is_liked"`
+	IsBookmarked        bool           `json:"is_bookmarked"`
+	Team                []IdeaMember   `json:"team"` // Owner and editors
+}
+
+// IdeaUpdate is a founder's progress post on an idea.
+type IdeaUpdate struct {
+	ID          int        `json:"id"`
+	IdeaID      int        `json:"idea_id"`
+	AuthorID    *string    `json:"author_id"`
+	AuthorName  string     `json:"author_name"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	Visibility  string     `json:"visibility"`           // public or supporters (followers and investors)
+	PublishAt   *time.Time `json:"publish_at,omitempty"` // Scheduled; only shown to the team
+	PublishedAt *time.Time `json:"published_at"`
+	IsHidden    bool       `json:"is_hidden,omitempty"` // Held by screening; only shown to the team
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// IdeaMember is someone on an idea's team.
+type IdeaMember struct {
+	UserID   string     `json:"user_id"`
+	Username string     `json:"username"`
+	FullName string     `json:"full_name"`
+	Role     string     `json:"role"` // owner, editor or viewer
+	JoinedAt *time.Time `json:"joined_at,omitempty"`
+}
+
+// IdeaInvitation asks a user, or an email address without an account, to
+// join an idea's team.
+type IdeaInvitation struct {
+	ID          int        `json:"id"`
+	IdeaID      int        `json:"idea_id"`
+	IdeaTitle   string     `json:"idea_title"`
+	InviterID   *string    `json:"inviter_id"`
+	InviterName string     `json:"inviter_name"`
+	InviteeID   *string    `json:"invitee_id,omitempty"`
+	Email       string     `json:"email,omitempty"` // Only for invitations by email
+	Role        string     `json:"role"`
+	Status      string     `json:"status"` // pending, accepted, declined or revoked
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at,omitempty"`
+}
+
+// SimilarIdea is an existing idea that looks like a near-duplicate of a new one.
+type SimilarIdea struct {
+	ID         int     `json:"id"`
+	UserID     string  `json:"user_id"`
+	Title      string  `json:"title"`
+	IsOwn      bool    `json:"is_own"`     // Posted by the same author
+	Similarity float64 `json:"similarity"` // 0 to 1
+}
+
+// RelatedIdea is an idea similar to the one being viewed.
+type RelatedIdea struct {
+	Idea
+	Similarity float64 `json:"similarity"` // 0 to 1
+}
+
+// TrashedIdea is a soft-deleted idea in its owner's trash.
+type TrashedIdea struct {
+	Idea
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"` // Restorable until then
+}
+
+type Category struct {
+	ID        int       `json:"id"`
+	Slug      string    `json:"slug"`
+	Name      string    `json:"name"`
+	SortOrder int       `json:"sort_order"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Tag struct {
+	Name       string `json:"name"`
+	UsageCount int    `json:"usage_count"`
 }
 
 type Comment struct {
@@ -35,9 +135,218 @@ type Comment struct {
 }
 
 type Message struct {
-	ID         int       `json:"id"`
-	SenderID   string    `json:"sender_id"`   // UUID
-	ReceiverID string    `json:"receiver_id"` // UUID
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID             int        `json:"id"`
+	SenderID       string     `json:"sender_id"`   // UUID
+	ReceiverID     string     `json:"receiver_id"` // UUID
+	Content        string     `json:"content"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"` // Acknowledged by the receiver's chat socket
+	ReadAt         *time.Time `json:"read_at,omitempty"`
+	ConversationID int        `json:"conversation_id"`
+}
+
+// Conversation is a direct-message thread as listed for one of its two
+// participants.
+type Conversation struct {
+	ID          int            `json:"id"`
+	Counterpart UserSummary    `json:"counterpart"`
+	LastMessage MessagePreview `json:"last_message"`
+	UnreadCount int            `json:"unread_count"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// MessagePreview is the start of a conversation's latest message.
+type MessagePreview struct {
+	ID        int       `json:"id"`
+	SenderID  string    `json:"sender_id"`
+	Content   string    `json:"content"` // First 200 characters
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Attachment struct {
+	ID           int       `json:"id"`
+	IdeaID       int       `json:"idea_id"`
+	UploaderID   string    `json:"uploader_id"` // UUID
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	SizeBytes    int64     `json:"size_bytes"`
+	SHA256       string    `json:"sha256"`
+	Kind         string    `json:"kind"` // pitch_deck, one_pager, image, other
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type NDA struct {
+	ID           int       `json:"id"`
+	IdeaID       int       `json:"idea_id"`
+	Version      int       `json:"version"`
+	Body         string    `json:"body"`
+	DocumentHash string    `json:"document_hash"` // SHA-256 of Body
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type NDAAcceptance struct {
+	ID           int        `json:"id"`
+	IdeaID       int        `json:"idea_id"`
+	SignerID     string     `json:"signer_id"` // UUID
+	SignerName   string     `json:"signer_name"`
+	SignerEmail  string     `json:"signer_email"`
+	NDAVersion   int        `json:"nda_version"`
+	DocumentHash string     `json:"document_hash"`
+	IPAddress    string     `json:"ip_address"`
+	UserAgent    string     `json:"user_agent"`
+	AcceptedAt   time.Time  `json:"accepted_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+}
+
+type DailyViews struct {
+	Day           string `json:"day"` // YYYY-MM-DD
+	Views         int    `json:"views"`
+	UniqueViewers int    `json:"unique_viewers"`
+	InvestorViews int    `json:"investor_views"`
+}
+
+type ReferrerViews struct {
+	Host  string `json:"host"`
+	Views int    `json:"views"`
+}
+
+// IdeaAnalytics totals cover the idea's lifetime; Daily and TopReferrers cover the last Days days.
+type IdeaAnalytics struct {
+	IdeaID                int             `json:"idea_id"`
+	Days                  int             `json:"days"`
+	Views                 int             `json:"views"`
+	UniqueViewers         int             `json:"unique_viewers"`
+	InvestorViews         int             `json:"investor_views"`
+	InvestorUniqueViewers int             `json:"investor_unique_viewers"`
+	Likes                 int             `json:"likes"`
+	LikeConversion        float64         `json:"like_conversion"` // Share of signed-in viewers who liked the idea
+	Daily                 []DailyViews    `json:"daily"`
+	TopReferrers          []ReferrerViews `json:"top_referrers"`
+}
+
+type Watchlist struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	Items     []WatchlistItem `json:"items"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type WatchlistItem struct {
+	Idea    Idea      `json:"idea"`
+	Note    string    `json:"note"`
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// Profile is the public view of a user.
+type Profile struct {
+	ID             string    `json:"id"`
+	Username       string    `json:"username"`
+	FullName       string    `json:"full_name"`
+	Bio            string    `json:"bio"`
+	Role           string    `json:"role"`
+	AvatarURL      string    `json:"avatar_url"`
+	FollowerCount  int       `json:"follower_count"`
+	FollowingCount int       `json:"following_count"`
+	IsFollowing    bool      `json:"is_following"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type UserSummary struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	FullName   string    `json:"full_name"`
+	AvatarURL  string    `json:"avatar_url"`
+	FollowedAt time.Time `json:"followed_at,omitzero"` // Only in follower and following lists
+}
+
+type Notification struct {
+	ID        int64      `json:"id"`
+	Type      string     `json:"type"`
+	ActorID   *string    `json:"actor_id"`
+	IdeaID    *int       `json:"idea_id"`
+	Message   string     `json:"message"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// FundingRound is what an idea is raising. Amounts are whole units of Currency.
+type FundingRound struct {
+	ID            int            `json:"id"`
+	IdeaID        int            `json:"idea_id"`
+	Instrument    string         `json:"instrument"` // equity, safe, convertible
+	TargetAmount  int64          `json:"target_amount"`
+	Currency      string         `json:"currency"`  // ISO 4217
+	Valuation     *int64         `json:"valuation"` // Pre-money valuation, or the cap for SAFEs and convertibles
+	MinimumTicket *int64         `json:"minimum_ticket"`
+	OpensAt       *time.Time     `json:"opens_at"`
+	ClosesAt      *time.Time     `json:"closes_at"`
+	Status        string         `json:"status"` // draft, open, closed, cancelled
+	MyInterest    *RoundInterest `json:"my_interest,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+type RoundInterest struct {
+	ID           int       `json:"id"`
+	RoundID      int       `json:"round_id"`
+	InvestorID   string    `json:"investor_id"` // UUID
+	InvestorName string    `json:"investor_name,omitempty"`
+	Status       string    `json:"status"` // interested, in_diligence, committed, withdrawn
+	Amount       *int64    `json:"amount"`
+	Note         string    `json:"note"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type InterestTotals struct {
+	Count  int   `json:"count"`
+	Amount int64 `json:"amount"`
+}
+
+// RoundProgress is the founder's aggregated view of a round.
+type RoundProgress struct {
+	Round            FundingRound              `json:"round"`
+	CommittedAmount  int64                     `json:"committed_amount"`
+	PipelineAmount   int64                     `json:"pipeline_amount"` // Interested and in diligence
+	PercentCommitted float64                   `json:"percent_committed"`
+	ByStatus         map[string]InterestTotals `json:"by_status"`
+	Interests        []RoundInterest           `json:"interests"`
+}
+
+// Report is a user's flag on an idea, comment, message or user, as seen in
+// the moderation queue.
+type Report struct {
+	ID            int        `json:"id"`
+	ReporterID    *string    `json:"reporter_id"`
+	TargetType    string     `json:"target_type"` // idea, comment, message, user
+	TargetID      string     `json:"target_id"`
+	TargetUserID  *string    `json:"target_user_id"` // Author of the reported content
+	TargetPreview string     `json:"target_preview"` // Title, excerpt or username; empty once deleted
+	OpenReports   int        `json:"open_reports"`   // Open reports on the same target
+	Reason        string     `json:"reason"`
+	Details       string     `json:"details"`
+	Status        string     `json:"status"` // open, claimed, resolved, dismissed
+	ClaimedBy     *string    `json:"claimed_by"`
+	ClaimedAt     *time.Time `json:"claimed_at"`
+	Resolution    string     `json:"resolution"`
+	ResolvedBy    *string    `json:"resolved_by"`
+	ResolvedAt    *time.Time `json:"resolved_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// ScreeningResult is a content screening verdict as shown to moderators.
+type ScreeningResult struct {
+	ID           int             `json:"id"`
+	ContentType  string          `json:"content_type"`
+	ContentID    *int            `json:"content_id"` // Nil for rejected content, which was never saved
+	AuthorID     *string         `json:"author_id"`
+	Excerpt      string          `json:"excerpt"`
+	Verdict      string          `json:"verdict"`
+	Reasons      json.RawMessage `json:"reasons"`
+	ReviewStatus *string         `json:"review_status"` // pending, approved or rejected; nil unless held
+	ReviewedBy   *string         `json:"reviewed_by"`
+	ReviewedAt   *time.Time      `json:"reviewed_at"`
+	CreatedAt    time.Time       `json:"created_at"`
 }
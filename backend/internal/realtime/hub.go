@@ -0,0 +1,315 @@
+package realtime
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+)
+
+// EventSync tells a connection that events were too large to relay between
+// instances; it should reload what it missed from the database instead.
+const EventSync = "sync"
+
+const (
+	// subscriptionBuffer is how many events a slow connection may fall behind
+	// before it is dropped; the client reconnects and resumes.
+	subscriptionBuffer = 64
+
+	// Instances announce their online users this often, and forget another
+	// instance's users when its announcements stop.
+	presenceInterval = 30 * time.Second
+	presenceExpiry   = 3 * presenceInterval
+
+	// maxBridgePayload stays under Postgres's 8000 byte NOTIFY limit.
+	maxBridgePayload = 7900
+	// presenceChunk keeps presence announcements under maxBridgePayload.
+	presenceChunk = 150
+)
+
+// Event is pushed to the connected clients of its recipients as
+// {"type": ..., "data": ...}.
+type Event struct {
+	Type string `json:"type"`
+	Data any    `json:"data,omitempty"`
+}
+
+// Bridge relays payloads between backend instances.
+type Bridge interface {
+	Publish(ctx context.Context, payload []byte) error
+	// Listen calls receive with every payload published by any instance,
+	// including this one, until ctx is cancelled.
+	Listen(ctx context.Context, receive func(payload []byte))
+}
+
+// envelope is what instances exchange over the bridge: an event for some
+// users, or a presence change.
+type envelope struct {
+	Instance   string   `json:"i"`
+	Recipients []string `json:"r,omitempty"`
+	Event      *Event   `json:"e,omitempty"`
+	Online     []string `json:"on,omitempty"`
+	Offline    []string `json:"off,omitempty"`
+}
+
+// Subscription receives the events for one connection of a user.
+type Subscription struct {
+	UserID  string
+	C       <-chan Event
+	c       chan Event
+	dropped chan struct{}
+	drop    sync.Once
+	hub     *Hub
+}
+
+// Dropped is closed when the subscription fell too far behind and lost
+// events. The connection should close; the client resumes on reconnect.
+func (s *Subscription) Dropped() <-chan struct{} {
+	return s.dropped
+}
+
+// Close unsubscribes and reports whether it was the user's last connection
+// to this instance.
+func (s *Subscription) Close() bool {
+	return s.hub.unsubscribe(s)
+}
+
+// Hub fans events out to the connections of their recipients. With a bridge,
+// events and presence are shared with the other instances too.
+type Hub struct {
+	instance string
+	bridge   Bridge
+
+	mu     sync.RWMutex
+	subs   map[string]map[*Subscription]struct{}
+	remote map[string]map[string]time.Time // Instance to user to last announcement
+}
+
+// Default is the hub configured by Init. It starts without a bridge, which is
+// right for a single instance.
+var Default = NewHub(nil)
+
+// NewHub returns a hub that relays through bridge, or stays local when it is nil.
+func NewHub(bridge Bridge) *Hub {
+	return &Hub{
+		instance: newInstanceID(),
+		bridge:   bridge,
+		subs:     map[string]map[*Subscription]struct{}{},
+		remote:   map[string]map[string]time.Time{},
+	}
+}
+
+// Subscribe registers a connection for userID and reports whether it is the
+// user's first connection to this instance.
+func (h *Hub) Subscribe(userID string) (*Subscription, bool) {
+	c := make(chan Event, subscriptionBuffer)
+	s := &Subscription{UserID: userID, C: c, c: c, dropped: make(chan struct{}), hub: h}
+
+	h.mu.Lock()
+	first := len(h.subs[userID]) == 0
+	if first {
+		h.subs[userID] = map[*Subscription]struct{}{}
+	}
+	h.subs[userID][s] = struct{}{}
+	h.mu.Unlock()
+
+	if first {
+		h.relay(envelope{Instance: h.instance, Online: []string{userID}})
+	}
+	return s, first
+}
+
+func (h *Hub) unsubscribe(s *Subscription) bool {
+	h.mu.Lock()
+	conns, ok := h.subs[s.UserID]
+	if !ok {
+		h.mu.Unlock()
+		return false
+	}
+	if _, ok := conns[s]; !ok {
+		h.mu.Unlock()
+		return false
+	}
+	delete(conns, s)
+	last := len(conns) == 0
+	if last {
+		delete(h.subs, s.UserID)
+	}
+	h.mu.Unlock()
+
+	if last {
+		h.relay(envelope{Instance: h.instance, Offline: []string{s.UserID}})
+	}
+	return last
+}
+
+// Online reports whether userID has a connection to any instance.
+func (h *Hub) Online(userID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.subs[userID]) > 0 {
+		return true
+	}
+	cutoff := time.Now().Add(-presenceExpiry)
+	for _, users := range h.remote {
+		if seen, ok := users[userID]; ok && seen.After(cutoff) {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish sends event to every connection of the recipients, on this
+// instance directly and on the others through the bridge.
+func (h *Hub) Publish(ctx context.Context, recipients []string, event Event) {
+	h.deliver(recipients, event)
+	if h.bridge == nil {
+		return
+	}
+
+	env := envelope{Instance: h.instance, Recipients: recipients, Event: &event}
+	payload, err := json.Marshal(env)
+	if err == nil && len(payload) > maxBridgePayload {
+		env.Event = &Event{Type: EventSync}
+		payload, err = json.Marshal(env)
+	}
+	if err != nil {
+		log.Printf("Realtime: failed to encode %s event: %v", event.Type, err)
+		return
+	}
+	if err := h.bridge.Publish(ctx, payload); err != nil {
+		log.Printf("Realtime: failed to relay %s event: %v", event.Type, err)
+	}
+}
+
+// Run relays between instances until ctx is cancelled. Without a bridge it
+// returns immediately.
+func (h *Hub) Run(ctx context.Context) {
+	if h.bridge == nil {
+		return
+	}
+	go h.bridge.Listen(ctx, h.receive)
+
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.announcePresence()
+		}
+	}
+}
+
+// deliver hands event to the local connections of the recipients. A
+// connection whose buffer is full is dropped rather than blocking the others.
+func (h *Hub) deliver(recipients []string, event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, userID := range recipients {
+		for s := range h.subs[userID] {
+			select {
+			case s.c <- event:
+			default:
+				s.drop.Do(func() { close(s.dropped) })
+			}
+		}
+	}
+}
+
+func (h *Hub) receive(payload []byte) {
+	// Event data stays raw JSON, which is sent on to clients unchanged.
+	var env struct {
+		envelope
+		Event *struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		} `json:"e"`
+	}
+	if err := json.Unmarshal(payload, &env); err != nil {
+		log.Printf("Realtime: ignoring malformed bridge payload: %v", err)
+		return
+	}
+	if env.Instance == h.instance {
+		return
+	}
+
+	if len(env.Online) > 0 || len(env.Offline) > 0 {
+		now := time.Now()
+		h.mu.Lock()
+		users := h.remote[env.Instance]
+		if users == nil {
+			users = map[string]time.Time{}
+			h.remote[env.Instance] = users
+		}
+		for _, id := range env.Online {
+			users[id] = now
+		}
+		for _, id := range env.Offline {
+			delete(users, id)
+		}
+		h.mu.Unlock()
+	}
+	if env.Event != nil {
+		event := Event{Type: env.Event.Type}
+		if len(env.Event.Data) > 0 {
+			event.Data = env.Event.Data
+		}
+		h.deliver(env.Recipients, event)
+	}
+}
+
+// announcePresence re-announces this instance's online users, so new
+// instances learn them and stale entries of dead instances expire.
+func (h *Hub) announcePresence() {
+	h.mu.Lock()
+	users := make([]string, 0, len(h.subs))
+	for id := range h.subs {
+		users = append(users, id)
+	}
+	cutoff := time.Now().Add(-presenceExpiry)
+	for instance, remote := range h.remote {
+		for id, seen := range remote {
+			if seen.Before(cutoff) {
+				delete(remote, id)
+			}
+		}
+		if len(remote) == 0 {
+			delete(h.remote, instance)
+		}
+	}
+	h.mu.Unlock()
+
+	for start := 0; start < len(users); start += presenceChunk {
+		end := min(start+presenceChunk, len(users))
+		h.relay(envelope{Instance: h.instance, Online: users[start:end]})
+	}
+}
+
+// relay publishes a presence envelope to the other instances.
+func (h *Hub) relay(env envelope) {
+	if h.bridge == nil {
+		return
+	}
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.bridge.Publish(ctx, payload); err != nil {
+		log.Printf("Realtime: failed to relay presence: %v", err)
+	}
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,92 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultChannel = "invesa_realtime"
+
+// PostgresBridge relays payloads between instances with LISTEN/NOTIFY on one
+// channel. It needs a direct connection; transaction-mode poolers such as
+// PgBouncer do not pass notifications through.
+type PostgresBridge struct {
+	db      *pgxpool.Pool
+	channel string
+}
+
+// NewPostgresBridge relays over channel on db.
+func NewPostgresBridge(db *pgxpool.Pool, channel string) *PostgresBridge {
+	return &PostgresBridge{db: db, channel: channel}
+}
+
+func (b *PostgresBridge) Publish(ctx context.Context, payload []byte) error {
+	_, err := b.db.Exec(ctx, "SELECT pg_notify($1, $2)", b.channel, string(payload))
+	return err
+}
+
+// Listen holds a pooled connection for LISTEN, reconnecting with a growing
+// delay when it is lost. Events sent while disconnected are missed; clients
+// recover them by resuming from their last message.
+func (b *PostgresBridge) Listen(ctx context.Context, receive func(payload []byte)) {
+	delay := time.Second
+	for {
+		err := b.listen(ctx, receive, func() { delay = time.Second })
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Realtime: LISTEN connection lost, retrying in %s: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, time.Minute)
+	}
+}
+
+func (b *PostgresBridge) listen(ctx context.Context, receive func([]byte), connected func()) error {
+	pooled, err := b.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A connection left in LISTEN must not go back to the pool.
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{b.channel}.Sanitize()); err != nil {
+		return err
+	}
+	connected()
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		receive([]byte(n.Payload))
+	}
+}
+
+// Init configures Default from REALTIME_BRIDGE: "postgres" shares events and
+// presence between instances over db, "off" (the default) keeps them local.
+func Init(db *pgxpool.Pool) error {
+	switch mode := os.Getenv("REALTIME_BRIDGE"); mode {
+	case "", "off":
+		Default = NewHub(nil)
+	case "postgres":
+		channel := os.Getenv("REALTIME_CHANNEL")
+		if channel == "" {
+			channel = defaultChannel
+		}
+		Default = NewHub(NewPostgresBridge(db, channel))
+	default:
+		return fmt.Errorf("unknown REALTIME_BRIDGE %q", mode)
+	}
+	return nil
+}
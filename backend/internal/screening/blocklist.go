@@ -0,0 +1,176 @@
+package screening
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// rule is one line of a blocklist file: "<hold|reject> <pattern>".
+type rule struct {
+	verdict Verdict
+	pattern string
+}
+
+// loadRules reads a blocklist file. Blank lines and lines starting with # are
+// skipped.
+func loadRules(path string) ([]rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules []rule
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		verdict, pattern, _ := strings.Cut(text, " ")
+		v, ok := parseVerdict(verdict)
+		pattern = strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("%s:%d: expected \"hold <pattern>\" or \"reject <pattern>\"", path, line)
+		}
+		rules = append(rules, rule{verdict: v, pattern: pattern})
+	}
+	return rules, scanner.Err()
+}
+
+type keyword struct {
+	verdict Verdict
+	label   string
+	re      *regexp.Regexp
+}
+
+// KeywordScreener flags text containing blocked words, phrases or patterns.
+type KeywordScreener struct {
+	keywords []keyword
+}
+
+// LoadKeywords reads a keyword list. A pattern wrapped in slashes is a regular
+// expression; anything else matches as a whole word or phrase. Both ignore case.
+//
+//	reject guaranteed returns
+//	hold   /whats\s*app/
+func LoadKeywords(path string) (*KeywordScreener, error) {
+	rules, err := loadRules(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &KeywordScreener{}
+	for _, r := range rules {
+		expr := wholeWords(r.pattern)
+		if len(r.pattern) > 2 && strings.HasPrefix(r.pattern, "/") && strings.HasSuffix(r.pattern, "/") {
+			expr = r.pattern[1 : len(r.pattern)-1]
+		}
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern %q: %w", path, r.pattern, err)
+		}
+		s.keywords = append(s.keywords, keyword{verdict: r.verdict, label: r.pattern, re: re})
+	}
+	return s, nil
+}
+
+// wholeWords matches pattern literally, not as part of a longer word. Word
+// boundaries only apply at ends that are word characters, so "c++" still
+// matches.
+func wholeWords(pattern string) string {
+	expr := regexp.QuoteMeta(pattern)
+	if wordChar.MatchString(pattern[:1]) {
+		expr = `\b` + expr
+	}
+	if wordChar.MatchString(pattern[len(pattern)-1:]) {
+		expr += `\b`
+	}
+	return expr
+}
+
+var wordChar = regexp.MustCompile(`^\w$`)
+
+func (s *KeywordScreener) Name() string { return "keywords" }
+
+func (s *KeywordScreener) Screen(ctx context.Context, content Content) ([]Reason, error) {
+	var reasons []Reason
+	for _, k := range s.keywords {
+		if k.re.MatchString(content.Text) {
+			reasons = append(reasons, Reason{Verdict: k.verdict, Message: "Contains blocked language", Detail: k.label})
+		}
+	}
+	return reasons, nil
+}
+
+// urlPattern finds links with a scheme or a leading "www.".
+var urlPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"'()]+`)
+
+// LinkScreener flags links to domains with a bad reputation.
+type LinkScreener struct {
+	domains map[string]Verdict
+}
+
+// LoadLinks reads a domain reputation list. A domain also covers its
+// subdomains.
+//
+//	reject scam-example.com
+//	hold   bit.ly
+func LoadLinks(path string) (*LinkScreener, error) {
+	rules, err := loadRules(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &LinkScreener{domains: make(map[string]Verdict, len(rules))}
+	for _, r := range rules {
+		s.domains[strings.TrimPrefix(strings.ToLower(r.pattern), "www.")] = r.verdict
+	}
+	return s, nil
+}
+
+func (s *LinkScreener) Name() string { return "links" }
+
+func (s *LinkScreener) Screen(ctx context.Context, content Content) ([]Reason, error) {
+	var reasons []Reason
+	seen := map[string]bool{}
+	for _, link := range urlPattern.FindAllString(content.Text, -1) {
+		host := linkHost(link)
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		// Walk up the domain so "a.b.example.com" matches "example.com".
+		for domain := host; domain != ""; {
+			if verdict, ok := s.domains[domain]; ok {
+				reasons = append(reasons, Reason{Verdict: verdict, Message: "Links to a blocked website", Detail: host})
+				break
+			}
+			_, parent, found := strings.Cut(domain, ".")
+			if !found {
+				break
+			}
+			domain = parent
+		}
+	}
+	return reasons, nil
+}
+
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(strings.TrimRight(link, ".,;:!?"))
+	if err != nil {
+		return ""
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return strings.TrimPrefix(strings.ToLower(host), "www.")
+}
@@ -0,0 +1,101 @@
+package screening
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// History counts what an author has submitted recently.
+type History interface {
+	// Count returns how many items of kind authorKey submitted within window.
+	// A non-empty hash only counts items with that text.
+	Count(ctx context.Context, kind, authorKey, hash string, window time.Duration) (int, error)
+}
+
+// postgresHistory counts past verdicts in screening_results, which records
+// every screened item including rejected ones.
+type postgresHistory struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresHistory(db *pgxpool.Pool) History {
+	return &postgresHistory{db: db}
+}
+
+func (h *postgresHistory) Count(ctx context.Context, kind, authorKey, hash string, window time.Duration) (int, error) {
+	var n int
+	err := h.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM screening_results
+		WHERE content_type = $1 AND author_key = $2 AND ($3 = '' OR content_hash = $3)
+			AND created_at > CURRENT_TIMESTAMP - make_interval(secs => $4)`,
+		kind, authorKey, hash, window.Seconds()).Scan(&n)
+	return n, err
+}
+
+// minRepeatLength keeps short replies such as "thanks!" from counting as spam.
+const minRepeatLength = 20
+
+// RepeatScreener holds text its author has already posted Limit times within Window.
+type RepeatScreener struct {
+	History History
+	Limit   int
+	Window  time.Duration
+}
+
+func (s *RepeatScreener) Name() string { return "repeats" }
+
+func (s *RepeatScreener) Screen(ctx context.Context, content Content) ([]Reason, error) {
+	if content.Bulk || utf8.RuneCountInString(content.Text) < minRepeatLength {
+		return nil, nil
+	}
+	n, err := s.History.Count(ctx, content.Kind, content.AuthorKey(), Hash(content.Text), s.Window)
+	if err != nil || n < s.Limit {
+		return nil, err
+	}
+	return []Reason{{Verdict: Hold, Message: "The same text was posted several times recently"}}, nil
+}
+
+// RateLimit is how many items of one kind an author may post per Window
+// before they are held, and before they are rejected. A zero HoldAt never holds.
+type RateLimit struct {
+	HoldAt   int
+	RejectAt int
+	Window   time.Duration
+}
+
+// RateScreener holds or rejects content from authors posting faster than the
+// limit for its kind. Kinds without their own limit use Default.
+type RateScreener struct {
+	History History
+	Default RateLimit
+	Limits  map[string]RateLimit
+}
+
+func (s *RateScreener) Name() string { return "rate" }
+
+func (s *RateScreener) limit(kind string) RateLimit {
+	if limit, ok := s.Limits[kind]; ok {
+		return limit
+	}
+	return s.Default
+}
+
+func (s *RateScreener) Screen(ctx context.Context, content Content) ([]Reason, error) {
+	if content.Bulk {
+		return nil, nil
+	}
+	limit := s.limit(content.Kind)
+	n, err := s.History.Count(ctx, content.Kind, content.AuthorKey(), "", limit.Window)
+	switch {
+	case err != nil:
+		return nil, err
+	case n >= limit.RejectAt:
+		return []Reason{{Verdict: Reject, Message: "You are posting too quickly; please try again later"}}, nil
+	case limit.HoldAt > 0 && n >= limit.HoldAt:
+		return []Reason{{Verdict: Hold, Message: "Unusually many posts in a short time"}}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,228 @@
+package screening
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Verdict is the outcome of screening a piece of content.
+type Verdict string
+
+const (
+	Allow  Verdict = "allow"
+	Hold   Verdict = "hold"   // Saved, but hidden until a moderator approves it
+	Reject Verdict = "reject" // Not saved at all
+)
+
+func (v Verdict) severity() int {
+	switch v {
+	case Reject:
+		return 2
+	case Hold:
+		return 1
+	}
+	return 0
+}
+
+func parseVerdict(s string) (Verdict, bool) {
+	switch v := Verdict(strings.ToLower(s)); v {
+	case Hold, Reject:
+		return v, true
+	}
+	return "", false
+}
+
+// Content is user-written text about to be created or edited.
+type Content struct {
+	Kind   string // "idea", "message", "feedback" or "update"
+	UserID string // Empty for anonymous feedback
+	IP     string
+	Text   string
+	Bulk   bool // Bulk imports skip the history-based screeners
+}
+
+// AuthorKey identifies the author for the history-based screeners: the user,
+// or the client IP when there is no user.
+func (c Content) AuthorKey() string {
+	if c.UserID != "" {
+		return c.UserID
+	}
+	return "ip:" + c.IP
+}
+
+// Reason explains why a screener flagged content.
+type Reason struct {
+	Screener string  `json:"screener"`
+	Verdict  Verdict `json:"verdict"`
+	Message  string  `json:"message"`          // Safe to show to the author
+	Detail   string  `json:"detail,omitempty"` // The matched term or domain, for moderators only
+}
+
+// Result is the combined verdict of every screener; the strictest one wins.
+type Result struct {
+	Verdict Verdict
+	Reasons []Reason
+	Hash    string // Hash of the normalized text, see Hash
+}
+
+// Messages returns the reasons that can be shown to the author.
+func (r Result) Messages() []string {
+	messages := []string{}
+	seen := map[string]bool{}
+	for _, reason := range r.Reasons {
+		if !seen[reason.Message] {
+			seen[reason.Message] = true
+			messages = append(messages, reason.Message)
+		}
+	}
+	return messages
+}
+
+// Screener checks one aspect of a piece of content. It returns no reasons for
+// content it has no objection to.
+type Screener interface {
+	Name() string
+	Screen(ctx context.Context, content Content) ([]Reason, error)
+}
+
+// Pipeline runs a chain of screeners. Every screener runs so moderators see
+// all the reasons, not just the first.
+type Pipeline struct {
+	screeners []Screener
+}
+
+func NewPipeline(screeners ...Screener) *Pipeline {
+	return &Pipeline{screeners: screeners}
+}
+
+// Screen runs the pipeline. A screener that fails is logged and skipped:
+// screening must not take posting down with it.
+func (p *Pipeline) Screen(ctx context.Context, content Content) Result {
+	result := Result{Verdict: Allow, Reasons: []Reason{}, Hash: Hash(content.Text)}
+	for _, s := range p.screeners {
+		reasons, err := s.Screen(ctx, content)
+		if err != nil {
+			log.Printf("Screener %s failed: %v", s.Name(), err)
+			continue
+		}
+		for _, reason := range reasons {
+			reason.Screener = s.Name()
+			if reason.Verdict.severity() > result.Verdict.severity() {
+				result.Verdict = reason.Verdict
+			}
+			result.Reasons = append(result.Reasons, reason)
+		}
+	}
+	return result
+}
+
+// Default is the pipeline configured from the environment by Init. It allows
+// everything until then.
+var Default = NewPipeline()
+
+// Init configures Default. SCREENING=off disables it; the keyword and link
+// lists are only used when their files are set.
+func Init(db *pgxpool.Pool) error {
+	if os.Getenv("SCREENING") == "off" {
+		Default = NewPipeline()
+		return nil
+	}
+
+	var screeners []Screener
+	if path := os.Getenv("SCREENING_KEYWORDS_FILE"); path != "" {
+		keywords, err := LoadKeywords(path)
+		if err != nil {
+			return err
+		}
+		screeners = append(screeners, keywords)
+	}
+	if path := os.Getenv("SCREENING_LINKS_FILE"); path != "" {
+		links, err := LoadLinks(path)
+		if err != nil {
+			return err
+		}
+		screeners = append(screeners, links)
+	}
+
+	history := NewPostgresHistory(db)
+
+	repeatLimit, err := getEnvInt("SCREENING_REPEAT_LIMIT", 3)
+	if err != nil {
+		return err
+	}
+	repeatWindow, err := getEnvDuration("SCREENING_REPEAT_WINDOW", 24*time.Hour)
+	if err != nil {
+		return err
+	}
+	screeners = append(screeners, &RepeatScreener{History: history, Limit: repeatLimit, Window: repeatWindow})
+
+	rateHold, err := getEnvInt("SCREENING_RATE_HOLD", 30)
+	if err != nil {
+		return err
+	}
+	rateReject, err := getEnvInt("SCREENING_RATE_REJECT", 100)
+	if err != nil {
+		return err
+	}
+	rateWindow, err := getEnvDuration("SCREENING_RATE_WINDOW", 10*time.Minute)
+	if err != nil {
+		return err
+	}
+	// Chat is conversational, so messages are never held for their pace, only
+	// rejected when they arrive in floods.
+	messageReject, err := getEnvInt("SCREENING_MESSAGE_RATE_REJECT", 200)
+	if err != nil {
+		return err
+	}
+	screeners = append(screeners, &RateScreener{
+		History: history,
+		Default: RateLimit{HoldAt: rateHold, RejectAt: rateReject, Window: rateWindow},
+		Limits: map[string]RateLimit{
+			"message": {RejectAt: messageReject, Window: rateWindow},
+		},
+	})
+
+	Default = NewPipeline(screeners...)
+	return nil
+}
+
+// Hash fingerprints text ignoring case and whitespace, so trivially altered
+// copies still match.
+func Hash(text string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return n, nil
+}
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return d, nil
+}
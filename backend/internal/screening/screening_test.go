@@ -0,0 +1,227 @@
+package screening
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeList(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    []rule
+		wantErr string
+	}{
+		{
+			name: "verdicts, comments and blank lines",
+			file: "# spam\n\nhold bit.ly\n  REJECT   guaranteed returns  \n",
+			want: []rule{{Hold, "bit.ly"}, {Reject, "guaranteed returns"}},
+		},
+		{name: "empty file", file: "", want: nil},
+		{name: "unknown verdict", file: "hold a\nallow b\n", wantErr: ":2:"},
+		{name: "missing pattern", file: "reject\n", wantErr: ":1:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadRules(writeList(t, tt.file))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadRules error = %v, want one mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadRules = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRulesMissingFile(t *testing.T) {
+	if _, err := loadRules(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("loadRules on a missing file succeeded")
+	}
+}
+
+func TestKeywordScreener(t *testing.T) {
+	s, err := LoadKeywords(writeList(t, "reject guaranteed returns\nhold /whats\\s*app/\nhold c++\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		want []Verdict
+	}{
+		{"Guaranteed Returns for every investor!", []Verdict{Reject}},
+		{"guaranteed returnsX", nil}, // Whole words only
+		{"Message me on WhatsApp", []Verdict{Hold}},
+		{"whats app me", []Verdict{Hold}},
+		{"written in C++ and Go", []Verdict{Hold}}, // Plain patterns are literal
+		{"guaranteed returns, ask on whatsapp", []Verdict{Reject, Hold}},
+		{"a perfectly normal pitch", nil},
+	}
+	for _, tt := range tests {
+		reasons, err := s.Screen(context.Background(), Content{Text: tt.text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Verdict
+		for _, r := range reasons {
+			got = append(got, r.Verdict)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Screen(%q) verdicts = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLoadKeywordsInvalidRegexp(t *testing.T) {
+	if _, err := LoadKeywords(writeList(t, "hold /(unclosed/\n")); err == nil {
+		t.Error("LoadKeywords accepted an invalid regular expression")
+	}
+}
+
+func TestLinkHost(t *testing.T) {
+	tests := []struct {
+		link, want string
+	}{
+		{"https://Example.com/path?q=1", "example.com"},
+		{"http://www.example.com", "example.com"},
+		{"www.example.com/page", "example.com"},
+		{"https://sub.example.co.uk:8443/x", "sub.example.co.uk"},
+		{"https://example.com.", "example.com"},
+		{"http://example.com/a),", "example.com"},
+		{"http://192.168.0.1/admin", "192.168.0.1"},
+		{"http://[::1]:8080/", "::1"},
+		{"http://%zz", ""},
+	}
+	for _, tt := range tests {
+		if got := linkHost(tt.link); got != tt.want {
+			t.Errorf("linkHost(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestLinkScreener(t *testing.T) {
+	s, err := LoadLinks(writeList(t, "reject scam-example.com\nhold www.bit.ly\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		want []Verdict
+	}{
+		{"see https://scam-example.com/offer", []Verdict{Reject}},
+		{"see http://a.b.scam-example.com", []Verdict{Reject}},
+		{"short link www.bit.ly/abc", []Verdict{Hold}},
+		{"twice https://bit.ly/a https://bit.ly/b", []Verdict{Hold}},
+		{"not-scam-example.com is only text", nil},
+		{"https://notscam-example.com", nil},
+		{"https://example.com", nil},
+	}
+	for _, tt := range tests {
+		reasons, err := s.Screen(context.Background(), Content{Text: tt.text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Verdict
+		for _, r := range reasons {
+			got = append(got, r.Verdict)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Screen(%q) verdicts = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHashIgnoresCaseAndWhitespace(t *testing.T) {
+	if Hash("Buy  NOW\n today") != Hash("buy now today") {
+		t.Error("Hash differs for text that only differs in case and whitespace")
+	}
+	if Hash("buy now") == Hash("buy later") {
+		t.Error("Hash collides for different text")
+	}
+}
+
+// fakeHistory reports a fixed count per kind.
+type fakeHistory map[string]int
+
+func (h fakeHistory) Count(ctx context.Context, kind, authorKey, hash string, window time.Duration) (int, error) {
+	return h[kind], nil
+}
+
+func TestRateScreenerPerKind(t *testing.T) {
+	s := &RateScreener{
+		Default: RateLimit{HoldAt: 30, RejectAt: 100, Window: 10 * time.Minute},
+		Limits:  map[string]RateLimit{"message": {RejectAt: 200, Window: 10 * time.Minute}},
+	}
+	tests := []struct {
+		kind  string
+		count int
+		want  Verdict
+	}{
+		{"idea", 29, Allow},
+		{"idea", 30, Hold},
+		{"idea", 100, Reject},
+		{"message", 30, Allow},
+		{"message", 199, Allow},
+		{"message", 200, Reject},
+	}
+	for _, tt := range tests {
+		s.History = fakeHistory{tt.kind: tt.count}
+		result := NewPipeline(s).Screen(context.Background(), Content{Kind: tt.kind, UserID: "u", Text: "hello"})
+		if result.Verdict != tt.want {
+			t.Errorf("%s after %d: verdict %s, want %s", tt.kind, tt.count, result.Verdict, tt.want)
+		}
+	}
+}
+
+type failingScreener struct{}
+
+func (failingScreener) Name() string { return "failing" }
+func (failingScreener) Screen(context.Context, Content) ([]Reason, error) {
+	return nil, errors.New("boom")
+}
+
+type fixedScreener []Reason
+
+func (fixedScreener) Name() string { return "fixed" }
+func (s fixedScreener) Screen(context.Context, Content) ([]Reason, error) {
+	return s, nil
+}
+
+func TestPipelineStrictestVerdictWins(t *testing.T) {
+	p := NewPipeline(
+		fixedScreener{{Verdict: Hold, Message: "a"}},
+		failingScreener{},
+		fixedScreener{{Verdict: Reject, Message: "b"}, {Verdict: Hold, Message: "a"}},
+	)
+	result := p.Screen(context.Background(), Content{Text: "x"})
+	if result.Verdict != Reject {
+		t.Errorf("verdict = %s, want %s", result.Verdict, Reject)
+	}
+	if len(result.Reasons) != 3 || result.Reasons[0].Screener != "fixed" {
+		t.Errorf("reasons = %+v", result.Reasons)
+	}
+	if got := result.Messages(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Messages = %v, want [a b]", got)
+	}
+}
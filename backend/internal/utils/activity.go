@@ -11,6 +11,12 @@ import (
 
 // LogActivity records a user action in the database
 func LogActivity(c *gin.Context, userID, action string, details interface{}) {
+	LogActivityFrom(c.ClientIP(), userID, action, details)
+}
+
+// LogActivityFrom is LogActivity for callers that no longer hold the
+// request, such as a websocket, and captured the client IP up front.
+func LogActivityFrom(ip, userID, action string, details interface{}) {
 	// Serialize details to JSON if it's not a string
 	var detailsStr string
 	if s, ok := details.(string); ok {
@@ -22,8 +28,6 @@ func LogActivity(c *gin.Context, userID, action string, details interface{}) {
 		}
 	}
 
-	ip := c.ClientIP()
-
 	// Run in background so it doesn't block the request
 	go func() {
 		// Use a fresh context for background DB operation
@@ -2,20 +2,27 @@ package utils
 
 import (
 	"fmt"
+	"html"
 	"log"
 	"os"
+	"strings"
 
 	"gopkg.in/gomail.v2"
 )
 
+// FrontendURL is the base URL of the web app, used to build links in emails and feeds.
+func FrontendURL() string {
+	if url := os.Getenv("FRONTEND_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://invesa-prod-he47.vercel.app"
+}
+
 // SendResetEmail sends a password reset link to the user's email
 func SendResetEmail(toEmail, token string) error {
 	smtpEmail := os.Getenv("SMTP_EMAIL")
 	smtpPassword := os.Getenv("SMTP_PASSWORD")
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "https://invesa-prod-he47.vercel.app"
-	}
+	frontendURL := FrontendURL()
 	resetLink := fmt.Sprintf("%s/reset-password?token=%s", frontendURL, token)
 
 	// Fallback to console logging if SMTP creds are missing
@@ -61,10 +68,7 @@ func SendResetEmail(toEmail, token string) error {
 func SendRegistrationEmail(toEmail, token string) error {
 	smtpEmail := os.Getenv("SMTP_EMAIL")
 	smtpPassword := os.Getenv("SMTP_PASSWORD")
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "https://invesa-prod-he47.vercel.app"
-	}
+	frontendURL := FrontendURL()
 	registrationLink := fmt.Sprintf("%s/complete-registration?token=%s", frontendURL, token)
 
 	// Fallback to console logging if SMTP creds are missing
@@ -112,3 +116,115 @@ func SendRegistrationEmail(toEmail, token string) error {
 
 	return nil
 }
+
+// SendInvitationEmail invites someone by email to join an idea's team. The
+// link carries the invitation's single-use token.
+func SendInvitationEmail(toEmail, inviterName, ideaTitle, role string, invitationID int, token string) error {
+	smtpEmail := os.Getenv("SMTP_EMAIL")
+	smtpPassword := os.Getenv("SMTP_PASSWORD")
+	acceptLink := fmt.Sprintf("%s/invitations/%d?token=%s", FrontendURL(), invitationID, token)
+
+	// Fallback to console logging if SMTP creds are missing
+	if smtpEmail == "" || smtpPassword == "" {
+		log.Println("==================================================")
+		log.Printf("MOCK EMAIL TO: %s\n", toEmail)
+		log.Printf("TEAM INVITATION: %s invited you to %q as %s\n", inviterName, ideaTitle, role)
+		log.Printf("ACCEPT LINK: %s\n", acceptLink)
+		log.Println("==================================================")
+		return nil
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", smtpEmail)
+	m.SetHeader("To", toEmail)
+	m.SetHeader("Subject", fmt.Sprintf("%s invited you to join %s on Invesa", inviterName, ideaTitle))
+	m.SetBody("text/html", fmt.Sprintf(`
+		<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+			<h1 style="color: #f59e0b;">You're invited!</h1>
+			<p>%s invited you to join the team of <strong>%s</strong> as %s.</p>
+			<p style="margin: 30px 0;">
+				<a href="%s" style="background-color: #f59e0b; color: #000; padding: 12px 24px; text-decoration: none; border-radius: 6px; font-weight: bold;">Accept Invitation</a>
+			</p>
+			<p style="color: #666;">Sign in or sign up, then open this link to accept. It works only once.</p>
+		</div>
+	`, html.EscapeString(inviterName), html.EscapeString(ideaTitle), role, acceptLink))
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		host = "smtp.gmail.com"
+	}
+	port := 587
+
+	d := gomail.NewDialer(host, port, smtpEmail, smtpPassword)
+
+	if err := d.DialAndSend(m); err != nil {
+		log.Printf("Failed to send invitation email: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// DigestUpdate is one founder update listed in an updates digest email.
+type DigestUpdate struct {
+	IdeaID    int
+	IdeaTitle string
+	Title     string
+}
+
+// SendUpdatesDigestEmail sends one email listing a batch of founder updates
+// on ideas the recipient follows, liked or saved.
+func SendUpdatesDigestEmail(toEmail string, updates []DigestUpdate) error {
+	smtpEmail := os.Getenv("SMTP_EMAIL")
+	smtpPassword := os.Getenv("SMTP_PASSWORD")
+	frontendURL := FrontendURL()
+
+	// Fallback to console logging if SMTP creds are missing
+	if smtpEmail == "" || smtpPassword == "" {
+		log.Println("==================================================")
+		log.Printf("MOCK EMAIL TO: %s\n", toEmail)
+		for _, u := range updates {
+			log.Printf("UPDATE ON %q: %s (%s/ideas/%d)\n", u.IdeaTitle, u.Title, frontendURL, u.IdeaID)
+		}
+		log.Println("==================================================")
+		return nil
+	}
+
+	var items strings.Builder
+	for _, u := range updates {
+		fmt.Fprintf(&items, `<li><a href="%s/ideas/%d">%s</a>: %s</li>`,
+			frontendURL, u.IdeaID, html.EscapeString(u.IdeaTitle), html.EscapeString(u.Title))
+	}
+
+	subject := fmt.Sprintf("New update on %s", updates[0].IdeaTitle)
+	if len(updates) > 1 {
+		subject = fmt.Sprintf("%d new founder updates on Invesa", len(updates))
+	}
+
+	m := gomail.NewMessage()
+	m.SetHeader("From", smtpEmail)
+	m.SetHeader("To", toEmail)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", fmt.Sprintf(`
+		<div style="font-family: Arial, sans-serif; max-width: 600px; margin: 0 auto;">
+			<h1 style="color: #f59e0b;">Founder updates</h1>
+			<p>Founders of ideas you follow posted new updates:</p>
+			<ul>%s</ul>
+		</div>
+	`, items.String()))
+
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		host = "smtp.gmail.com"
+	}
+	port := 587
+
+	d := gomail.NewDialer(host, port, smtpEmail, smtpPassword)
+
+	if err := d.DialAndSend(m); err != nil {
+		log.Printf("Failed to send updates digest: %v\n", err)
+		return err
+	}
+
+	return nil
+}
@@ -11,9 +11,13 @@ import (
 	"syscall"
 	"time"
 
+	"invesa_backend/internal/cache"
 	"invesa_backend/internal/database"
 	"invesa_backend/internal/handlers"
 	"invesa_backend/internal/middleware"
+	"invesa_backend/internal/realtime"
+	"invesa_backend/internal/screening"
+	"invesa_backend/internal/storage"
 	"invesa_backend/internal/utils"
 
 	"github.com/gin-contrib/cors"
@@ -39,6 +43,37 @@ func main() {
 		utils.LogFatal("Failed to create tables: %v", err)
 	}
 
+	// Configure blob storage for idea attachments
+	if err := storage.Init(); err != nil {
+		utils.LogFatal("Failed to configure storage: %v", err)
+	}
+
+	// Configure the shared cache for idea listings
+	if err := cache.Init(); err != nil {
+		utils.LogFatal("Failed to configure cache: %v", err)
+	}
+
+	// Configure content screening for ideas, updates, messages and feedback
+	if err := screening.Init(database.DB); err != nil {
+		utils.LogFatal("Failed to configure content screening: %v", err)
+	}
+
+	// Configure how chat events reach sockets on other instances
+	if err := realtime.Init(database.DB); err != nil {
+		utils.LogFatal("Failed to configure realtime: %v", err)
+	}
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go handlers.StartRankingRefresher(jobsCtx, time.Minute)
+	go handlers.StartViewRecorder(jobsCtx)
+	go handlers.StartViewRollups(jobsCtx, 5*time.Minute)
+	go handlers.StartStatsReconciler(jobsCtx, time.Hour)
+	go handlers.StartTrashPurger(jobsCtx, time.Hour)
+	go handlers.StartScheduler(jobsCtx, 30*time.Second)
+	go handlers.StartUpdateDelivery(jobsCtx, 5*time.Minute)
+	go realtime.Default.Run(jobsCtx)
+
 	r := gin.New()
 	r.Use(gin.Recovery())
 	r.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
@@ -56,12 +91,14 @@ func main() {
 	r.Use(middleware.RequestID())
 	r.Use(middleware.SecurityHeaders())
 	r.Use(middleware.ResponseTime())
+	r.Use(middleware.StreamDeadlines())
 
 	rateLimitWindow := 1 * time.Minute
 	rateLimitCleanup := 5 * time.Minute
 	r.Use(middleware.RateLimit(120, rateLimitWindow, rateLimitCleanup))
 	r.Use(gzip.Gzip(gzip.DefaultCompression))
 	r.Use(middleware.CacheControl())
+	r.Use(middleware.ConditionalGET())
 
 	// CORS Setup
 	allowedOrigins := []string{
@@ -77,16 +114,20 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Admin-Key"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	// Routes
+	// Anonymous listings may be served by a CDN; anything personal is no-store.
+	publicCache := middleware.PublicCache(time.Minute, 30*time.Second)
+	noStore := middleware.NoStore()
+
 	api := r.Group("/api")
 	{
-		api.GET("/health", func(c *gin.Context) {
+		api.GET("/health", noStore, func(c *gin.Context) {
 			utils.RespondWithJSON(c, http.StatusOK, gin.H{"status": "ok"})
 		})
 
@@ -108,13 +149,130 @@ func main() {
 
 		api.POST("/feedback", handlers.SubmitFeedback)
 
-		api.GET("/ideas", handlers.GetIdeas)
-		api.POST("/ideas", handlers.CreateIdea)
-		api.DELETE("/ideas/:id", middleware.RequireAuth(), handlers.DeleteIdea)
-		api.POST("/ideas/:id/like", handlers.LikeIdea)
+		api.GET("/categories", publicCache, handlers.GetCategories)
+		api.GET("/tags", publicCache, handlers.GetTags) // ?prefix=fin
+		api.POST("/categories/:slug/follow", middleware.RequireAuth(), handlers.FollowCategory)
+		api.DELETE("/categories/:slug/follow", middleware.RequireAuth(), handlers.UnfollowCategory)
+
+		api.GET("/users/:id", publicCache, middleware.OptionalAuth(), handlers.GetUserProfile)
+		api.GET("/users/:id/followers", publicCache, handlers.GetFollowers)
+		api.GET("/users/:id/following", publicCache, handlers.GetFollowing)
+		api.POST("/users/:id/follow", middleware.RequireAuth(), handlers.FollowUser)
+		api.DELETE("/users/:id/follow", middleware.RequireAuth(), handlers.UnfollowUser)
+
+		api.GET("/ideas", publicCache, middleware.OptionalAuth(), handlers.GetIdeas)
+		api.POST("/ideas", middleware.RequireAuth(), handlers.CreateIdea)
+		api.GET("/ideas/:id", middleware.OptionalAuth(), handlers.GetIdea) // ?share=<token> for unlisted ideas
+		api.POST("/ideas/:id/publish", middleware.RequireAuth(), handlers.PublishIdea)
+		api.DELETE("/ideas/:id/schedule", middleware.RequireAuth(), handlers.CancelScheduledPublish) // Undoes {"publish_at": ...} on publish
+		api.PUT("/ideas/:id/visibility", middleware.RequireAuth(), handlers.UpdateIdeaVisibility)
+		api.PUT("/ideas/:id", middleware.RequireAuth(), handlers.UpdateIdea)
+		api.DELETE("/ideas/:id", middleware.RequireAuth(), handlers.DeleteIdea) // Moves the idea to the trash
+		api.POST("/ideas/:id/restore", middleware.RequireAuth(), handlers.RestoreIdea)
+		api.PUT("/ideas/:id/reactions/:kind", middleware.RequireAuth(), handlers.SetReaction) // like, insightful, would_invest
+		api.DELETE("/ideas/:id/reactions/:kind", middleware.RequireAuth(), handlers.RemoveReaction)
+		api.GET("/ideas/:id/related", publicCache, middleware.OptionalAuth(), handlers.GetRelatedIdeas) // ?limit=5
+		api.GET("/ideas/:id/attachments", middleware.OptionalAuth(), handlers.GetAttachments)
+		api.POST("/ideas/:id/attachments", middleware.RequireAuth(), handlers.UploadAttachment)
+		api.DELETE("/ideas/:id/attachments/:attachmentId", middleware.RequireAuth(), handlers.DeleteAttachment)
+		api.GET("/files/*key", handlers.ServeFile) // signed, expiring local-storage downloads
+		api.GET("/ideas/:id/analytics", noStore, middleware.RequireAuth(), handlers.GetIdeaAnalytics)
+		api.PUT("/ideas/:id/confidential", middleware.RequireAuth(), handlers.UpdateConfidentialDetails)
+		api.GET("/ideas/:id/nda", middleware.OptionalAuth(), handlers.GetNDA)
+		api.POST("/ideas/:id/nda/accept", middleware.RequireAuth(), handlers.AcceptNDA)
+		api.GET("/ideas/:id/nda/signatures", noStore, middleware.RequireAuth(), handlers.GetNDASignatures)
+		api.DELETE("/ideas/:id/nda/signatures/:acceptanceId", middleware.RequireAuth(), handlers.RevokeNDAAccess)
+		api.GET("/ideas/:id/rounds", publicCache, middleware.OptionalAuth(), handlers.GetRounds)
+		api.POST("/ideas/:id/rounds", middleware.RequireAuth(), handlers.CreateRound)
+		api.PUT("/ideas/:id/rounds/:roundId", middleware.RequireAuth(), handlers.UpdateRound)
+		api.GET("/ideas/:id/rounds/:roundId/progress", noStore, middleware.RequireAuth(), handlers.GetRoundProgress)
+		api.PUT("/ideas/:id/rounds/:roundId/interest", middleware.RequireAuth(), handlers.SetRoundInterest)
+		api.POST("/ideas/:id/not-interested", middleware.RequireAuth(), handlers.NotInterested)
+		api.DELETE("/ideas/:id/not-interested", middleware.RequireAuth(), handlers.UndoNotInterested)
+		api.PUT("/ideas/:id/bookmark", middleware.RequireAuth(), handlers.BookmarkIdea)
+		api.DELETE("/ideas/:id/bookmark", middleware.RequireAuth(), handlers.RemoveBookmark) // ?watchlist_id= removes from one list only
+
+		// Idea teams: owners invite editors and viewers by username or email
+		api.GET("/ideas/:id/members", noStore, middleware.RequireAuth(), handlers.GetIdeaMembers)
+		api.POST("/ideas/:id/invitations", middleware.RequireAuth(), handlers.InviteToIdea)
+		api.DELETE("/ideas/:id/invitations/:invitationId", middleware.RequireAuth(), handlers.RevokeInvitation)
+		api.PUT("/ideas/:id/members/:userId", middleware.RequireAuth(), handlers.UpdateMemberRole)
+		api.DELETE("/ideas/:id/members/:userId", middleware.RequireAuth(), handlers.RemoveMember) // Own user id to leave
+		api.POST("/ideas/:id/transfer", middleware.RequireAuth(), handlers.TransferIdeaOwnership)
+		// Email invitations need {"token": ...} from the emailed link
+		api.POST("/invitations/:id/accept", middleware.RequireAuth(), handlers.AcceptInvitation)
+		api.POST("/invitations/:id/decline", middleware.RequireAuth(), handlers.DeclineInvitation)
+
+		// Founder updates; followers and supporters get them in batches
+		api.GET("/ideas/:id/updates", middleware.OptionalAuth(), handlers.GetIdeaUpdates)
+		api.POST("/ideas/:id/updates", middleware.RequireAuth(), handlers.CreateIdeaUpdate) // Owner or editor; publish_at schedules it
+		api.PUT("/ideas/:id/updates/:updateId", middleware.RequireAuth(), handlers.EditIdeaUpdate)
+		api.DELETE("/ideas/:id/updates/:updateId", middleware.RequireAuth(), handlers.DeleteIdeaUpdate)
 
-		api.POST("/messages", handlers.SendMessage)
-		api.GET("/messages", handlers.GetMessages) // ?user1=1&user2=2
+		// Bulk import (CSV or NDJSON, ?dry_run=true) and streaming export of the caller's ideas
+		api.POST("/ideas/import", middleware.RequireAuth(), handlers.ImportIdeas)
+		api.GET("/ideas/export", noStore, middleware.RequireAuth(), handlers.ExportIdeas) // ?format=csv|ndjson|xlsx
+
+		me := api.Group("/me", noStore, middleware.RequireAuth())
+		{
+			me.GET("/watchlists", handlers.GetWatchlists)
+			me.POST("/watchlists", handlers.CreateWatchlist)
+			me.PUT("/watchlists/:id", handlers.RenameWatchlist)
+			me.DELETE("/watchlists/:id", handlers.DeleteWatchlist)
+			me.GET("/notifications", handlers.GetNotifications) // ?unread=true
+			me.POST("/notifications/read", handlers.MarkNotificationsRead)
+			me.GET("/feed-token", handlers.GetFeedToken)
+			me.POST("/feed-token/rotate", handlers.RotateFeedToken)
+			me.GET("/trash", handlers.GetTrash)
+			me.GET("/invitations", handlers.GetMyInvitations)
+		}
+
+		// Atom, RSS and JSON Feed; ?token= from /me/feed-token adds investors-only ideas
+		api.GET("/feeds/ideas.atom", publicCache, handlers.GetIdeasAtomFeed)
+		api.GET("/feeds/ideas.rss", publicCache, handlers.GetIdeasRSSFeed)
+		api.GET("/feeds/ideas.json", publicCache, handlers.GetIdeasJSONFeed)
+
+		api.GET("/feed", noStore, middleware.RequireAuth(), handlers.GetFeed)
+		api.GET("/feed/following", noStore, middleware.RequireAuth(), handlers.GetFollowingFeed)
+
+		api.POST("/messages", middleware.RequireAuth(), handlers.SendMessage)
+		api.GET("/messages", noStore, middleware.RequireAuth(), handlers.GetMessages) // ?with=<user id>
+
+		// Conversation list with unread counts; read marks messages up to message_id (all when omitted)
+		api.GET("/conversations", noStore, middleware.RequireAuth(), handlers.GetConversations)
+		api.POST("/conversations/:id/read", middleware.RequireAuth(), handlers.MarkConversationRead)
+
+		// Chat push: new messages, delivery acks and presence (?after_id= resumes)
+		api.GET("/ws/chat", noStore, middleware.RequireSocketAuth(), handlers.ChatSocket(allowedOrigins))
+
+		// Content reports and the moderation queue (moderators or X-Admin-Key)
+		api.POST("/reports", middleware.RequireAuth(), handlers.CreateReport)
+		moderation := api.Group("/moderation", noStore, middleware.RequireModerator())
+		{
+			moderation.GET("/reports", handlers.GetReports) // ?status=open,claimed&target_type=&reason=
+			moderation.POST("/reports/:id/claim", handlers.ClaimReport)
+			moderation.POST("/reports/:id/actions", handlers.TakeModerationAction)
+			moderation.POST("/reports/:id/resolve", handlers.ResolveReport)
+			moderation.POST("/reports/:id/dismiss", handlers.DismissReport)
+			moderation.GET("/screenings", handlers.GetScreenings) // ?status=pending&verdict=&content_type=
+			moderation.POST("/screenings/:id/approve", handlers.ApproveScreening)
+			moderation.POST("/screenings/:id/reject", handlers.RejectScreening)
+		}
+
+		admin := api.Group("/admin", noStore, middleware.RequireAdmin())
+		{
+			admin.GET("/categories", handlers.AdminGetCategories)
+			admin.POST("/categories", handlers.AdminCreateCategory)
+			admin.PUT("/categories/:id", handlers.AdminUpdateCategory)
+			admin.DELETE("/categories/:id", handlers.AdminDeleteCategory)
+			admin.PUT("/users/:id/verification", handlers.AdminSetUserVerified)
+			admin.PUT("/users/:id/moderator", handlers.AdminSetModerator)
+			admin.GET("/cache/stats", handlers.AdminCacheStats)
+			admin.POST("/ideas/import", handlers.AdminImportIdeas)
+			admin.GET("/ideas/export", handlers.AdminExportIdeas)
+			admin.POST("/ideas/:id/restore", handlers.AdminRestoreIdea)
+			admin.DELETE("/ideas/:id", handlers.AdminPurgeIdea)
+		}
 
 		// protected := api.Group("/", middleware.RequireAuth())
 		// {
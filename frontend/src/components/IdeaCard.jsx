@@ -32,7 +32,9 @@ const IdeaCard = ({ idea, currentUser, onDelete, showChat = true }) => {
         setIsLiked(newIsLiked);
         setLikes(prev => newIsLiked ? prev + 1 : prev - 1);
         try {
-            await api.post(`/ideas/${idea.id}/like`, { user_id: currentUser.id });
+            const path = `/ideas/${idea.id}/reactions/like`;
+            const response = newIsLiked ? await api.put(path) : await api.delete(path);
+            setLikes(response.data.reactions?.like || 0);
         } catch (e) {
             console.error(e);
             setIsLiked(!newIsLiked);
@@ -68,10 +70,17 @@ const IdeaCard = ({ idea, currentUser, onDelete, showChat = true }) => {
                 <div className="flex justify-between items-start mb-3">
                     <h3 className="text-xl font-semibold text-white">{idea.title}</h3>
                     <span className="items-center rounded-full border border-gray-800 bg-[#111] px-2.5 py-0.5 text-xs font-semibold text-gray-300">
-                        {idea.category || 'Other'}
+                        {idea.category_name || idea.category || 'Other'}
                     </span>
                 </div>
                 <p className="text-gray-400 mb-6 whitespace-pre-wrap">{idea.description}</p>
+                {idea.tags?.length > 0 && (
+                    <div className="flex flex-wrap gap-2 -mt-3 mb-6">
+                        {idea.tags.map((tag) => (
+                            <span key={tag} className="text-xs text-gray-500">#{tag}</span>
+                        ))}
+                    </div>
+                )}
             </div>
 
             <div className="mt-auto pt-4 border-t border-gray-900 flex items-center justify-between">
@@ -4,16 +4,33 @@ import api from '../api';
 import Button from '../components/Button';
 import Input from '../components/Input';
 
+// ws(s)://host/api/ws/chat for the API's base URL
+const chatSocketUrl = () => {
+    const base = new URL(api.defaults.baseURL, window.location.href);
+    base.protocol = base.protocol === 'https:' ? 'wss:' : 'ws:';
+    return base.toString().replace(/\/$/, '') + '/ws/chat';
+};
+
 const Chat = () => {
     const [conversations, setConversations] = useState([]);
     const [selectedUser, setSelectedUser] = useState(null);
     const [messages, setMessages] = useState([]);
     const [newMessage, setNewMessage] = useState('');
     const [loading, setLoading] = useState(true);
+    const [connected, setConnected] = useState(false);
+    const [onlineUsers, setOnlineUsers] = useState(() => new Set());
+    const [notice, setNotice] = useState('');
     const [currentUser] = useState(() => JSON.parse(localStorage.getItem('user')));
     const [searchParams] = useSearchParams();
     const navigate = useNavigate();
     const messagesEndRef = useRef(null);
+    const socketRef = useRef(null);
+    const lastIdRef = useRef(0); // Resume point after a reconnect
+    const selectedRef = useRef(null);
+
+    useEffect(() => {
+        selectedRef.current = selectedUser;
+    }, [selectedUser]);
 
     const scrollToBottom = () => {
         messagesEndRef.current?.scrollIntoView({ behavior: "smooth" });
@@ -21,17 +38,25 @@ const Chat = () => {
 
     const fetchConversations = async () => {
         try {
-            // Fetch all messages involving current user to group them
-            // This is inefficient but works without a dedicated endpoint
-            const response = await api.get(`/messages?user1=${currentUser.id}&user2=${currentUser.id}&limit=500`);
-            setLoading(false);
+            const response = await api.get('/conversations?limit=50');
+            setConversations(response.data.items || []);
         } catch (error) {
             console.error("Failed to fetch conversations", error);
+        } finally {
             setLoading(false);
         }
     };
 
-    // Initial load: fetch all messages to build conversation list
+    const markRead = async (conversationId, messageId) => {
+        try {
+            await api.post(`/conversations/${conversationId}/read`, messageId ? { message_id: messageId } : {});
+            setConversations(prev => prev.map(c => c.id === conversationId ? { ...c, unread_count: 0 } : c));
+        } catch (error) {
+            console.error("Failed to mark conversation read", error);
+        }
+    };
+
+    // Initial load: the conversation list
     useEffect(() => {
         if (!currentUser) {
             navigate('/login');
@@ -43,10 +68,10 @@ const Chat = () => {
     // Check query param for initial selected user
     useEffect(() => {
         const userId = searchParams.get('user');
-        if (userId && conversations.length > 0) {
-            const user = conversations.find(c => c.id === userId);
-            if (user) {
-                setSelectedUser(user);
+        if (userId && !loading && selectedRef.current?.id !== userId) {
+            const conversation = conversations.find(c => c.counterpart.id === userId);
+            if (conversation) {
+                setSelectedUser(conversation.counterpart);
             } else {
                 // If not in list (new chat?), we might need to fetch user details or just start empty
                 // For now, let's assume we can chat if we have the ID.
@@ -56,42 +81,188 @@ const Chat = () => {
                 }
             }
         }
-    }, [searchParams, conversations]);
+    }, [searchParams, conversations, loading]);
 
-    // Fetch messages when selectedUser changes
-    useEffect(() => {
-        if (!selectedUser) return;
+    const fetchMessages = async (user = selectedRef.current) => {
+        if (!user) return;
+        try {
+            const response = await api.get(`/messages?with=${user.id}`);
+            const items = response.data.items || [];
+            setMessages(items);
+            const received = items.filter(m => m.receiver_id === currentUser.id && !m.delivered_at);
+            if (received.length > 0) {
+                sendEvent({ type: 'ack', message_id: received[received.length - 1].id });
+            }
+            const unread = items.filter(m => m.receiver_id === currentUser.id && !m.read_at);
+            if (unread.length > 0) {
+                markRead(unread[0].conversation_id);
+            }
+            scrollToBottom();
+        } catch (error) {
+            console.error("Failed to fetch messages", error);
+        }
+    };
 
-        const fetchMessages = async () => {
-            try {
-                const response = await api.get(`/messages?user1=${currentUser.id}&user2=${selectedUser.id}`);
-                setMessages(response.data.items || []);
-                scrollToBottom();
-            } catch (error) {
-                console.error("Failed to fetch messages", error);
+    const sendEvent = (event) => {
+        const socket = socketRef.current;
+        if (socket?.readyState !== WebSocket.OPEN) return false;
+        socket.send(JSON.stringify(event));
+        return true;
+    };
+
+    const handleEvent = ({ type, data }) => {
+        const selected = selectedRef.current;
+        switch (type) {
+            case 'ready':
+                lastIdRef.current = Math.max(lastIdRef.current, data.last_id);
+                setOnlineUsers(new Set(data.online));
+                break;
+            case 'message': {
+                lastIdRef.current = Math.max(lastIdRef.current, data.id);
+                if (data.receiver_id === currentUser.id) {
+                    sendEvent({ type: 'ack', message_id: data.id });
+                }
+                const partner = data.sender_id === currentUser.id ? data.receiver_id : data.sender_id;
+                const open = selected && partner === selected.id;
+                if (open) {
+                    setMessages(prev => prev.some(m => m.id === data.id) ? prev : [...prev, data]);
+                    scrollToBottom();
+                    if (data.receiver_id === currentUser.id) {
+                        markRead(data.conversation_id, data.id);
+                    }
+                }
+                if (!conversations.some(c => c.id === data.conversation_id)) {
+                    fetchConversations();
+                    break;
+                }
+                setConversations(prev => {
+                    const existing = prev.find(c => c.id === data.conversation_id);
+                    if (!existing || existing.last_message.id >= data.id) return prev;
+                    const updated = {
+                        ...existing,
+                        last_message: { id: data.id, sender_id: data.sender_id, content: data.content.slice(0, 200), created_at: data.created_at },
+                        unread_count: existing.unread_count + (data.receiver_id === currentUser.id && !open ? 1 : 0),
+                        updated_at: data.created_at
+                    };
+                    return [updated, ...prev.filter(c => c.id !== data.conversation_id)];
+                });
+                break;
+            }
+            case 'read':
+                if (data.user_id === currentUser.id) {
+                    // Read in another tab
+                    fetchConversations();
+                } else {
+                    const ids = new Set(data.message_ids);
+                    setMessages(prev => prev.map(m => ids.has(m.id) ? { ...m, read_at: data.read_at } : m));
+                }
+                break;
+            case 'delivered': {
+                const ids = new Set(data.message_ids);
+                setMessages(prev => prev.map(m => ids.has(m.id) ? { ...m, delivered_at: data.delivered_at } : m));
+                break;
             }
+            case 'presence':
+                setOnlineUsers(prev => {
+                    const next = new Set(prev);
+                    if (data.online) next.add(data.user_id);
+                    else next.delete(data.user_id);
+                    return next;
+                });
+                break;
+            case 'held':
+                setNotice('Your message is being reviewed and will be delivered once approved.');
+                break;
+            case 'error':
+                setNotice(data.error || 'Failed to send message');
+                break;
+            case 'incomplete':
+                // Too much was missed to replay; reload the conversation
+                fetchMessages();
+                break;
+            case 'ping':
+                sendEvent({ type: 'pong' });
+                break;
+            default:
+                break;
+        }
+    };
+    const handleEventRef = useRef(handleEvent);
+    handleEventRef.current = handleEvent;
+
+    // Chat socket: pushes messages, delivery acks and presence, and resumes
+    // from the last message seen after a reconnect
+    useEffect(() => {
+        if (!currentUser?.token) return;
+        let closed = false;
+        let retryDelay = 1000;
+        let retryTimer;
+
+        const connect = () => {
+            const url = chatSocketUrl() + (lastIdRef.current ? `?after_id=${lastIdRef.current}` : '');
+            const socket = new WebSocket(url, ['bearer', currentUser.token]);
+            socketRef.current = socket;
+            socket.onopen = () => {
+                retryDelay = 1000;
+                setConnected(true);
+            };
+            socket.onmessage = (e) => {
+                try {
+                    handleEventRef.current(JSON.parse(e.data));
+                } catch (error) {
+                    console.error("Bad chat event", error);
+                }
+            };
+            socket.onclose = () => {
+                setConnected(false);
+                if (closed) return;
+                retryTimer = setTimeout(connect, retryDelay);
+                retryDelay = Math.min(retryDelay * 2, 30000);
+            };
         };
 
-        fetchMessages();
-        const interval = setInterval(fetchMessages, 3000); // Poll every 3s
+        connect();
+        return () => {
+            closed = true;
+            clearTimeout(retryTimer);
+            socketRef.current?.close();
+        };
+    }, [currentUser?.token]);
+
+    // Fetch messages when selectedUser changes; poll only while the socket is down
+    useEffect(() => {
+        if (!selectedUser) return;
+
+        fetchMessages(selectedUser);
+        if (connected) return;
+        const interval = setInterval(() => fetchMessages(selectedUser), 3000);
         return () => clearInterval(interval);
-    }, [selectedUser, currentUser.id]);
+    }, [selectedUser, currentUser.id, connected]);
 
     const handleSend = async (e) => {
         e.preventDefault();
         if (!newMessage.trim() || !selectedUser) return;
 
+        setNotice('');
+        const sent = sendEvent({
+            type: 'send',
+            client_id: `${Date.now()}`,
+            receiver_id: selectedUser.id,
+            content: newMessage.trim()
+        });
+        if (sent) {
+            // The message comes back over the socket once it is stored
+            setNewMessage('');
+            return;
+        }
+
         try {
             await api.post('/messages', {
-                sender_id: currentUser.id,
                 receiver_id: selectedUser.id,
                 content: newMessage
             });
             setNewMessage('');
-            // Manually fetch or wait for poll
-            const response = await api.get(`/messages?user1=${currentUser.id}&user2=${selectedUser.id}`);
-            setMessages(response.data.items || []);
-            scrollToBottom();
+            fetchMessages(selectedUser);
         } catch (error) {
             console.error("Failed to send message", error);
         }
@@ -101,21 +272,44 @@ const Chat = () => {
         <div className="container mx-auto p-4 min-h-[calc(100vh-4rem)] flex pt-20">
             <div className="w-full max-w-4xl mx-auto border border-gray-800 rounded-xl bg-[#0a0a0a] flex overflow-hidden h-[80vh]">
 
-                {/* Sidebar (Conversations) - Placeholder if we can't fetch list */}
+                {/* Sidebar (Conversations) */}
                 <div className="w-1/3 border-r border-gray-800 bg-[#111] flex flex-col">
                     <div className="p-4 border-b border-gray-800">
                         <h2 className="font-semibold text-white">Chats</h2>
                     </div>
                     <div className="flex-1 overflow-y-auto p-2">
-                        {/* We can't list chats easily without backend change. 
-                            If selectedUser is set from URL, show it.
-                        */}
-                        {selectedUser && (
+                        {selectedUser && !conversations.some(c => c.counterpart.id === selectedUser.id) && (
                             <div className="p-3 mb-2 rounded-lg bg-gray-800 cursor-pointer">
                                 <p className="font-medium text-white">{selectedUser.username || 'Current Chat'}</p>
                             </div>
                         )}
-                        {!selectedUser && (
+                        {conversations.map((conversation) => {
+                            const { counterpart, last_message: last } = conversation;
+                            const active = selectedUser?.id === counterpart.id;
+                            return (
+                                <div
+                                    key={conversation.id}
+                                    onClick={() => setSelectedUser(counterpart)}
+                                    className={`p-3 mb-2 rounded-lg cursor-pointer ${active ? 'bg-gray-800' : 'hover:bg-gray-900'}`}
+                                >
+                                    <div className="flex items-center justify-between gap-2">
+                                        <p className="font-medium text-white truncate">
+                                            {counterpart.full_name || counterpart.username}
+                                            {onlineUsers.has(counterpart.id) && <span className="ml-2 inline-block w-2 h-2 rounded-full bg-green-500" />}
+                                        </p>
+                                        {conversation.unread_count > 0 && (
+                                            <span className="text-[10px] font-semibold bg-primary text-black rounded-full px-2 py-0.5">
+                                                {conversation.unread_count}
+                                            </span>
+                                        )}
+                                    </div>
+                                    <p className="text-xs text-gray-500 truncate">
+                                        {last.sender_id === currentUser.id ? 'You: ' : ''}{last.content}
+                                    </p>
+                                </div>
+                            );
+                        })}
+                        {!loading && conversations.length === 0 && !selectedUser && (
                             <p className="text-sm text-gray-500 p-2">Select a business from Home to chat.</p>
                         )}
                     </div>
@@ -127,6 +321,9 @@ const Chat = () => {
                         <>
                             <div className="p-4 border-b border-gray-800 bg-[#111]">
                                 <h3 className="font-semibold text-white">{selectedUser.username || 'Chat'}</h3>
+                                <span className="text-xs text-gray-500">
+                                    {onlineUsers.has(selectedUser.id) ? 'Online' : 'Offline'}
+                                </span>
                             </div>
 
                             <div className="flex-1 overflow-y-auto p-4 space-y-3">
@@ -138,6 +335,7 @@ const Chat = () => {
                                                 <p className="text-sm">{msg.content}</p>
                                                 <span className="text-[10px] opacity-70 block text-right mt-1">
                                                     {new Date(msg.created_at).toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' })}
+                                                    {isMe && (msg.read_at ? ' · Read' : msg.delivered_at ? ' · Delivered' : '')}
                                                 </span>
                                             </div>
                                         </div>
@@ -146,6 +344,10 @@ const Chat = () => {
                                 <div ref={messagesEndRef} />
                             </div>
 
+                            {notice && (
+                                <p className="px-4 py-2 text-xs text-yellow-400 border-t border-gray-800 bg-[#111]">{notice}</p>
+                            )}
+
                             <form onSubmit={handleSend} className="p-4 border-t border-gray-800 bg-[#111] flex gap-2">
                                 <Input
                                     value={newMessage}
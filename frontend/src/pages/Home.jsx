@@ -10,8 +10,15 @@ const Home = () => {
     const [loading, setLoading] = useState(true);
     const [search, setSearch] = useState('');
     const [category, setCategory] = useState('All');
+    const [categories, setCategories] = useState([]);
     const currentUser = JSON.parse(localStorage.getItem('user'));
 
+    useEffect(() => {
+        api.get('/categories')
+            .then((response) => setCategories(response.data?.items || []))
+            .catch((error) => console.error("Failed to fetch categories", error));
+    }, []);
+
     useEffect(() => {
         const fetchIdeas = async () => {
             setLoading(true);
@@ -82,16 +89,16 @@ const Home = () => {
 
                     {/* Filter Chips */}
                     <div className="flex flex-wrap gap-2 justify-center sm:justify-start">
-                        {['All', 'Technology', 'Health', 'Finance', 'Education', 'Other'].map((cat) => (
+                        {[{ slug: 'All', name: 'All' }, ...categories].map((cat) => (
                             <button
-                                key={cat}
-                                onClick={() => setCategory(cat)}
-                                className={`px-4 py-1.5 rounded-full text-sm font-medium transition-colors border ${category === cat
+                                key={cat.slug}
+                                onClick={() => setCategory(cat.slug)}
+                                className={`px-4 py-1.5 rounded-full text-sm font-medium transition-colors border ${category === cat.slug
                                     ? 'bg-primary text-black border-primary'
                                     : 'bg-transparent text-gray-400 border-gray-800 hover:border-gray-600'
                                     }`}
                             >
-                                {cat}
+                                {cat.name}
                             </button>
                         ))}
                     </div>
@@ -1,14 +1,21 @@
-import { useState } from 'react';
+import { useEffect, useState } from 'react';
 import { useNavigate } from 'react-router-dom';
 import api from '../api';
 import Button from '../components/Button';
 import Input from '../components/Input';
 
 const PostIdea = () => {
-    const [formData, setFormData] = useState({ title: '', description: '', category: 'Other' });
+    const [formData, setFormData] = useState({ title: '', description: '', category: 'other', tags: '' });
+    const [categories, setCategories] = useState([]);
     const navigate = useNavigate();
     const user = JSON.parse(localStorage.getItem('user'));
 
+    useEffect(() => {
+        api.get('/categories')
+            .then((response) => setCategories(response.data?.items || []))
+            .catch((error) => console.error("Failed to fetch categories", error));
+    }, []);
+
     const handleSubmit = async (e) => {
         e.preventDefault();
         if (!user) return navigate('/login');
@@ -16,7 +23,7 @@ const PostIdea = () => {
         try {
             await api.post('/ideas', {
                 ...formData,
-                user_id: user.id
+                tags: formData.tags.split(',').map((t) => t.trim()).filter(Boolean)
             });
             navigate('/');
         } catch (error) {
@@ -46,16 +53,9 @@ const PostIdea = () => {
                             value={formData.category}
                             onChange={(e) => setFormData({ ...formData, category: e.target.value })}
                         >
-                            <option value="Other" className="bg-black text-white">Select Category</option>
-                            <option value="Technology" className="bg-black text-white">Technology</option>
-                            <option value="Health & Wellness" className="bg-black text-white">Health & Wellness</option>
-                            <option value="Finance" className="bg-black text-white">Finance</option>
-                            <option value="Education" className="bg-black text-white">Education</option>
-                            <option value="Lifestyle" className="bg-black text-white">Lifestyle</option>
-                            <option value="Food & Beverage" className="bg-black text-white">Food & Beverage</option>
-                            <option value="Art & Design" className="bg-black text-white">Art & Design</option>
-                            <option value="Retail" className="bg-black text-white">Retail</option>
-                            <option value="Other" className="bg-black text-white">Other</option>
+                            {categories.map((cat) => (
+                                <option key={cat.slug} value={cat.slug} className="bg-black text-white">{cat.name}</option>
+                            ))}
                         </select>
                         <div className="pointer-events-none absolute right-3 top-1/2 -translate-y-1/2 text-gray-400">
                             <svg xmlns="http://www.w3.org/2000/svg" width="16" height="16" viewBox="0 0 24 24" fill="none" stroke="currentColor" strokeWidth="2" strokeLinecap="round" strokeLinejoin="round">
@@ -76,6 +76,15 @@ const PostIdea = () => {
                     />
                 </div>
 
+                <div>
+                    <label className="block text-sm font-medium mb-1">Tags</label>
+                    <Input
+                        value={formData.tags}
+                        onChange={(e) => setFormData({ ...formData, tags: e.target.value })}
+                        placeholder="e.g. saas, b2b, ai"
+                    />
+                </div>
+
                 <Button type="submit" className="text-black font-semibold">Post Idea</Button>
             </form>
         </div>